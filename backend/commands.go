@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/export"
+	"comtradeviewer/storage"
+)
+
+// commands 命令行子命令, 以 `comtradeviewer <command> [flags]` 方式运行, 不启动 HTTP 服务
+var commands = map[string]func(stor storage.Storage, args []string) int{
+	"export-parquet": runExportParquet,
+	"convert":        runConvert,
+}
+
+// runCommand 执行子命令并返回进程退出码, args[0] 为子命令名
+func runCommand(stor storage.Storage, args []string) int {
+	run, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Fprintf(os.Stderr, "unknown command %q, available: %s\n", args[0], strings.Join(names, ", "))
+		return 2
+	}
+	return run(stor, args[1:])
+}
+
+// commandDatasetIDs 返回命令行指定的数据集 ID, 未指定时返回存储中的全部数据集(按 ID 排序)
+func commandDatasetIDs(stor storage.Storage, args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	datasets, err := listDatasets(stor)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(datasets))
+	for _, d := range datasets {
+		ids = append(ids, d.DatasetID)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// runExportParquet 批量将数据集的全部模拟量与开关量通道导出为 Parquet 文件, 每个数据集一个文件
+// 未指定数据集 ID 时导出存储中的所有数据集; 单个数据集失败不影响其余数据集, 但退出码为 1
+func runExportParquet(stor storage.Storage, args []string) int {
+	fs := flag.NewFlagSet("export-parquet", flag.ContinueOnError)
+	out := fs.String("out", "parquet", "output directory")
+	layout := fs.String("layout", parquetLayoutLong, "table layout: long|wide")
+	compression := fs.String("compression", export.ParquetCompressionGzip, "page compression: gzip|none")
+	values := fs.String("values", comtrade.ValueSideRaw, "value side: primary|secondary|raw")
+	skew := fs.Bool("skew", false, "compensate channel skew")
+	step := fs.Int("step", 1, "export every N-th sample")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: comtradeviewer export-parquet [flags] [datasetId ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *layout != parquetLayoutLong && *layout != parquetLayoutWide {
+		fmt.Fprintf(os.Stderr, "invalid layout %q, expected long|wide\n", *layout)
+		return 2
+	}
+	if *compression != export.ParquetCompressionGzip && *compression != export.ParquetCompressionNone {
+		fmt.Fprintf(os.Stderr, "invalid compression %q, expected gzip|none\n", *compression)
+		return 2
+	}
+	if !comtrade.IsValidValueSide(*values) {
+		fmt.Fprintf(os.Stderr, "invalid value side %q, expected primary|secondary|raw\n", *values)
+		return 2
+	}
+	if *step < 1 {
+		fmt.Fprintln(os.Stderr, "step must be a positive integer")
+		return 2
+	}
+
+	ids, err := commandDatasetIDs(stor, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list datasets: %v\n", err)
+		return 1
+	}
+	if err := ensureDir(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "create output directory: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	opts := comtrade.AnalogOptions{Side: *values, CompensateSkew: *skew}
+	failed := 0
+	for _, id := range ids {
+		file := filepath.Join(*out, id+".parquet")
+		rows, err := exportDatasetParquet(ctx, stor, id, file, *layout, *compression, opts, *step)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			continue
+		}
+		fmt.Printf("%s: %d rows -> %s\n", id, rows, file)
+	}
+	fmt.Printf("exported %d of %d datasets\n", len(ids)-failed, len(ids))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// exportDatasetParquet 导出单个数据集的全部通道, 返回写出的行数; 失败时删除不完整的文件
+func exportDatasetParquet(ctx context.Context, stor storage.Storage, id, file, layout, compression string, opts comtrade.AnalogOptions, step int) (int, error) {
+	meta, dat, err := loadDataset(ctx, stor, id)
+	if err != nil {
+		return 0, err
+	}
+	if len(dat.Timestamps) == 0 {
+		return 0, fmt.Errorf("no samples")
+	}
+	table := newExportTable(meta, dat, opts)
+	for _, ch := range meta.AnalogChannels {
+		if err := table.addAnalog(dat, ch.ChannelNumber); err != nil {
+			return 0, err
+		}
+	}
+	for _, ch := range meta.DigitalChannels {
+		if err := table.addDigital(dat, ch.ChannelNumber); err != nil {
+			return 0, err
+		}
+	}
+	table.selectRows(0, len(table.times)-1, step)
+
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	err = writeParquetTable(f, table, id, layout, compression)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file)
+		return 0, err
+	}
+	rows := len(table.rows)
+	if layout == parquetLayoutLong {
+		rows *= len(table.columns)
+	}
+	return rows, nil
+}
+
+// runConvert 批量将数据集转换为指定的 COMTRADE 版本与数据文件格式, 写出到 <out>/<datasetId>/ 目录
+// 未指定数据集 ID 时转换存储中的所有数据集, 每个数据集输出一行精度损失摘要
+func runConvert(stor storage.Storage, args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	out := fs.String("out", "converted", "output directory")
+	revision := fs.String("revision", "1999", "COMTRADE revision: 1991|1999|2013")
+	format := fs.String("format", "binary", "data file type: ascii|binary|binary32|float32")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: comtradeviewer convert [flags] [datasetId ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	fileType := strings.ToLower(*format)
+	if err := comtrade.CheckConversion(*revision, fileType); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ids, err := commandDatasetIDs(stor, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "list datasets: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	failed := 0
+	for _, id := range ids {
+		report, dir, err := convertDatasetFiles(ctx, stor, id, *out, *revision, fileType)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			continue
+		}
+		maxErr := 0.0
+		for _, ch := range report.Channels {
+			maxErr = max(maxErr, ch.MaxError)
+		}
+		fmt.Printf("%s: %s/%s -> %s/%s lossless=%t maxError=%g -> %s\n", id,
+			report.FromRevision, report.FromDataFileType, report.Revision, report.DataFileType, report.Lossless, maxErr, dir)
+		for _, w := range report.Warnings {
+			fmt.Printf("  warning: %s\n", w)
+		}
+	}
+	fmt.Printf("converted %d of %d datasets\n", len(ids)-failed, len(ids))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// convertDatasetFiles 转换单个数据集并写出 CFG 与 DAT 文件, 返回转换报告与输出目录
+func convertDatasetFiles(ctx context.Context, stor storage.Storage, id, out, revision, fileType string) (*comtrade.ConversionReport, string, error) {
+	meta, dat, err := loadDataset(ctx, stor, id)
+	if err != nil {
+		return nil, "", err
+	}
+	files, err := convertDataset(meta, dat, revision, fileType)
+	if err != nil {
+		return nil, "", err
+	}
+	dir := filepath.Join(out, id)
+	if err := ensureDir(dir); err != nil {
+		return nil, "", err
+	}
+	base := datasetBaseName(ctx, stor, id)
+	if err := os.WriteFile(filepath.Join(dir, base+".cfg"), files.cfg, 0o644); err != nil {
+		return nil, "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, base+".dat"), files.dat, 0o644); err != nil {
+		return nil, "", err
+	}
+	return files.report, dir, nil
+}
@@ -0,0 +1,72 @@
+package comtrade
+
+import "math"
+
+const (
+	AlignAbsolute = "absolute" // 按各记录开始时刻的绝对时间对齐
+	AlignTrigger  = "trigger"  // 按各记录触发时刻对齐
+	AlignOffset   = "offset"   // 以各记录开始时刻为零点, 仅按用户给定偏移对齐
+)
+
+// IsValidAlignMode 判断对齐方式是否合法
+func IsValidAlignMode(mode string) bool {
+	return mode == AlignAbsolute || mode == AlignTrigger || mode == AlignOffset
+}
+
+// AlignmentShift 返回记录时间轴(相对记录开始的毫秒数)需要叠加的偏移量, 使其落到公共时间轴上
+//   - absolute: 公共零点为 reference(通常为所有记录中最早的开始时刻)
+//   - trigger:  公共零点为各记录自身的触发时刻
+//   - offset:   公共零点为各记录自身的开始时刻
+func AlignmentShift(meta *Metadata, mode string, reference *Metadata) float64 {
+	switch mode {
+	case AlignAbsolute:
+		if reference == nil {
+			return 0
+		}
+		return float64(meta.StartTime.Sub(reference.StartTime).Microseconds()) / 1000
+	case AlignTrigger:
+		return -float64(meta.EndTime.Sub(meta.StartTime).Microseconds()) / 1000
+	default:
+		return 0
+	}
+}
+
+// CrossCorrelationLag 在 [-maxLag, maxLag] 内搜索使 y 相对 ref 的归一化互相关最大的滞后采样数
+// 返回值 lag > 0 表示 y 滞后于 ref, 即 y[i+lag] 与 ref[i] 对应; 第二个返回值为相关系数
+func CrossCorrelationLag(ref, y []float64, maxLag int) (int, float64) {
+	n := min(len(ref), len(y))
+	if n == 0 {
+		return 0, 0
+	}
+	maxLag = max(0, min(maxLag, n-1))
+
+	mean := func(v []float64) float64 {
+		s := 0.0
+		for _, x := range v[:n] {
+			s += x
+		}
+		return s / float64(n)
+	}
+	mr, my := mean(ref), mean(y)
+
+	bestLag, best := 0, math.Inf(-1)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var sxy, sxx, syy float64
+		for i := max(0, -lag); i < n && i+lag < n; i++ {
+			a, b := ref[i]-mr, y[i+lag]-my
+			sxy += a * b
+			sxx += a * a
+			syy += b * b
+		}
+		if sxx == 0 || syy == 0 {
+			continue
+		}
+		if r := sxy / math.Sqrt(sxx*syy); r > best {
+			best, bestLag = r, lag
+		}
+	}
+	if math.IsInf(best, -1) {
+		return 0, 0
+	}
+	return bestLag, best
+}
@@ -0,0 +1,180 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultFrequency 额定频率缺省值(Hz), 当CFG中的lf无效时使用
+const DefaultFrequency = 50.0
+
+// AnalogChannelByNumber 按通道号查找模拟量通道配置
+func (meta *Metadata) AnalogChannelByNumber(channelNumber int) (AnalogChannel, bool) {
+	for _, ch := range meta.AnalogChannels {
+		if ch.ChannelNumber == channelNumber {
+			return ch, true
+		}
+	}
+	return AnalogChannel{}, false
+}
+
+// DigitalChannelByNumber 按通道号查找数字量通道配置
+func (meta *Metadata) DigitalChannelByNumber(channelNumber int) (DigitalChannel, bool) {
+	for _, ch := range meta.DigitalChannels {
+		if ch.ChannelNumber == channelNumber {
+			return ch, true
+		}
+	}
+	return DigitalChannel{}, false
+}
+
+// NominalFrequency 返回额定频率, lf缺失或非法时回退到 DefaultFrequency
+func (meta *Metadata) NominalFrequency() float64 {
+	if meta.Frequency <= 0 {
+		return DefaultFrequency
+	}
+	return meta.Frequency
+}
+
+// SampleRateAt 返回第 index 个采样点所在采样段的采样率(Hz)
+func (meta *Metadata) SampleRateAt(index int) float64 {
+	for _, sr := range meta.SampleRates {
+		if index < sr.LastSampleNum && sr.SampRate > 0 {
+			return sr.SampRate
+		}
+	}
+	if n := len(meta.SampleRates); n > 0 && meta.SampleRates[n-1].SampRate > 0 {
+		return meta.SampleRates[n-1].SampRate
+	}
+	return DefaultSampleRate
+}
+
+// ScaledAnalogData 返回模拟量通道按 a*x+b 换算后的采样值
+func ScaledAnalogData(meta *Metadata, dat *ChannelData, channelNumber int) ([]float64, error) {
+	ch, ok := meta.AnalogChannelByNumber(channelNumber)
+	if !ok {
+		return nil, fmt.Errorf("analog channel %d not found", channelNumber)
+	}
+	intData, floatData, err := dat.GetAnalogData(channelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(floatData) >= len(intData) {
+		y := make([]float64, len(floatData))
+		for i, d := range floatData {
+			y[i] = float64(d)*ch.Multiplier + ch.Offset
+		}
+		return y, nil
+	}
+	y := make([]float64, len(intData))
+	for i, d := range intData {
+		y[i] = float64(d)*ch.Multiplier + ch.Offset
+	}
+	return y, nil
+}
+
+const (
+	ValueSideRaw       = "raw"
+	ValueSidePrimary   = "primary"
+	ValueSideSecondary = "secondary"
+)
+
+// IsValidValueSide 判断 values 参数是否合法, 空串等同于 raw
+func IsValidValueSide(side string) bool {
+	switch side {
+	case "", ValueSideRaw, ValueSidePrimary, ValueSideSecondary:
+		return true
+	default:
+		return false
+	}
+}
+
+// SideFactor 返回将通道记录值换算到指定侧(一次/二次)的系数
+// PS 标志缺失、变比无效或 side 为 raw/空串时返回 1, 即保持记录侧的值
+func (ch AnalogChannel) SideFactor(side string) float64 {
+	if ch.Primary <= 0 || ch.Secondary <= 0 {
+		return 1
+	}
+	ratio := ch.Primary / ch.Secondary
+	recorded := strings.ToUpper(strings.TrimSpace(ch.PS))
+	switch {
+	case side == ValueSidePrimary && recorded == "S":
+		return ratio
+	case side == ValueSideSecondary && recorded == "P":
+		return 1 / ratio
+	default:
+		return 1
+	}
+}
+
+// ValueConversion 返回换算到指定侧所需的总系数及换算后的单位
+// 与前端一致: 经变比换算的一次值以 kV/kA 表示, 二次值以 V/A 表示;
+// 未实际换算(记录侧即目标侧、PS 标志缺失或变比无效)时保持 CFG 单位, 非电压/电流单位只做变比换算
+func (ch AnalogChannel) ValueConversion(side string) (float64, string) {
+	factor := ch.SideFactor(side)
+	exp, base := splitUnitPrefix(ch.Unit)
+	if factor == 1 || (!strings.EqualFold(base, "V") && !strings.EqualFold(base, "A")) {
+		return factor, ch.Unit
+	}
+	switch side {
+	case ValueSidePrimary:
+		return factor * math.Pow10(exp-3), "k" + base
+	case ValueSideSecondary:
+		return factor * math.Pow10(exp), base
+	default:
+		return factor, ch.Unit
+	}
+}
+
+// AnalogOptions 控制模拟量通道的取值方式
+//   - Side:           数值侧 raw|primary|secondary, 空串等同于 raw
+//   - CompensateSkew: 是否按通道 skew 将采样重新插值到公共时间网格
+type AnalogOptions struct {
+	Side           string
+	CompensateSkew bool
+}
+
+// AnalogValues 按 opts 返回模拟量通道的采样值及对应单位
+func AnalogValues(meta *Metadata, dat *ChannelData, channelNumber int, opts AnalogOptions) ([]float64, string, error) {
+	y, err := ScaledAnalogData(meta, dat, channelNumber)
+	if err != nil {
+		return nil, "", err
+	}
+	ch, _ := meta.AnalogChannelByNumber(channelNumber)
+	factor, unit := ch.ValueConversion(opts.Side)
+	if factor != 1 {
+		for i := range y {
+			y[i] *= factor
+		}
+	}
+	if opts.CompensateSkew && ch.Skew != 0 {
+		y = CompensateSkew(y, ch.Skew, meta.SampleRateAt(0))
+	}
+	return y, unit, nil
+}
+
+// UnitBaseFactor 返回将带前缀单位(kV/kA 等)换算为基本单位(V/A)的系数
+func UnitBaseFactor(unit string) float64 {
+	exp, _ := splitUnitPrefix(unit)
+	return math.Pow10(exp)
+}
+
+// TriggerIndex 返回触发时刻(CFG 第二个时间戳)在时间轴(毫秒)上对应的采样序号
+// 触发时刻早于记录开始时返回 0, 晚于记录结束时返回最后一个采样
+func (meta *Metadata) TriggerIndex(timestamps []float32) int {
+	if len(timestamps) == 0 {
+		return 0
+	}
+	offset := float32(meta.EndTime.Sub(meta.StartTime).Seconds() * 1000)
+	i := sort.Search(len(timestamps), func(i int) bool { return timestamps[i] >= offset })
+	return min(i, len(timestamps)-1)
+}
+
+// IsCurrent 判断通道单位是否为电流(A、kA 等)
+func (ch AnalogChannel) IsCurrent() bool {
+	_, base := splitUnitPrefix(ch.Unit)
+	return strings.EqualFold(base, "A")
+}
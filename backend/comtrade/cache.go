@@ -1,6 +1,7 @@
 package comtrade
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
@@ -31,6 +32,17 @@ func (dc *DatasetCache) Clear() {
 	dc.cache = make(map[string]*cacheEntry)
 }
 
+// Delete 移除数据集及其派生缓存(如 "id@rate/method" 形式的重采样结果)
+func (dc *DatasetCache) Delete(id string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	for k := range dc.cache {
+		if k == id || strings.HasPrefix(k, id+"@") {
+			delete(dc.cache, k)
+		}
+	}
+}
+
 func (dc *DatasetCache) Size() int {
 	dc.mu.RLock()
 	defer dc.mu.RUnlock()
@@ -29,6 +29,11 @@ type Metadata struct {
 	EndTime           time.Time        `json:"endTime"`
 	DataFileType      string           `json:"dataFileType"`
 	TimeMultiplier    float64          `json:"timeMultiplier"`
+	// 以下为 2013 版新增字段, 其他版本为空
+	TimeCode   string `json:"timeCode,omitempty"`
+	LocalCode  string `json:"localCode,omitempty"`
+	TmqCode    string `json:"tmqCode,omitempty"`
+	LeapSecond string `json:"leapSecond,omitempty"`
 }
 
 type AnalogChannel struct {
@@ -150,6 +155,8 @@ func parseStationLine(parser *cfgParser, line string) error {
 			parseEndTimeLine,
 			parseDataFileTypeLine,
 			parseTimeMultiplierLine,
+			parseTimeCodeLine,
+			parseTimeQualityLine,
 		)
 	default:
 		return fmt.Errorf("unsupported COMTRADE version: %s", parser.cfg.Version)
@@ -241,10 +248,11 @@ func parseAnalogChannelLine(parser *cfgParser, line string) error {
 	if err != nil {
 		return fmt.Errorf("invalid Analog Offset: %s", parts[6])
 	}
-	ch.Skew, err = strconv.ParseFloat(parts[7], 64)
-	if err != nil {
-		fmt.Printf("invalid Analog Skew: %s", parts[7])
-		ch.Skew = 0.0 // default to 0.0 if invalid
+	// skew 为可选字段(微秒), 留空或非法时按 0 处理
+	if parts[7] != "" {
+		if ch.Skew, err = strconv.ParseFloat(parts[7], 64); err != nil {
+			ch.Skew = 0.0
+		}
 	}
 	ch.MinValue, err = strconv.ParseFloat(parts[8], 64)
 	if err != nil {
@@ -468,6 +476,38 @@ func parseTimeMultiplierLine(parser *cfgParser, line string) error {
 	if err != nil {
 		return fmt.Errorf("invalid TimeMultiplier: %s", parts[0])
 	}
+	if parser.cfg.Version == "2013" {
+		parser.status++
+	} else {
+		parser.status = -1
+	}
+	return nil
+}
+
+/*
+time code line (2013): time_code,local_code
+example: +8h00,+8h00
+*/
+func parseTimeCodeLine(parser *cfgParser, line string) error {
+	parts := splitAndTrim(line, ",")
+	parser.cfg.TimeCode = parts[0]
+	if len(parts) >= 2 {
+		parser.cfg.LocalCode = parts[1]
+	}
+	parser.status++
+	return nil
+}
+
+/*
+time quality line (2013): tmq_code,leapsec
+example: 0,0
+*/
+func parseTimeQualityLine(parser *cfgParser, line string) error {
+	parts := splitAndTrim(line, ",")
+	parser.cfg.TmqCode = parts[0]
+	if len(parts) >= 2 {
+		parser.cfg.LeapSecond = parts[1]
+	}
 	parser.status = -1
 	return nil
 }
@@ -0,0 +1,232 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// COMName 按 IEEE C37.232 (COMNAME) 命名规则解析出的文件名字段
+// 文件名形如 "240315,142530123,+8h,StationA,DFR01,Company,Bay1.cfg", 以逗号分隔依次为:
+//   - 开始日期: yymmdd 或 yyyymmdd
+//   - 开始时间: hhmmss, 其后可直接跟小数秒位(如 142530123 为 14:25:30.123)
+//   - 时间代码: 相对 UTC 的偏移, 如 0、+8h、-5h30、Z
+//   - 站名、设备名、公司名, 以及任意个用户字段
+type COMName struct {
+	StartTime  time.Time `json:"startTime"`
+	TimeCode   string    `json:"timeCode"`
+	Station    string    `json:"station"`
+	Device     string    `json:"device"`
+	Company    string    `json:"company"`
+	UserFields []string  `json:"userFields"`
+
+	// precision 文件名中时间的分辨率, 由小数秒位数决定
+	precision time.Duration
+}
+
+// comNameFields 必需字段数: 日期、时间、时间代码、站名、设备名、公司名
+const comNameFields = 6
+
+// comNameTimeCodePattern 时间代码: 小时偏移, 可带分钟, 如 +8h、-5h30、-4
+var comNameTimeCodePattern = regexp.MustCompile(`^([+-]?)(\d{1,2})(?:h(\d{2})?)?$`)
+
+// ParseCOMName 解析符合 C37.232 命名规则的文件名(可带目录与扩展名), 不符合时返回错误
+func ParseCOMName(filename string) (*COMName, error) {
+	base := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	base = strings.TrimSuffix(base, path.Ext(base))
+	parts := strings.Split(base, ",")
+	if len(parts) < comNameFields {
+		return nil, fmt.Errorf("COMNAME requires at least %d comma-separated fields, got %d", comNameFields, len(parts))
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+		if i < comNameFields && parts[i] == "" {
+			return nil, fmt.Errorf("COMNAME field %d is empty", i+1)
+		}
+	}
+
+	offset, err := ParseTimeCode(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	zone := time.FixedZone(parts[2], int(offset/time.Second))
+	start, precision, err := parseCOMNameTime(parts[0], parts[1], zone)
+	if err != nil {
+		return nil, err
+	}
+	return &COMName{
+		StartTime:  start,
+		TimeCode:   parts[2],
+		Station:    parts[3],
+		Device:     parts[4],
+		Company:    parts[5],
+		UserFields: parts[comNameFields:],
+		precision:  precision,
+	}, nil
+}
+
+// ParseTimeCode 解析 C37.232 / 2013 版 CFG 的时间代码, 返回相对 UTC 的偏移
+func ParseTimeCode(code string) (time.Duration, error) {
+	code = strings.TrimSpace(code)
+	if strings.EqualFold(code, "z") {
+		return 0, nil
+	}
+	m := comNameTimeCodePattern.FindStringSubmatch(strings.ToLower(code))
+	if m == nil {
+		return 0, fmt.Errorf("invalid time code %q, expected e.g. 0, +8h, -5h30", code)
+	}
+	hours, _ := strconv.Atoi(m[2])
+	minutes := 0
+	if m[3] != "" {
+		minutes, _ = strconv.Atoi(m[3])
+	}
+	if hours > 14 || minutes >= 60 {
+		return 0, fmt.Errorf("time code %q out of range", code)
+	}
+	offset := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if m[1] == "-" {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// parseCOMNameTime 解析日期与时间字段, 两位年份按 70 划分世纪
+func parseCOMNameTime(date, clock string, zone *time.Location) (time.Time, time.Duration, error) {
+	if !isDigits(date) || (len(date) != 6 && len(date) != 8) {
+		return time.Time{}, 0, fmt.Errorf("invalid COMNAME date %q, expected yymmdd or yyyymmdd", date)
+	}
+	if len(date) == 6 {
+		if yy, _ := strconv.Atoi(date[:2]); yy >= 70 {
+			date = "19" + date
+		} else {
+			date = "20" + date
+		}
+	}
+	digits := strings.Replace(clock, ".", "", 1)
+	if !isDigits(digits) || len(digits) < 6 || len(digits) > 15 {
+		return time.Time{}, 0, fmt.Errorf("invalid COMNAME time %q, expected hhmmss with optional fraction digits", clock)
+	}
+	t, err := time.ParseInLocation("20060102150405", date+digits[:6], zone)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid COMNAME date/time %q,%q", date, clock)
+	}
+	frac := digits[6:]
+	precision := time.Second
+	if frac != "" {
+		scale := math.Pow10(9 - min(len(frac), 9))
+		ns, _ := strconv.ParseInt(frac[:min(len(frac), 9)], 10, 64)
+		t = t.Add(time.Duration(float64(ns) * scale))
+		precision = time.Duration(scale)
+	}
+	return t, precision, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// COMNameFromMetadata 由 CFG 的站名、设备名、开始时刻与时间代码生成命名字段
+func COMNameFromMetadata(meta *Metadata, company string, userFields ...string) *COMName {
+	code := meta.TimeCode
+	offset, err := ParseTimeCode(code)
+	if code == "" || err != nil {
+		code, offset = "0", 0
+	}
+	// CFG 中的时间为记录当地时间, 按时间代码标注时区
+	start := meta.StartTime
+	start = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(),
+		time.FixedZone(code, int(offset/time.Second)))
+	return &COMName{
+		StartTime:  start,
+		TimeCode:   code,
+		Station:    meta.Station,
+		Device:     meta.Relay,
+		Company:    company,
+		UserFields: userFields,
+	}
+}
+
+// FileName 按 C37.232 规则生成不含扩展名的文件名; 时间按需保留毫秒或微秒位,
+// 字段中的逗号与文件名非法字符替换为下划线, 空字段写为 "-"
+func (n *COMName) FileName() string {
+	t := n.StartTime
+	clock := t.Format("150405")
+	switch ns := t.Nanosecond(); {
+	case ns == 0:
+	case ns%int(time.Millisecond) == 0:
+		clock += fmt.Sprintf("%03d", ns/int(time.Millisecond))
+	default:
+		clock += fmt.Sprintf("%06d", ns/int(time.Microsecond))
+	}
+	fields := []string{t.Format("060102"), clock, comNameField(n.TimeCode), comNameField(n.Station), comNameField(n.Device), comNameField(n.Company)}
+	for _, f := range n.UserFields {
+		fields = append(fields, comNameField(f))
+	}
+	return strings.Join(fields, ",")
+}
+
+func comNameField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20:
+			return -1
+		case strings.ContainsRune(`,/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Mismatches 比较文件名字段与 CFG 内容, 返回不一致项的说明
+// 站名与设备名按文件名规则替换非法字符后比较, 忽略大小写, 空格与下划线视为相同; 开始时刻与 CFG 的开始时刻或触发时刻之一
+// 在文件名时间分辨率内一致即可, CFG 没有时间代码时按相同时区比较
+func (n *COMName) Mismatches(meta *Metadata) []string {
+	out := make([]string, 0)
+	if !sameCOMNameField(n.Station, meta.Station) {
+		out = append(out, fmt.Sprintf("文件名站名 %q 与 CFG 站名 %q 不一致", n.Station, meta.Station))
+	}
+	if !sameCOMNameField(n.Device, meta.Relay) {
+		out = append(out, fmt.Sprintf("文件名设备名 %q 与 CFG 录波器编号 %q 不一致", n.Device, meta.Relay))
+	}
+
+	name := n.StartTime
+	cfgOffset, err := ParseTimeCode(meta.TimeCode)
+	if meta.TimeCode == "" || err != nil {
+		// 没有时区信息: 只比较钟面时间
+		_, offset := name.Zone()
+		cfgOffset = time.Duration(offset) * time.Second
+	}
+	tolerance := n.precision
+	if tolerance <= 0 {
+		tolerance = time.Second
+	}
+	near := func(t time.Time) bool {
+		d := name.Sub(t.Add(-cfgOffset))
+		return d > -tolerance && d < tolerance
+	}
+	if !near(meta.StartTime) && !near(meta.EndTime) {
+		out = append(out, fmt.Sprintf("文件名时间 %s 与 CFG 开始时刻 %s、触发时刻 %s 均不一致",
+			name.Format("2006-01-02 15:04:05.999999 -07:00"), meta.StartTime.Format("2006-01-02 15:04:05.999999"), meta.EndTime.Format("2006-01-02 15:04:05.999999")))
+	}
+	return out
+}
+
+func sameCOMNameField(a, b string) bool {
+	norm := func(s string) string {
+		return strings.Join(strings.Fields(strings.ReplaceAll(comNameField(s), "_", " ")), " ")
+	}
+	return strings.EqualFold(norm(a), norm(b))
+}
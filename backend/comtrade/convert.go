@@ -0,0 +1,280 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// revisionFileTypes 各版本标准支持的数据文件格式
+var revisionFileTypes = map[string][]string{
+	"1991": {"ascii", "binary"},
+	"1999": {"ascii", "binary"},
+	"2013": {"ascii", "binary", "binary32", "float32"},
+}
+
+// fileTypeLimit 返回数据文件格式的模拟量码值范围 [-limit, limit] 及是否为整数格式
+// 各整数格式的最小值保留用于表示缺失数据; 不支持的格式返回 0
+func fileTypeLimit(fileType string) (float64, bool) {
+	switch fileType {
+	case "ascii":
+		return 99999, true
+	case "binary":
+		return 32767, true
+	case "binary32":
+		return math.MaxInt32, true
+	case "float32":
+		return math.MaxFloat32, false
+	}
+	return 0, false
+}
+
+// missingCode 返回整数格式表示缺失数据的码值(binary 为 0x8000, binary32 为 0x80000000),
+// ascii 与 float32 在本实现中没有缺失值码
+func missingCode(fileType string) (float64, bool) {
+	switch fileType {
+	case "binary":
+		return math.MinInt16, true
+	case "binary32":
+		return math.MinInt32, true
+	}
+	return 0, false
+}
+
+// CheckConversion 校验目标版本与数据文件格式的组合
+func CheckConversion(revision, fileType string) error {
+	types, ok := revisionFileTypes[revision]
+	if !ok {
+		return fmt.Errorf("unsupported revision %q, expected 1991|1999|2013", revision)
+	}
+	if !slices.Contains(types, fileType) {
+		return fmt.Errorf("revision %s does not support data file type %q, expected %v", revision, fileType, types)
+	}
+	return nil
+}
+
+// ChannelConversion 单个模拟量通道的转换结果
+//   - Rescaled:   是否重新计算了比例系数 a 与偏移 b
+//   - Resolution: 转换后一个码值对应的工程值, float32 格式为 0
+//   - MaxError:   转换前后工程值(a*x+b)的最大绝对误差, 不含缺失样本
+//   - Missing:    原记录中缺失样本(缺失值码或 NaN)的个数
+type ChannelConversion struct {
+	Channel    int     `json:"channel"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	Multiplier float64 `json:"multiplier"`
+	Offset     float64 `json:"offset"`
+	Rescaled   bool    `json:"rescaled"`
+	Resolution float64 `json:"resolution"`
+	MaxError   float64 `json:"maxError"`
+	Lossless   bool    `json:"lossless"`
+	Missing    int     `json:"missing"`
+}
+
+// ConversionReport 转换报告; MaxTimestampError 为时间戳的最大舍入误差(微秒)
+type ConversionReport struct {
+	FromRevision      string              `json:"fromRevision"`
+	FromDataFileType  string              `json:"fromDataFileType"`
+	Revision          string              `json:"revision"`
+	DataFileType      string              `json:"dataFileType"`
+	Lossless          bool                `json:"lossless"`
+	MaxTimestampError float64             `json:"maxTimestampError"`
+	Channels          []ChannelConversion `json:"channels"`
+	Warnings          []string            `json:"warnings"`
+}
+
+// Convert 将记录转换为指定版本与数据文件格式, 返回新的配置与数据, 原记录不变
+// 记录值为浮点数或超出目标整数格式范围时, 按通道的实际取值范围重新计算 a、b 以用满码值范围,
+// 码值本身为整数且在范围内时保持不变(无损); 原格式的缺失值码不参与范围计算, 写为目标格式的缺失值码
+func Convert(meta *Metadata, dat *ChannelData, revision, fileType string) (*Metadata, *ChannelData, *ConversionReport, error) {
+	if err := CheckConversion(revision, fileType); err != nil {
+		return nil, nil, nil, err
+	}
+	n := len(dat.Timestamps)
+	out := *meta
+	out.Version = revision
+	out.DataFileType = fileType
+	out.AnalogChannels = slices.Clone(meta.AnalogChannels)
+	out.DigitalChannels = slices.Clone(meta.DigitalChannels)
+	out.SampleRates = slices.Clone(meta.SampleRates)
+	out.TotalChannelNum = len(meta.AnalogChannels) + len(meta.DigitalChannels)
+	out.AnalogChannelNum = len(meta.AnalogChannels)
+	out.DigitalChannelNum = len(meta.DigitalChannels)
+	out.RatesNum = len(meta.SampleRates)
+
+	report := &ConversionReport{
+		FromRevision:     meta.Version,
+		FromDataFileType: meta.DataFileType,
+		Revision:         revision,
+		DataFileType:     fileType,
+		Channels:         make([]ChannelConversion, 0, len(meta.AnalogChannels)),
+		Warnings:         make([]string, 0),
+	}
+	result := &ChannelData{
+		Timestamps:      slices.Clone(dat.Timestamps),
+		AnalogChannels:  make([]AnalogChannelData, 0, len(meta.AnalogChannels)),
+		DigitalChannels: make([]DigitalChannelData, 0, len(meta.DigitalChannels)),
+	}
+
+	// 1991 版没有时间戳倍率, 时间戳换算为微秒
+	if revision == "1991" && meta.TimeMultiplier > 0 && meta.TimeMultiplier != 1 {
+		for i, ts := range dat.Timestamps {
+			exact := float64(ts) * meta.TimeMultiplier
+			if math.Abs(exact) > math.MaxInt32 {
+				return nil, nil, nil, fmt.Errorf("timestamp %v us exceeds the 1991 range", exact)
+			}
+			result.Timestamps[i] = int32(math.Round(exact))
+			report.MaxTimestampError = math.Max(report.MaxTimestampError, math.Abs(exact-math.Round(exact)))
+		}
+		out.TimeMultiplier = 1
+	}
+	if out.TimeMultiplier <= 0 {
+		out.TimeMultiplier = 1
+	}
+
+	if revision == "1991" {
+		for _, ch := range meta.AnalogChannels {
+			if ch.Primary != 1 || ch.Secondary != 1 || ch.PS != "" {
+				report.Warnings = append(report.Warnings, "1991 版不含一次/二次变比, 变比信息已丢弃, 数值保持原记录侧")
+				break
+			}
+		}
+		for _, ch := range meta.DigitalChannels {
+			if ch.Phase != "" || ch.CCBM != "" {
+				report.Warnings = append(report.Warnings, "1991 版开关量不含相别与被监视元件字段, 已丢弃")
+				break
+			}
+		}
+	}
+	if revision != "2013" {
+		out.TimeCode, out.LocalCode, out.TmqCode, out.LeapSecond = "", "", "", ""
+	} else if meta.Version != "2013" {
+		report.Warnings = append(report.Warnings, "原记录没有时区与时间质量信息, 2013 版对应字段写为 0")
+	}
+
+	limit, integer := fileTypeLimit(fileType)
+	srcMissing, srcHasMissing := missingCode(meta.DataFileType)
+	fill, hasMissing := missingCode(fileType)
+	missingTotal := 0
+	columns := analogColumns(meta, dat, n)
+	for k, ch := range meta.AnalogChannels {
+		raw := columns[k]
+		conv := ChannelConversion{Channel: ch.ChannelNumber, Name: ch.ChannelName, Unit: ch.Unit, Multiplier: ch.Multiplier, Offset: ch.Offset}
+		data := AnalogChannelData{ChannelNumber: ch.ChannelNumber}
+
+		// 缺失样本标记为 NaN, 不参与范围与误差计算
+		for i, x := range raw {
+			if math.IsNaN(x) || srcHasMissing && x == srcMissing {
+				raw[i] = math.NaN()
+				conv.Missing++
+			}
+		}
+
+		if !integer {
+			data.RawDataFloat = make([]float32, n)
+			for i, x := range raw {
+				data.RawDataFloat[i] = float32(x)
+				if !math.IsNaN(x) {
+					conv.MaxError = math.Max(conv.MaxError, math.Abs((float64(float32(x))-x)*ch.Multiplier))
+				}
+			}
+		} else if fitsIntegerCodes(raw, limit) {
+			data.RawData = make([]int32, n)
+			for i, x := range raw {
+				if !math.IsNaN(x) {
+					data.RawData[i] = int32(x)
+				}
+			}
+			conv.Resolution = math.Abs(ch.Multiplier)
+			if ch.MinValue < -limit || ch.MaxValue > limit {
+				out.AnalogChannels[k].MinValue, out.AnalogChannels[k].MaxValue = -limit, limit
+			}
+		} else {
+			data.RawData = requantize(raw, ch, limit, &conv)
+			out.AnalogChannels[k].Multiplier, out.AnalogChannels[k].Offset = conv.Multiplier, conv.Offset
+			out.AnalogChannels[k].MinValue, out.AnalogChannels[k].MaxValue = -limit, limit
+		}
+		for i, x := range raw {
+			if !math.IsNaN(x) {
+				continue
+			}
+			if integer {
+				data.RawData[i] = int32(fill)
+			} else {
+				data.RawDataFloat[i] = float32(fill)
+			}
+		}
+		missingTotal += conv.Missing
+		conv.Lossless = conv.MaxError == 0 && (conv.Missing == 0 || hasMissing)
+		report.Channels = append(report.Channels, conv)
+		result.AnalogChannels = append(result.AnalogChannels, data)
+	}
+	for k, col := range digitalColumns(meta, dat, n) {
+		result.DigitalChannels = append(result.DigitalChannels, DigitalChannelData{ChannelNumber: meta.DigitalChannels[k].ChannelNumber, RawData: col})
+	}
+
+	if missingTotal > 0 && !hasMissing {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s 格式没有缺失值码, %d 个缺失样本已写为码值 0", fileType, missingTotal))
+	}
+
+	report.Lossless = report.MaxTimestampError == 0
+	for _, c := range report.Channels {
+		report.Lossless = report.Lossless && c.Lossless
+	}
+	return &out, result, report, nil
+}
+
+// fitsIntegerCodes 判断码值是否均为 [-limit, limit] 内的整数, 缺失样本(NaN)除外
+func fitsIntegerCodes(raw []float64, limit float64) bool {
+	for _, x := range raw {
+		if math.IsNaN(x) {
+			continue
+		}
+		if x != math.Trunc(x) || math.Abs(x) > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// requantize 按工程值的实际范围重新计算 a、b, 使最小值与最大值分别对应 -limit 与 limit,
+// 在目标位数下得到最高分辨率; 返回新的码值并在 conv 中记录系数与最大误差
+// 缺失样本(NaN)不参与范围计算, 其码值由调用方填写
+func requantize(raw []float64, ch AnalogChannel, limit float64, conv *ChannelConversion) []int32 {
+	y := make([]float64, len(raw))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, x := range raw {
+		y[i] = x*ch.Multiplier + ch.Offset
+		if !math.IsNaN(x) {
+			lo, hi = math.Min(lo, y[i]), math.Max(hi, y[i])
+		}
+	}
+	conv.Rescaled = true
+	codes := make([]int32, len(raw))
+	if lo >= hi {
+		// 常数通道: 码值全为 0, 工程值全部由偏移表示
+		conv.Multiplier = ch.Multiplier
+		if conv.Multiplier == 0 {
+			conv.Multiplier = 1
+		}
+		conv.Offset = 0
+		if lo == hi {
+			conv.Offset = lo
+		}
+		conv.Resolution = math.Abs(conv.Multiplier)
+		return codes
+	}
+
+	conv.Multiplier = (hi - lo) / (2 * limit)
+	conv.Offset = (hi + lo) / 2
+	conv.Resolution = conv.Multiplier
+	for i, v := range y {
+		if math.IsNaN(v) {
+			continue
+		}
+		x := math.Max(-limit, math.Min(limit, math.Round((v-conv.Offset)/conv.Multiplier)))
+		codes[i] = int32(x)
+		conv.MaxError = math.Max(conv.MaxError, math.Abs(x*conv.Multiplier+conv.Offset-v))
+	}
+	return codes
+}
@@ -0,0 +1,187 @@
+package comtrade
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+const (
+	DetectorRMSStep   = "rmsstep"   // 一周波有效值相对一个周波前的阶跃变化
+	DetectorDelta     = "delta"     // 瞬时值一周波突变量 |y(k) - y(k-N)|
+	DetectorThreshold = "threshold" // 一周波有效值越上限/下限
+	DetectorDigital   = "digital"   // 开关量变位
+)
+
+// DisturbanceDetectors 全部检测器, 顺序即缺省启用顺序
+var DisturbanceDetectors = []string{DetectorRMSStep, DetectorDelta, DetectorThreshold, DetectorDigital}
+
+// DetectorSettings 扰动检测参数
+//   - RMSStep: 有效值阶跃的相对门槛(相对一个周波前的有效值), 例如 0.1 表示 10%
+//   - Delta:   突变量门槛, 相对通道典型峰值(一周波有效值中位数的 √2 倍)
+//   - Over:    有效值上限(通道单位), <=0 表示不启用
+//   - Under:   有效值下限(通道单位), <=0 表示不启用; 仅在记录中位有效值高于下限时检测, 以免对空通道误报
+//   - MergeGap: 间隔不超过该采样数的检出区段合并为一个窗口
+type DetectorSettings struct {
+	RMSStep  float64
+	Delta    float64
+	Over     float64
+	Under    float64
+	MergeGap int
+}
+
+// DefaultDetectorSettings 缺省检测参数, MergeGap 为 0 时按一个周波处理
+var DefaultDetectorSettings = DetectorSettings{RMSStep: 0.1, Delta: 0.2}
+
+// DisturbanceWindow 单个检测器在单个通道上检出的候选窗口
+//   - Start/End: 采样序号区间(闭区间)
+//   - Peak:      指标最大的采样序号
+//   - Severity:  指标峰值与门槛之比, >= 1
+type DisturbanceWindow struct {
+	Detector string  `json:"detector"`
+	Type     string  `json:"type"`
+	Channel  int     `json:"channel"`
+	Start    int     `json:"start"`
+	End      int     `json:"end"`
+	Peak     int     `json:"peak"`
+	Severity float64 `json:"severity"`
+}
+
+// maskWindows 将指标超过门槛的区段转换为窗口, 相距不超过 gap 的区段合并
+func maskWindows(metric []float64, threshold float64, gap int, detector, typ string, channel int) []DisturbanceWindow {
+	out := make([]DisturbanceWindow, 0)
+	var cur *DisturbanceWindow
+	for i, v := range metric {
+		if v < threshold || math.IsNaN(v) {
+			continue
+		}
+		severity := v / threshold
+		if cur != nil && i-cur.End <= gap+1 {
+			cur.End = i
+			if severity > cur.Severity {
+				cur.Severity, cur.Peak = severity, i
+			}
+			continue
+		}
+		out = append(out, DisturbanceWindow{Detector: detector, Type: typ, Channel: channel, Start: i, End: i, Peak: i, Severity: severity})
+		cur = &out[len(out)-1]
+	}
+	return out
+}
+
+// median 返回切片中位数(不修改输入)
+func median(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	s := slices.Clone(v)
+	sort.Float64s(s)
+	return s[len(s)/2]
+}
+
+// DetectAnalogDisturbances 对一个模拟量通道运行指定的检测器
+func DetectAnalogDisturbances(y []float64, channel int, fs, f float64, detectors []string, settings DetectorSettings) []DisturbanceWindow {
+	out := make([]DisturbanceWindow, 0)
+	cycle := int(math.Round(fs / f))
+	if cycle < 2 || len(y) < 2*cycle {
+		return out
+	}
+	gap := settings.MergeGap
+	if gap <= 0 {
+		gap = cycle
+	}
+
+	rms := SlidingRMS(y, fs, f)
+	typical := median(rms)
+
+	if slices.Contains(detectors, DetectorRMSStep) && settings.RMSStep > 0 {
+		metric := make([]float64, len(y))
+		floor := math.Max(typical*0.05, 1e-12)
+		for i := 2 * cycle; i < len(y); i++ {
+			before := rms[i-cycle]
+			metric[i] = math.Abs(rms[i]-before) / math.Max(before, floor)
+		}
+		out = append(out, maskWindows(metric, settings.RMSStep, gap, DetectorRMSStep, "step", channel)...)
+	}
+
+	if slices.Contains(detectors, DetectorDelta) && settings.Delta > 0 && typical > 0 {
+		metric := make([]float64, len(y))
+		peak := typical * math.Sqrt2
+		for i := cycle; i < len(y); i++ {
+			metric[i] = math.Abs(y[i]-y[i-cycle]) / peak
+		}
+		out = append(out, maskWindows(metric, settings.Delta, gap, DetectorDelta, "delta", channel)...)
+	}
+
+	if slices.Contains(detectors, DetectorThreshold) {
+		if settings.Over > 0 {
+			out = append(out, maskWindows(rms, settings.Over, gap, DetectorThreshold, "over", channel)...)
+		}
+		if settings.Under > 0 && typical > settings.Under {
+			metric := make([]float64, len(y))
+			for i, v := range rms {
+				metric[i] = settings.Under / math.Max(v, settings.Under*1e-3)
+			}
+			out = append(out, maskWindows(metric, 1, gap, DetectorThreshold, "under", channel)...)
+		}
+	}
+	return out
+}
+
+// DetectDigitalDisturbances 将开关量通道的每次变位作为候选窗口, 严重程度固定为 1
+func DetectDigitalDisturbances(y []int8, channel int) []DisturbanceWindow {
+	out := make([]DisturbanceWindow, 0)
+	for i := 1; i < len(y); i++ {
+		if y[i] == y[i-1] {
+			continue
+		}
+		typ := "rise"
+		if y[i] < y[i-1] {
+			typ = "fall"
+		}
+		out = append(out, DisturbanceWindow{Detector: DetectorDigital, Type: typ, Channel: channel, Start: i, End: i, Peak: i, Severity: 1})
+	}
+	return out
+}
+
+// DisturbanceEvent 由多个通道/检测器的重叠窗口合并得到的扰动事件
+type DisturbanceEvent struct {
+	Start     int                 `json:"start"`
+	End       int                 `json:"end"`
+	Peak      int                 `json:"peak"`
+	Severity  float64             `json:"severity"`
+	Detectors []string            `json:"detectors"`
+	Windows   []DisturbanceWindow `json:"windows"`
+}
+
+// MergeDisturbances 按时间合并各通道的候选窗口, 间隔不超过 gap 的窗口归为同一事件,
+// 事件严重程度取其中最大者, 结果按起始时间排序
+func MergeDisturbances(windows []DisturbanceWindow, gap int) []DisturbanceEvent {
+	sorted := slices.Clone(windows)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	out := make([]DisturbanceEvent, 0)
+	for _, w := range sorted {
+		if n := len(out); n > 0 && w.Start-out[n-1].End <= gap {
+			ev := &out[n-1]
+			ev.End = max(ev.End, w.End)
+			if w.Severity > ev.Severity {
+				ev.Severity, ev.Peak = w.Severity, w.Peak
+			}
+			if !slices.Contains(ev.Detectors, w.Detector) {
+				ev.Detectors = append(ev.Detectors, w.Detector)
+			}
+			ev.Windows = append(ev.Windows, w)
+			continue
+		}
+		out = append(out, DisturbanceEvent{
+			Start:     w.Start,
+			End:       w.End,
+			Peak:      w.Peak,
+			Severity:  w.Severity,
+			Detectors: []string{w.Detector},
+			Windows:   []DisturbanceWindow{w},
+		})
+	}
+	return out
+}
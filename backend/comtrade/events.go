@@ -0,0 +1,72 @@
+package comtrade
+
+import (
+	"sort"
+	"time"
+)
+
+// DigitalEvent 开关量变位事件(SOE)
+//   - Time:        相对记录开始的时间(毫秒)
+//   - TriggerTime: 相对触发时刻的时间(毫秒), 触发前为负
+//   - Timestamp:   绝对时间, 由 StartTime 加上时间轴得到
+//   - Abnormal:    变位后的状态是否偏离通道正常状态 DigitalChannel.Y
+type DigitalEvent struct {
+	Channel     int       `json:"channel"`
+	Name        string    `json:"name"`
+	Index       int       `json:"index"`
+	Time        float64   `json:"time"`
+	TriggerTime float64   `json:"triggerTime"`
+	Timestamp   time.Time `json:"timestamp"`
+	OldState    int8      `json:"oldState"`
+	NewState    int8      `json:"newState"`
+	NormalState int       `json:"normalState"`
+	Abnormal    bool      `json:"abnormal"`
+}
+
+// DigitalEvents 提取指定开关量通道(为空时为全部通道)的所有变位, 按时间先后排序
+// timestamps 为 ComputeTimeAxisFromMeta 得到的毫秒时间轴
+func DigitalEvents(meta *Metadata, dat *ChannelData, channels []int, timestamps []float32) []DigitalEvent {
+	wanted := make(map[int]bool, len(channels))
+	for _, ch := range channels {
+		wanted[ch] = true
+	}
+	triggerOffset := meta.EndTime.Sub(meta.StartTime).Seconds() * 1000
+
+	events := make([]DigitalEvent, 0)
+	for _, ch := range meta.DigitalChannels {
+		if len(wanted) > 0 && !wanted[ch.ChannelNumber] {
+			continue
+		}
+		y, err := dat.GetDigitalData(ch.ChannelNumber)
+		if err != nil {
+			continue
+		}
+		n := min(len(y), len(timestamps))
+		for i := 1; i < n; i++ {
+			if y[i] == y[i-1] {
+				continue
+			}
+			ms := float64(timestamps[i])
+			events = append(events, DigitalEvent{
+				Channel:     ch.ChannelNumber,
+				Name:        ch.ChannelName,
+				Index:       i,
+				Time:        ms,
+				TriggerTime: ms - triggerOffset,
+				Timestamp:   meta.StartTime.Add(time.Duration(ms * float64(time.Millisecond))),
+				OldState:    y[i-1],
+				NewState:    y[i],
+				NormalState: ch.Y,
+				Abnormal:    int(y[i]) != ch.Y,
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Index != events[j].Index {
+			return events[i].Index < events[j].Index
+		}
+		return events[i].Channel < events[j].Channel
+	})
+	return events
+}
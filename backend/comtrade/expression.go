@@ -0,0 +1,606 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+派生通道表达式, 对整条记录逐点求值, 结果与原始通道等长
+
+通道引用:
+  - A1、D3:        按编号引用模拟量/开关量通道
+  - Ia、Ua:        按名称引用(名称只含字母、数字、下划线时)
+  - [保护电流A相]: 方括号内为任意通道名称
+  - 模拟量取值遵循 AnalogOptions(一次/二次、skew 补偿), 开关量取值为 0/1
+
+运算符(优先级由低到高): ||  &&  比较(> < >= <= == !=)  + -  * /  一元 - !  ^
+函数:
+  - 数学: abs sqrt exp log log10 sin cos tan floor ceil round sign pow(x,y) min(...) max(...) if(c,a,b)
+  - 周波量: rms(x) 一周波有效值, mag(x) 基波幅值(有效值), ang(x) 基波相角(度)
+常量: pi e
+逻辑运算结果为 0/1, 非零即为真
+*/
+
+// Expression 已解析的派生通道表达式
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// ParseExpression 解析表达式文本
+func ParseExpression(src string) (*Expression, error) {
+	tokens, err := tokenizeExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected token %q at position %d", p.peek().text, p.peek().pos)
+	}
+	return &Expression{source: src, root: root}, nil
+}
+
+// String 返回表达式原文
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Evaluate 对整条记录逐点求值
+func (e *Expression) Evaluate(meta *Metadata, dat *ChannelData, opts AnalogOptions) ([]float64, error) {
+	ctx := &exprContext{
+		meta:  meta,
+		dat:   dat,
+		opts:  opts,
+		n:     len(dat.Timestamps),
+		fs:    meta.SampleRateAt(0),
+		f:     meta.NominalFrequency(),
+		cache: make(map[string][]float64),
+	}
+	if ctx.n == 0 {
+		return nil, fmt.Errorf("dataset has no samples")
+	}
+	return e.root.eval(ctx)
+}
+
+// ---- 求值 ----
+
+type exprContext struct {
+	meta  *Metadata
+	dat   *ChannelData
+	opts  AnalogOptions
+	n     int
+	fs    float64
+	f     float64
+	cache map[string][]float64 // 按解析后的通道("A1"/"D3")缓存, 同一通道的编号与名称引用共用
+}
+
+var channelNumberPattern = regexp.MustCompile(`^([AaDd])(\d+)$`)
+
+// channel 解析通道引用并返回与记录等长的采样值
+func (ctx *exprContext) channel(ref string, bracketed bool) ([]float64, error) {
+	analog, digital := -1, -1
+	if m := channelNumberPattern.FindStringSubmatch(ref); m != nil && !bracketed {
+		num, _ := strconv.Atoi(m[2])
+		if strings.EqualFold(m[1], "A") {
+			analog = num
+		} else {
+			digital = num
+		}
+	} else {
+		for _, ch := range ctx.meta.AnalogChannels {
+			if ch.ChannelName == ref {
+				analog = ch.ChannelNumber
+				break
+			}
+		}
+		if analog < 0 {
+			for _, ch := range ctx.meta.DigitalChannels {
+				if ch.ChannelName == ref {
+					digital = ch.ChannelNumber
+					break
+				}
+			}
+		}
+	}
+
+	// [A1] 为名称是 "A1" 的通道, 与编号引用 A1 不一定是同一通道, 不能按引用文本缓存
+	key := fmt.Sprintf("A%d", analog)
+	if analog < 0 {
+		key = fmt.Sprintf("D%d", digital)
+	}
+	if v, ok := ctx.cache[key]; ok {
+		return v, nil
+	}
+
+	var out []float64
+	switch {
+	case analog >= 0:
+		y, _, err := AnalogValues(ctx.meta, ctx.dat, analog, ctx.opts)
+		if err != nil {
+			return nil, err
+		}
+		out = y
+	case digital >= 0:
+		d, err := ctx.dat.GetDigitalData(digital)
+		if err != nil {
+			return nil, err
+		}
+		out = make([]float64, len(d))
+		for i, v := range d {
+			out[i] = float64(v)
+		}
+	default:
+		return nil, fmt.Errorf("unknown channel %q", ref)
+	}
+
+	// 对齐到记录长度
+	if len(out) != ctx.n {
+		aligned := make([]float64, ctx.n)
+		copy(aligned, out)
+		out = aligned
+	}
+	ctx.cache[key] = out
+	return out, nil
+}
+
+func (ctx *exprContext) constant(v float64) []float64 {
+	out := make([]float64, ctx.n)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
+
+type exprNode interface {
+	eval(ctx *exprContext) ([]float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(ctx *exprContext) ([]float64, error) {
+	return ctx.constant(float64(n)), nil
+}
+
+type channelNode struct {
+	ref       string
+	bracketed bool
+}
+
+func (c channelNode) eval(ctx *exprContext) ([]float64, error) {
+	return ctx.channel(c.ref, c.bracketed)
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (u unaryNode) eval(ctx *exprContext) ([]float64, error) {
+	x, err := u.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, len(x))
+	for i, v := range x {
+		if u.op == "-" {
+			out[i] = -v
+		} else {
+			out[i] = boolValue(v == 0)
+		}
+	}
+	return out, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (b binaryNode) eval(ctx *exprContext) ([]float64, error) {
+	l, err := b.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, len(l))
+	for i := range out {
+		x, y := l[i], r[i]
+		switch b.op {
+		case "+":
+			out[i] = x + y
+		case "-":
+			out[i] = x - y
+		case "*":
+			out[i] = x * y
+		case "/":
+			out[i] = x / y
+		case "^":
+			out[i] = math.Pow(x, y)
+		case ">":
+			out[i] = boolValue(x > y)
+		case "<":
+			out[i] = boolValue(x < y)
+		case ">=":
+			out[i] = boolValue(x >= y)
+		case "<=":
+			out[i] = boolValue(x <= y)
+		case "==":
+			out[i] = boolValue(x == y)
+		case "!=":
+			out[i] = boolValue(x != y)
+		case "&&":
+			out[i] = boolValue(x != 0 && y != 0)
+		case "||":
+			out[i] = boolValue(x != 0 || y != 0)
+		}
+	}
+	return out, nil
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+// exprFunctions 逐点函数, 参数个数为 -1 表示可变(至少 1 个)
+var exprFunctions = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"sign": {1, func(a []float64) float64 {
+		switch {
+		case a[0] > 0:
+			return 1
+		case a[0] < 0:
+			return -1
+		default:
+			return 0
+		}
+	}},
+	"pow": {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"if": {3, func(a []float64) float64 {
+		if a[0] != 0 {
+			return a[1]
+		}
+		return a[2]
+	}},
+	"min": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+// exprWindowFunctions 基于一个周波窗口的函数, 参数为整条序列
+var exprWindowFunctions = map[string]func(ctx *exprContext, x []float64) []float64{
+	"rms": func(ctx *exprContext, x []float64) []float64 {
+		return SlidingRMS(x, ctx.fs, ctx.f)
+	},
+	"mag": func(ctx *exprContext, x []float64) []float64 {
+		p := SlidingPhasors(x, ctx.fs, ctx.f)
+		out := make([]float64, len(p))
+		for i, v := range p {
+			out[i] = cmplx.Abs(v)
+		}
+		return out
+	},
+	"ang": func(ctx *exprContext, x []float64) []float64 {
+		p := SlidingPhasors(x, ctx.fs, ctx.f)
+		out := make([]float64, len(p))
+		for i, v := range p {
+			out[i] = PhasorAngleDegrees(v)
+		}
+		return out
+	},
+}
+
+func (c callNode) eval(ctx *exprContext) ([]float64, error) {
+	args := make([][]float64, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	if wf, ok := exprWindowFunctions[c.name]; ok {
+		return wf(ctx, args[0]), nil
+	}
+
+	fn := exprFunctions[c.name]
+	out := make([]float64, ctx.n)
+	point := make([]float64, len(args))
+	for i := range out {
+		for k := range args {
+			point[k] = args[k][i]
+		}
+		out[i] = fn.fn(point)
+	}
+	return out, nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ---- 词法与语法分析 ----
+
+type exprToken struct {
+	kind string // num, ident, name, op
+	text string
+	pos  int
+}
+
+var exprOperators = []string{"&&", "||", ">=", "<=", "==", "!=", "+", "-", "*", "/", "^", "(", ")", ",", ">", "<", "!"}
+
+func tokenizeExpression(src string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// 科学计数法
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+					}
+				}
+			}
+			tokens = append(tokens, exprToken{kind: "num", text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: "ident", text: string(runes[start:i]), pos: start})
+		case r == '[':
+			start := i
+			end := start + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated channel name at position %d", start)
+			}
+			tokens = append(tokens, exprToken{kind: "name", text: strings.TrimSpace(string(runes[start+1 : end])), pos: start})
+			i = end + 1
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, exprToken{kind: "op", text: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.done() {
+		return exprToken{kind: "eof", pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseBinary(next func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseCompare, "&&")
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	return p.parseBinary(p.parseAdd, ">=", "<=", "==", "!=", ">", "<")
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	return p.parseBinary(p.parseMul, "+", "-")
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.acceptOp("-", "+", "!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return operand, nil
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *exprParser) parsePower() (exprNode, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.acceptOp("^"); ok {
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op: "^", left: base, right: exponent}, nil
+	}
+	return base, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case "eof":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "num":
+		p.pos++
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return numberNode(v), nil
+	case "name":
+		p.pos++
+		return channelNode{ref: t.text, bracketed: true}, nil
+	case "ident":
+		p.pos++
+		if _, ok := p.acceptOp("("); ok {
+			return p.parseCall(t)
+		}
+		switch t.text {
+		case "pi":
+			return numberNode(math.Pi), nil
+		case "e":
+			return numberNode(math.E), nil
+		}
+		return channelNode{ref: t.text}, nil
+	case "op":
+		if t.text == "(" {
+			p.pos++
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.acceptOp(")"); !ok {
+				return nil, fmt.Errorf("missing closing parenthesis for position %d", t.pos)
+			}
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected token %q at position %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	args := make([]exprNode, 0)
+	if _, ok := p.acceptOp(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.acceptOp(","); ok {
+				continue
+			}
+			if _, ok := p.acceptOp(")"); ok {
+				break
+			}
+			return nil, fmt.Errorf("expected ',' or ')' in call to %s", name.text)
+		}
+	}
+
+	if _, ok := exprWindowFunctions[name.text]; ok {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("function %s expects 1 argument, got %d", name.text, len(args))
+		}
+		return callNode{name: name.text, args: args}, nil
+	}
+	fn, ok := exprFunctions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+	if (fn.arity < 0 && len(args) == 0) || (fn.arity >= 0 && len(args) != fn.arity) {
+		return nil, fmt.Errorf("function %s: wrong number of arguments (%d)", name.text, len(args))
+	}
+	return callNode{name: name.text, args: args}, nil
+}
@@ -0,0 +1,115 @@
+package comtrade
+
+import (
+	"fmt"
+	"math/cmplx"
+)
+
+// FaultLoops 故障回路, 顺序即输出顺序
+var FaultLoops = []string{"AG", "BG", "CG", "AB", "BC", "CA", "ABC"}
+
+// LineParams 线路参数, 阻抗为一次侧每公里欧姆值
+type LineParams struct {
+	R1     float64 `json:"r1"`
+	X1     float64 `json:"x1"`
+	R0     float64 `json:"r0"`
+	X0     float64 `json:"x0"`
+	Length float64 `json:"length"`
+}
+
+// Z1 正序单位长度阻抗
+func (lp LineParams) Z1() complex128 {
+	return complex(lp.R1, lp.X1)
+}
+
+// K0 零序补偿系数 k0 = (Z0 - Z1) / 3Z1
+func (lp LineParams) K0() complex128 {
+	z1 := lp.Z1()
+	return (complex(lp.R0, lp.X0) - z1) / (3 * z1)
+}
+
+// Validate 检查线路参数
+func (lp LineParams) Validate() error {
+	if lp.X1 <= 0 || lp.Length <= 0 {
+		return fmt.Errorf("invalid line parameters: x1 and length must be positive")
+	}
+	return nil
+}
+
+// LoopQuantities 返回某故障回路在第 j 点的回路电压与(零序补偿后的)回路电流
+func LoopQuantities(loop string, v, i ThreePhasePhasors, k0 complex128, j int) (complex128, complex128) {
+	in := i[0][j] + i[1][j] + i[2][j]
+	switch loop {
+	case "AG":
+		return v[0][j], i[0][j] + k0*in
+	case "BG":
+		return v[1][j], i[1][j] + k0*in
+	case "CG":
+		return v[2][j], i[2][j] + k0*in
+	case "AB":
+		return v[0][j] - v[1][j], i[0][j] - i[1][j]
+	case "BC":
+		return v[1][j] - v[2][j], i[1][j] - i[2][j]
+	case "CA":
+		return v[2][j] - v[0][j], i[2][j] - i[0][j]
+	case "ABC":
+		_, v1, _ := SymmetricalComponents(v[0][j], v[1][j], v[2][j])
+		_, i1, _ := SymmetricalComponents(i[0][j], i[1][j], i[2][j])
+		return v1, i1
+	default:
+		return 0, 0
+	}
+}
+
+// LoopImpedance 计算某故障回路在给定采样点处的视在阻抗, 回路电流为零时返回 0
+func LoopImpedance(loop string, v, i ThreePhasePhasors, k0 complex128, j int) complex128 {
+	u, c := LoopQuantities(loop, v, i, k0, j)
+	if c == 0 {
+		return 0
+	}
+	return u / c
+}
+
+// FaultDistance 单端测距结果(公里及占线路全长百分比)
+type FaultDistance struct {
+	Loop             string  `json:"loop"`
+	R                float64 `json:"r"`
+	X                float64 `json:"x"`
+	ReactanceKm      float64 `json:"reactanceKm"`
+	ReactancePercent float64 `json:"reactancePercent"`
+	TakagiKm         float64 `json:"takagiKm"`
+	TakagiPercent    float64 `json:"takagiPercent"`
+	TakagiAvailable  bool    `json:"takagiAvailable"`
+}
+
+// EstimateFaultDistance 在故障后第 at 点对指定回路做单端测距
+//   - 电抗法:   d = Im(Zloop) / X1
+//   - Takagi法: d = Im(U·ΔI*) / Im(z1·I·ΔI*), I 为零序补偿后的回路电流,
+//     ΔI 为 at 与故障前 pre 两点的电流差(叠加分量), 可消除负荷电流与过渡电阻带来的误差
+func EstimateFaultDistance(loop string, v, i ThreePhasePhasors, line LineParams, at, pre int) FaultDistance {
+	k0 := line.K0()
+	z1 := line.Z1()
+	u, c := LoopQuantities(loop, v, i, k0, at)
+
+	out := FaultDistance{Loop: loop}
+	if c != 0 {
+		z := u / c
+		out.R, out.X = real(z), imag(z)
+		out.ReactanceKm = imag(z) / line.X1
+		out.ReactancePercent = out.ReactanceKm / line.Length * 100
+	}
+
+	if pre >= 0 && pre < at {
+		// 叠加分量取未补偿的回路电流(接地回路即相电流)之差, 其相位与故障支路电流一致
+		_, cAt := LoopQuantities(loop, v, i, 0, at)
+		_, cPre := LoopQuantities(loop, v, i, 0, pre)
+		delta := cmplx.Conj(cAt - cPre)
+		den := imag(z1 * c * delta)
+		if den != 0 {
+			out.TakagiKm = imag(u*delta) / den
+			out.TakagiPercent = out.TakagiKm / line.Length * 100
+			out.TakagiAvailable = true
+		}
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package comtrade
+
+import (
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+const (
+	// faultChangeRatio 突变量超过最大突变量的该比例即判为故障起始
+	faultChangeRatio = 0.15
+	// faultMinChangeRatio 最大突变量小于故障前电流的该比例时认为无故障
+	faultMinChangeRatio = 0.2
+	// faultPhaseRatio 相电流故障分量不小于最大相的该比例即判为故障相
+	faultPhaseRatio = 0.5
+	// faultGroundRatio 零序电流与最大相故障分量之比超过该值判为接地
+	faultGroundRatio = 0.1
+	// faultClearRatio 各相电流(或其相对故障前的变化量)低于故障电流的该比例判为切除
+	faultClearRatio = 0.1
+)
+
+// FaultClassification 故障类型识别结果
+//   - Type:      "AG"、"BC"、"BCG"、"ABC" 等, 未检测到故障时为空串
+//   - Inception: 故障起始采样序号, 未检测到故障时为 -1
+//   - Clearance: 故障切除采样序号, 记录结束前未切除时为 -1
+//   - I0/I1/I2:  故障后一个周波处故障分量的零序/正序/负序幅值
+type FaultClassification struct {
+	Type          string     `json:"type"`
+	Phases        string     `json:"phases"`
+	Ground        bool       `json:"ground"`
+	Inception     int        `json:"inception"`
+	Clearance     int        `json:"clearance"`
+	I0            float64    `json:"i0"`
+	I1            float64    `json:"i1"`
+	I2            float64    `json:"i2"`
+	PhaseCurrents [3]float64 `json:"phaseCurrents"`
+	VoltageDips   [3]float64 `json:"voltageDips,omitempty"`
+}
+
+// ClassifyFault 根据三相电流(及可选的三相电压)瞬时值识别故障类型与起止时刻
+//   - currents/voltages: A/B/C 顺序的瞬时值, voltages 可为 nil
+//   - trigger:           触发采样序号, 在其前 5 个周波起搜索故障起始
+//
+// 故障起始由电流一周波突变量 |i(k) - i(k-N)| 确定; 故障相由故障分量(故障后减故障前相量)的
+// 相对大小确定, 接地由零序分量判断, 单相/两相接地再以 I2 与 I0 的相角关系校核
+func ClassifyFault(currents, voltages [3][]float64, fs, f float64, trigger int) FaultClassification {
+	out := FaultClassification{Inception: -1, Clearance: -1}
+
+	n := len(currents[0])
+	for k := range 3 {
+		n = min(n, len(currents[k]))
+	}
+	cycle := int(math.Round(fs / f))
+	if cycle < 2 || n < 3*cycle {
+		return out
+	}
+
+	// 1. 电流突变量
+	from := max(cycle, trigger-5*cycle)
+	maxChange := 0.0
+	change := make([]float64, n)
+	for j := from; j < n; j++ {
+		for k := range 3 {
+			change[j] = math.Max(change[j], math.Abs(currents[k][j]-currents[k][j-cycle]))
+		}
+		maxChange = math.Max(maxChange, change[j])
+	}
+
+	iPhasors := [3][]complex128{}
+	for k := range 3 {
+		iPhasors[k] = SlidingPhasors(currents[k][:n], fs, f)
+	}
+	preLoad := 0.0
+	for k := range 3 {
+		preLoad = math.Max(preLoad, cmplx.Abs(iPhasors[k][from]))
+	}
+	if maxChange == 0 || maxChange < faultMinChangeRatio*preLoad*math.Sqrt2 {
+		return out
+	}
+
+	inception := -1
+	for j := from; j < n; j++ {
+		if change[j] >= faultChangeRatio*maxChange {
+			inception = j
+			break
+		}
+	}
+	if inception < 0 || inception-1 < cycle-1 {
+		return out
+	}
+
+	// 2. 故障分量: 故障后一个完整周波 - 故障前一个完整周波
+	pre := inception - 1
+	at := min(n-1, inception+cycle+cycle/4)
+	var delta [3]complex128
+	maxDelta := 0.0
+	for k := range 3 {
+		delta[k] = iPhasors[k][at] - iPhasors[k][pre]
+		out.PhaseCurrents[k] = cmplx.Abs(delta[k])
+		maxDelta = math.Max(maxDelta, out.PhaseCurrents[k])
+	}
+	if maxDelta == 0 {
+		return out
+	}
+	out.Inception = inception
+
+	i0, i1, i2 := SymmetricalComponents(delta[0], delta[1], delta[2])
+	out.I0, out.I1, out.I2 = cmplx.Abs(i0), cmplx.Abs(i1), cmplx.Abs(i2)
+	out.Ground = 3*out.I0 >= faultGroundRatio*maxDelta
+
+	faulted := [3]bool{}
+	count := 0
+	for k := range 3 {
+		if out.PhaseCurrents[k] >= faultPhaseRatio*maxDelta {
+			faulted[k] = true
+			count++
+		}
+	}
+
+	if voltages[0] != nil && voltages[1] != nil && voltages[2] != nil {
+		for k := range 3 {
+			vp := SlidingPhasors(voltages[k][:min(n, len(voltages[k]))], fs, f)
+			if pre < len(vp) && at < len(vp) && cmplx.Abs(vp[pre]) > 0 {
+				out.VoltageDips[k] = 1 - cmplx.Abs(vp[at])/cmplx.Abs(vp[pre])
+			}
+		}
+	}
+
+	// 3. 接地故障以 I2/I0 相角所在扇区校核故障相: 0°→A/BC, -120°→B/CA, 120°→C/AB
+	if out.Ground && count < 3 && out.I0 > 0 && out.I2 > 0 {
+		angle := cmplx.Phase(i2/i0) * 180 / math.Pi
+		single := 0
+		switch {
+		case angle < -60:
+			single = 1
+		case angle > 60:
+			single = 2
+		}
+		faulted = [3]bool{}
+		if count == 1 {
+			faulted[single] = true
+		} else {
+			faulted[(single+1)%3] = true
+			faulted[(single+2)%3] = true
+		}
+	}
+
+	var phases strings.Builder
+	for k, name := range []string{"A", "B", "C"} {
+		if faulted[k] {
+			phases.WriteString(name)
+		}
+	}
+	out.Phases = phases.String()
+	out.Type = out.Phases
+	if out.Ground {
+		out.Type += "G"
+	}
+	// 两相相间故障按习惯写作 AB/BC/CA
+	if out.Phases == "AC" {
+		out.Phases = "CA"
+		out.Type = strings.Replace(out.Type, "AC", "CA", 1)
+	}
+
+	// 4. 故障切除: 各相电流或其相对故障前的变化量回落到故障分量的 10% 以下,
+	// 相量窗口滞后约一个周波, 结果前移 0.9 周波补偿
+	for j := at; j < n; j++ {
+		low, restored := true, true
+		for k := range 3 {
+			if cmplx.Abs(iPhasors[k][j]) >= faultClearRatio*maxDelta {
+				low = false
+			}
+			if cmplx.Abs(iPhasors[k][j]-iPhasors[k][pre]) >= faultClearRatio*maxDelta {
+				restored = false
+			}
+		}
+		if low || restored {
+			out.Clearance = max(inception, j-cycle*9/10)
+			break
+		}
+	}
+	return out
+}
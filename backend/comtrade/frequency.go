@@ -0,0 +1,184 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+const (
+	FrequencyMethodZeroCrossing = "zerocross"
+	FrequencyMethodPhase        = "phase"
+)
+
+// phasePointsPerCycle 相角求导法每个周波的估计点数
+const phasePointsPerCycle = 8
+
+// FrequencyTrack 频率跟踪结果, 每个估计点对应一个采样序号
+type FrequencyTrack struct {
+	Method    string    `json:"method"`
+	Nominal   float64   `json:"nominal"`
+	Indices   []int     `json:"indices"`
+	Frequency []float64 `json:"frequency"`
+	DfDt      []float64 `json:"dfdt"`
+}
+
+// FrequencyAt 按采样序号线性插值得到跟踪频率, 无估计点时返回额定频率
+func (ft *FrequencyTrack) FrequencyAt(index int) float64 {
+	n := len(ft.Indices)
+	if n == 0 {
+		return ft.Nominal
+	}
+	i := sort.SearchInts(ft.Indices, index)
+	if i == 0 {
+		return ft.Frequency[0]
+	}
+	if i >= n {
+		return ft.Frequency[n-1]
+	}
+	x0, x1 := ft.Indices[i-1], ft.Indices[i]
+	if x1 == x0 {
+		return ft.Frequency[i]
+	}
+	r := float64(index-x0) / float64(x1-x0)
+	return ft.Frequency[i-1] + r*(ft.Frequency[i]-ft.Frequency[i-1])
+}
+
+// EstimateFrequency 在采样区间 [start, end] 内跟踪电压通道的频率并计算 df/dt
+//   - y:       换算后的采样值
+//   - fs:      采样率(Hz)
+//   - nominal: 额定频率(Hz), 用于确定窗口长度与相角求导的参考
+//   - method:  FrequencyMethodZeroCrossing 或 FrequencyMethodPhase
+func EstimateFrequency(y []float64, start, end int, fs, nominal float64, method string) (*FrequencyTrack, error) {
+	if fs <= 0 || nominal <= 0 {
+		return nil, fmt.Errorf("invalid sample rate %v or nominal frequency %v", fs, nominal)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= len(y) {
+		end = len(y) - 1
+	}
+	if end-start+1 < int(2*fs/nominal) {
+		return nil, fmt.Errorf("not enough samples for frequency tracking: need at least two cycles")
+	}
+
+	var track *FrequencyTrack
+	switch method {
+	case FrequencyMethodZeroCrossing, "":
+		track = trackZeroCrossing(y, start, end, fs)
+		track.Method = FrequencyMethodZeroCrossing
+	case FrequencyMethodPhase:
+		track = trackPhaseDerivative(y, start, end, fs, nominal)
+		track.Method = FrequencyMethodPhase
+	default:
+		return nil, fmt.Errorf("unsupported frequency method: %s", method)
+	}
+	track.Nominal = nominal
+	track.DfDt = derivative(track.Indices, track.Frequency, fs)
+	return track, nil
+}
+
+// trackZeroCrossing 使用正向过零点间隔估计频率, 过零时刻由线性插值得到
+// 仅在信号越过负向滞回门槛后才接受下一次过零, 以避免噪声造成的抖动
+func trackZeroCrossing(y []float64, start, end int, fs float64) *FrequencyTrack {
+	track := &FrequencyTrack{Indices: []int{}, Frequency: []float64{}}
+
+	mean, peak := 0.0, 0.0
+	for i := start; i <= end; i++ {
+		mean += y[i]
+	}
+	mean /= float64(end - start + 1)
+	for i := start; i <= end; i++ {
+		peak = math.Max(peak, math.Abs(y[i]-mean))
+	}
+	if peak == 0 {
+		return track
+	}
+	hysteresis := 0.05 * peak
+
+	armed := false
+	lastCross := math.NaN()
+	for i := start + 1; i <= end; i++ {
+		prev, cur := y[i-1]-mean, y[i]-mean
+		if cur < -hysteresis {
+			armed = true
+		}
+		if !armed || prev >= 0 || cur < 0 {
+			continue
+		}
+		armed = false
+		cross := float64(i-1) + (-prev)/(cur-prev)
+		if !math.IsNaN(lastCross) {
+			period := (cross - lastCross) / fs
+			if period > 0 {
+				track.Indices = append(track.Indices, int(math.Round((cross+lastCross)/2)))
+				track.Frequency = append(track.Frequency, 1/period)
+			}
+		}
+		lastCross = cross
+	}
+	return track
+}
+
+// trackPhaseDerivative 以额定频率做一周波DFT, 通过相角变化率估计频率:
+// f = f0 + (dφ/dt) / 2π, 结果再做一个周波的滑动平均以抑制非额定频率下的二倍频波动
+func trackPhaseDerivative(y []float64, start, end int, fs, nominal float64) *FrequencyTrack {
+	track := &FrequencyTrack{Indices: []int{}, Frequency: []float64{}}
+
+	cycle := int(math.Round(fs / nominal))
+	step := max(1, cycle/phasePointsPerCycle)
+
+	var indices []int
+	var raw []float64
+	prevIdx := -1
+	prevPhase := 0.0
+	for idx := start + cycle - 1; idx <= end; idx += step {
+		p := DFTPhasor(y, idx, fs, nominal)
+		if cmplx.Abs(p) == 0 {
+			prevIdx = -1
+			continue
+		}
+		phase := cmplx.Phase(p)
+		if prevIdx >= 0 {
+			d := phase - prevPhase
+			d -= 2 * math.Pi * math.Round(d/(2*math.Pi))
+			dt := float64(idx-prevIdx) / fs
+			indices = append(indices, (idx+prevIdx)/2)
+			raw = append(raw, nominal+d/(2*math.Pi*dt))
+		}
+		prevIdx = idx
+		prevPhase = phase
+	}
+
+	span := max(1, cycle/step)
+	for i := range raw {
+		lo := max(0, i-span/2)
+		hi := min(len(raw), lo+span)
+		sum := 0.0
+		for _, v := range raw[lo:hi] {
+			sum += v
+		}
+		track.Indices = append(track.Indices, indices[i])
+		track.Frequency = append(track.Frequency, sum/float64(hi-lo))
+	}
+	return track
+}
+
+// derivative 计算相邻估计点之间的变化率(单位/秒), 端点使用单侧差分
+func derivative(indices []int, values []float64, fs float64) []float64 {
+	n := len(values)
+	out := make([]float64, n)
+	if n < 2 {
+		return out
+	}
+	for i := range values {
+		lo, hi := max(0, i-1), min(n-1, i+1)
+		dt := float64(indices[hi]-indices[lo]) / fs
+		if dt > 0 {
+			out[i] = (values[hi] - values[lo]) / dt
+		}
+	}
+	return out
+}
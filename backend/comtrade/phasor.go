@@ -0,0 +1,143 @@
+package comtrade
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FrequencySource 提供某个采样点处用于DFT/RMS计算的系统频率
+type FrequencySource interface {
+	FrequencyAt(index int) float64
+}
+
+// FixedFrequency 以固定频率(通常为额定频率)实现 FrequencySource
+type FixedFrequency float64
+
+func (f FixedFrequency) FrequencyAt(int) float64 {
+	return float64(f)
+}
+
+// cycleWindow 返回以 index 结尾的一个周波窗口 [start, index]
+// 数据不足一个周波时, 窗口对齐到第一个完整周波
+func cycleWindow(n int, index int, fs, f float64) (int, int, bool) {
+	if fs <= 0 || f <= 0 {
+		return 0, 0, false
+	}
+	size := int(math.Round(fs / f))
+	if size < 2 || size > n {
+		return 0, 0, false
+	}
+	if index < size-1 {
+		index = size - 1
+	}
+	if index >= n {
+		index = n - 1
+	}
+	return index - size + 1, index, true
+}
+
+// DFTPhasor 以 index 结尾的一个周波数据计算频率 f 下的基波相量
+// 幅值为有效值, 相角以采样序号 0 为参考, 同一时刻不同通道的相角可直接比较
+func DFTPhasor(y []float64, index int, fs, f float64) complex128 {
+	start, end, ok := cycleWindow(len(y), index, fs, f)
+	if !ok {
+		return 0
+	}
+	w := 2 * math.Pi * f / fs
+	var re, im float64
+	for k := start; k <= end; k++ {
+		s, c := math.Sincos(w * float64(k))
+		re += y[k] * c
+		im -= y[k] * s
+	}
+	scale := math.Sqrt2 / float64(end-start+1)
+	return complex(re*scale, im*scale)
+}
+
+// CycleRMS 以 index 结尾的一个周波数据计算真有效值
+func CycleRMS(y []float64, index int, fs, f float64) float64 {
+	start, end, ok := cycleWindow(len(y), index, fs, f)
+	if !ok {
+		return 0
+	}
+	sum := 0.0
+	for k := start; k <= end; k++ {
+		sum += y[k] * y[k]
+	}
+	return math.Sqrt(sum / float64(end-start+1))
+}
+
+// PhasorSeries 在给定采样点上计算基波相量, 频率由 freq 逐点提供
+func PhasorSeries(y []float64, indices []int, fs float64, freq FrequencySource) []complex128 {
+	out := make([]complex128, len(indices))
+	for i, idx := range indices {
+		out[i] = DFTPhasor(y, idx, fs, freq.FrequencyAt(idx))
+	}
+	return out
+}
+
+// RMSSeries 在给定采样点上计算一个周波的真有效值, 频率由 freq 逐点提供
+func RMSSeries(y []float64, indices []int, fs float64, freq FrequencySource) []float64 {
+	out := make([]float64, len(indices))
+	for i, idx := range indices {
+		out[i] = CycleRMS(y, idx, fs, freq.FrequencyAt(idx))
+	}
+	return out
+}
+
+// PhasorAngleDegrees 返回相量相角(度)
+func PhasorAngleDegrees(p complex128) float64 {
+	return cmplx.Phase(p) * 180 / math.Pi
+}
+
+// SlidingPhasors 计算每个采样点处(以该点结尾的一个周波窗口)频率 f 下的基波相量
+// 使用前缀和实现, 复杂度 O(n); 前一个周波内的点取第一个完整周波的结果
+func SlidingPhasors(y []float64, fs, f float64) []complex128 {
+	n := len(y)
+	out := make([]complex128, n)
+	_, _, ok := cycleWindow(n, 0, fs, f)
+	if !ok {
+		return out
+	}
+	size := int(math.Round(fs / f))
+	w := 2 * math.Pi * f / fs
+
+	sumRe := make([]float64, n+1)
+	sumIm := make([]float64, n+1)
+	for k, v := range y {
+		s, c := math.Sincos(w * float64(k))
+		sumRe[k+1] = sumRe[k] + v*c
+		sumIm[k+1] = sumIm[k] - v*s
+	}
+
+	scale := math.Sqrt2 / float64(size)
+	for i := range out {
+		end := max(i, size-1)
+		start := end - size + 1
+		out[i] = complex((sumRe[end+1]-sumRe[start])*scale, (sumIm[end+1]-sumIm[start])*scale)
+	}
+	return out
+}
+
+// SlidingRMS 计算每个采样点处(以该点结尾的一个周波窗口)的真有效值, 复杂度 O(n)
+// 前一个周波内的点取第一个完整周波的结果
+func SlidingRMS(y []float64, fs, f float64) []float64 {
+	n := len(y)
+	out := make([]float64, n)
+	_, _, ok := cycleWindow(n, 0, fs, f)
+	if !ok {
+		return out
+	}
+	size := int(math.Round(fs / f))
+
+	sumSq := make([]float64, n+1)
+	for k, v := range y {
+		sumSq[k+1] = sumSq[k] + v*v
+	}
+	for i := range out {
+		end := max(i, size-1)
+		start := end - size + 1
+		out[i] = math.Sqrt(math.Max(0, sumSq[end+1]-sumSq[start]) / float64(size))
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// PowerGroup 描述一组功率计算所用的电压/电流通道, 按位置一一配对
+// 单相时各含 1 个通道, 三相时各含 3 个通道(A/B/C 顺序)
+type PowerGroup struct {
+	Voltages []int `json:"voltages"`
+	Currents []int `json:"currents"`
+}
+
+// PowerSeries 功率计算结果, 每个采样点一个值
+//   - Instantaneous:  瞬时有功 p(t) = Σ u·i
+//   - InstantaneousQ: 三相瞬时无功 q(t) = [(ub-uc)ia + (uc-ua)ib + (ua-ub)ic]/√3, 单相时为 nil
+//   - P/Q/S/PF:       基波有功、无功、视在功率与功率因数, 由一周波DFT相量 S = Σ U·I* 得到
+type PowerSeries struct {
+	Instantaneous  []float64
+	InstantaneousQ []float64
+	P              []float64
+	Q              []float64
+	S              []float64
+	PF             []float64
+}
+
+// ComputePower 根据已换算的电压、电流采样值计算瞬时与基波功率
+func ComputePower(voltages, currents [][]float64, fs, f float64) (*PowerSeries, error) {
+	if len(voltages) == 0 || len(voltages) != len(currents) {
+		return nil, fmt.Errorf("voltage/current channel count mismatch: %d vs %d", len(voltages), len(currents))
+	}
+	n := len(voltages[0])
+	for k := range voltages {
+		n = min(n, len(voltages[k]), len(currents[k]))
+	}
+
+	out := &PowerSeries{
+		Instantaneous: make([]float64, n),
+		P:             make([]float64, n),
+		Q:             make([]float64, n),
+		S:             make([]float64, n),
+		PF:            make([]float64, n),
+	}
+
+	for k := range voltages {
+		u, i := voltages[k], currents[k]
+		for j := range n {
+			out.Instantaneous[j] += u[j] * i[j]
+		}
+
+		up := SlidingPhasors(u[:n], fs, f)
+		ip := SlidingPhasors(i[:n], fs, f)
+		for j := range n {
+			s := up[j] * cmplx.Conj(ip[j])
+			out.P[j] += real(s)
+			out.Q[j] += imag(s)
+		}
+	}
+
+	if len(voltages) == 3 {
+		ua, ub, uc := voltages[0], voltages[1], voltages[2]
+		ia, ib, ic := currents[0], currents[1], currents[2]
+		out.InstantaneousQ = make([]float64, n)
+		for j := range n {
+			out.InstantaneousQ[j] = ((ub[j]-uc[j])*ia[j] + (uc[j]-ua[j])*ib[j] + (ua[j]-ub[j])*ic[j]) / math.Sqrt(3)
+		}
+	}
+
+	for j := range n {
+		out.S[j] = math.Hypot(out.P[j], out.Q[j])
+		if out.S[j] > 0 {
+			out.PF[j] = out.P[j] / out.S[j]
+		}
+	}
+	return out, nil
+}
+
+// unitPrefixes 常见的十进制单位前缀
+var unitPrefixes = map[string]int{"m": -3, "": 0, "k": 3, "M": 6, "G": 9}
+
+// splitUnitPrefix 将 "kV"/"kA" 等单位拆为十进制指数与基本单位
+func splitUnitPrefix(unit string) (int, string) {
+	unit = strings.TrimSpace(unit)
+	if len(unit) > 1 {
+		if exp, ok := unitPrefixes[unit[:1]]; ok {
+			return exp, unit[1:]
+		}
+	}
+	return 0, unit
+}
+
+// prefixForExponent 返回十进制指数对应的单位前缀, 不在表中时返回 false
+func prefixForExponent(exp int) (string, bool) {
+	for p, e := range unitPrefixes {
+		if e == exp {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// PowerUnitPrefix 由电压、电流单位推导功率单位前缀及功率值需乘的系数, 例如 kV×A → ("k", 1), kV×kA → ("M", 1);
+// 指数不在前缀表中时(如 mV×mA 为 10^-6)退回基本单位 W/var/VA, 系数为 10^指数
+func PowerUnitPrefix(voltageUnit, currentUnit string) (string, float64) {
+	ue, _ := splitUnitPrefix(voltageUnit)
+	ie, _ := splitUnitPrefix(currentUnit)
+	if prefix, ok := prefixForExponent(ue + ie); ok {
+		return prefix, 1
+	}
+	return "", math.Pow10(ue + ie)
+}
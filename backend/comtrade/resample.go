@@ -0,0 +1,254 @@
+package comtrade
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	ResampleMethodLinear = "linear" // 线性插值, 降采样时先做抗混叠低通滤波
+	ResampleMethodSinc   = "sinc"   // 加窗 sinc 带限插值, 截止频率取源/目标采样率较小者的一半
+)
+
+// sincHalfZeros 加窗 sinc 核单侧包含的过零点个数
+const sincHalfZeros = 16
+
+// 重采样结果的大小上限, 防止过高的目标采样率申请过多内存(结果会进入缓存):
+// MaxResampleSamples 为每个通道的采样点数, MaxResampleBytes 为全部通道与时间轴合计的字节数
+const (
+	MaxResampleSamples = 1 << 23
+	MaxResampleBytes   = 512 << 20
+)
+
+// ErrInvalidResample 重采样参数(采样率、方法)无效, 由调用方作为请求参数错误处理
+var ErrInvalidResample = errors.New("invalid resample parameters")
+
+// IsValidResampleMethod 判断重采样方法是否合法
+func IsValidResampleMethod(method string) bool {
+	return method == ResampleMethodLinear || method == ResampleMethodSinc
+}
+
+// UniformTimes 生成从 start 起、间隔为 step 毫秒、不超过 end 的时间网格
+func UniformTimes(start, end, step float64) []float64 {
+	if step <= 0 || end < start {
+		return []float64{}
+	}
+	n := int(math.Floor((end-start)/step+1e-9)) + 1
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = start + float64(i)*step
+	}
+	return out
+}
+
+// InterpolateLinear 在时刻 at(毫秒, 升序)处对 (times, y) 做线性插值, 超出范围时取端点值
+func InterpolateLinear(times, y []float64, at []float64) []float64 {
+	out := make([]float64, len(at))
+	n := min(len(times), len(y))
+	if n == 0 {
+		return out
+	}
+	j := 0
+	for i, t := range at {
+		// at 升序时从上次位置继续查找, 否则退回二分查找
+		if j > 0 && times[j-1] > t {
+			j = sort.SearchFloat64s(times[:n], t)
+		}
+		for j < n && times[j] < t {
+			j++
+		}
+		switch {
+		case j == 0:
+			out[i] = y[0]
+		case j >= n:
+			out[i] = y[n-1]
+		case times[j] == times[j-1]:
+			out[i] = y[j]
+		default:
+			w := (t - times[j-1]) / (times[j] - times[j-1])
+			out[i] = y[j-1] + w*(y[j]-y[j-1])
+		}
+	}
+	return out
+}
+
+// InterpolateHold 在时刻 at 处取不晚于该时刻的最后一个采样值(零阶保持), 用于开关量
+func InterpolateHold(times []float64, y []int8, at []float64) []int8 {
+	out := make([]int8, len(at))
+	n := min(len(times), len(y))
+	if n == 0 {
+		return out
+	}
+	for i, t := range at {
+		j := sort.Search(n, func(k int) bool { return times[k] > t })
+		out[i] = y[max(0, j-1)]
+	}
+	return out
+}
+
+// TimesToFloat64 将毫秒时间轴转换为 float64 并加上偏移 shift(毫秒)
+func TimesToFloat64(times []float32, shift float64) []float64 {
+	out := make([]float64, len(times))
+	for i, t := range times {
+		out[i] = float64(t) + shift
+	}
+	return out
+}
+
+// sourceRateAt 返回源时间轴在第 j 点附近的采样率(Hz)
+func sourceRateAt(times []float64, j int) float64 {
+	j = max(1, min(len(times)-1, j))
+	dt := times[j] - times[j-1]
+	if dt <= 0 {
+		return math.Inf(1)
+	}
+	return 1000 / dt
+}
+
+// bandLimited 以截止频率 cutoff(Hz) 的加窗 sinc 核在时刻 at 处重建信号
+// cutoff <= 0 时按源采样率逐点确定; 核权重归一化, 可适用于多采样率段拼接的非均匀时间轴
+func bandLimited(times, y []float64, at []float64, cutoff float64) []float64 {
+	out := make([]float64, len(at))
+	n := min(len(times), len(y))
+	if n == 0 {
+		return out
+	}
+	for i, t := range at {
+		j := sort.SearchFloat64s(times[:n], t)
+		fc := cutoff
+		if fc <= 0 || fc > sourceRateAt(times[:n], j)/2 {
+			fc = sourceRateAt(times[:n], j) / 2
+		}
+		if math.IsInf(fc, 1) {
+			out[i] = y[min(j, n-1)]
+			continue
+		}
+		// 核半宽(毫秒)
+		half := sincHalfZeros / (2 * fc) * 1000
+		lo := sort.SearchFloat64s(times[:n], t-half)
+		var sum, weight float64
+		for k := lo; k < n && times[k] <= t+half; k++ {
+			tau := (t - times[k]) / 1000
+			x := 2 * fc * tau
+			h := 1.0
+			if x != 0 {
+				h = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			h *= 0.5 + 0.5*math.Cos(math.Pi*tau*1000/half)
+			sum += h * y[k]
+			weight += h
+		}
+		if weight == 0 {
+			out[i] = InterpolateLinear(times[:n], y[:n], []float64{t})[0]
+			continue
+		}
+		out[i] = sum / weight
+	}
+	return out
+}
+
+// Resample 将 (times, y) 重采样到均匀时间网格 at(间隔即目标采样周期)
+//   - linear: 目标采样率低于源采样率时先以目标奈奎斯特频率做抗混叠低通, 再线性插值
+//   - sinc:   加窗 sinc 带限插值, 截止频率取 min(源, 目标)/2, 降采样时同时起到抗混叠作用
+func Resample(times, y []float64, at []float64, method string) []float64 {
+	dstRate := math.Inf(1)
+	if len(at) > 1 && at[1] > at[0] {
+		dstRate = 1000 / (at[1] - at[0])
+	}
+	switch method {
+	case ResampleMethodSinc:
+		return bandLimited(times, y, at, dstRate/2)
+	default:
+		n := min(len(times), len(y))
+		if n < 2 || dstRate >= sourceRateAt(times, n/2) {
+			return InterpolateLinear(times, y, at)
+		}
+		// 只对目标时刻两侧的源采样点做低通滤波, 再在滤波后的点之间线性插值
+		neighbors := make([]float64, 0, 2*len(at))
+		for _, t := range at {
+			j := max(1, min(n-1, sort.SearchFloat64s(times[:n], t)))
+			for _, k := range []int{j - 1, j} {
+				if m := len(neighbors); m == 0 || times[k] > neighbors[m-1] {
+					neighbors = append(neighbors, times[k])
+				}
+			}
+		}
+		return InterpolateLinear(neighbors, bandLimited(times[:n], y[:n], neighbors, dstRate/2), at)
+	}
+}
+
+// ResampleDataset 将整条记录重采样为单一采样率 rate(Hz), 返回新的元数据与通道数据
+// 模拟量以浮点原始值(换算前)保存, 开关量按零阶保持; 时间范围与原记录一致
+func ResampleDataset(meta *Metadata, dat *ChannelData, rate float64, method string) (*Metadata, *ChannelData, error) {
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return nil, nil, fmt.Errorf("%w: rate %v must be a positive number", ErrInvalidResample, rate)
+	}
+	if method == "" {
+		method = ResampleMethodLinear
+	}
+	if !IsValidResampleMethod(method) {
+		return nil, nil, fmt.Errorf("%w: unsupported method %q", ErrInvalidResample, method)
+	}
+	if len(dat.Timestamps) == 0 {
+		return nil, nil, fmt.Errorf("cannot resample a record without samples")
+	}
+
+	src := TimesToFloat64(ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps)), 0)
+	expected := (src[len(src)-1]-src[0])*rate/1000 + 1
+	if expected > MaxResampleSamples {
+		return nil, nil, fmt.Errorf("%w: rate %g Hz yields %.0f samples, exceeding the limit of %d", ErrInvalidResample, rate, expected, MaxResampleSamples)
+	}
+	// 每个采样: 时间戳 int32、每个模拟量 float32、每个开关量 int8
+	perSample := 4 + 4*len(dat.AnalogChannels) + len(dat.DigitalChannels)
+	if size := expected * float64(perSample); size > MaxResampleBytes {
+		return nil, nil, fmt.Errorf("%w: rate %g Hz yields %.0f samples x %d channels (%.0f MB), exceeding the limit of %d MB",
+			ErrInvalidResample, rate, expected, len(dat.AnalogChannels)+len(dat.DigitalChannels), size/(1<<20), MaxResampleBytes>>20)
+	}
+	at := UniformTimes(src[0], src[len(src)-1], 1000/rate)
+	n := len(at)
+
+	outMeta := *meta
+	outMeta.RatesNum = 1
+	outMeta.SampleRates = []SampleRate{{SampRate: rate, LastSampleNum: n}}
+
+	outDat := &ChannelData{
+		Timestamps:      make([]int32, n),
+		AnalogChannels:  make([]AnalogChannelData, 0, len(dat.AnalogChannels)),
+		DigitalChannels: make([]DigitalChannelData, 0, len(dat.DigitalChannels)),
+	}
+	mul := meta.TimeMultiplier
+	if mul == 0 {
+		mul = 1
+	}
+	for i, t := range at {
+		outDat.Timestamps[i] = int32(math.Round(t * 1000 / mul))
+	}
+
+	for _, ch := range dat.AnalogChannels {
+		raw := make([]float64, 0, max(len(ch.RawData), len(ch.RawDataFloat)))
+		if len(ch.RawDataFloat) >= len(ch.RawData) {
+			for _, v := range ch.RawDataFloat {
+				raw = append(raw, float64(v))
+			}
+		} else {
+			for _, v := range ch.RawData {
+				raw = append(raw, float64(v))
+			}
+		}
+		resampled := Resample(src[:min(len(src), len(raw))], raw, at, method)
+		data := make([]float32, n)
+		for i, v := range resampled {
+			data[i] = float32(v)
+		}
+		outDat.AnalogChannels = append(outDat.AnalogChannels, AnalogChannelData{ChannelNumber: ch.ChannelNumber, RawDataFloat: data})
+	}
+	for _, ch := range dat.DigitalChannels {
+		outDat.DigitalChannels = append(outDat.DigitalChannels, DigitalChannelData{
+			ChannelNumber: ch.ChannelNumber,
+			RawData:       InterpolateHold(src, ch.RawData, at),
+		})
+	}
+	return &outMeta, outDat, nil
+}
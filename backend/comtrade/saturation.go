@@ -0,0 +1,156 @@
+package comtrade
+
+import (
+	"math"
+	"slices"
+)
+
+const (
+	// saturationActiveRatio 周波峰值低于记录最大峰值的该比例时不做判断(负荷电流一般不致饱和)
+	saturationActiveRatio = 0.2
+	// saturationSpikeRatio 二阶差分超过同幅值正弦理论最大值的倍数判为突变尖峰
+	saturationSpikeRatio = 4.0
+	// saturationSpikeFloor 二阶差分门槛的下限(相对周波峰值), 避免低采样率时正弦本身被误判
+	saturationSpikeFloor = 0.05
+	// saturationFlatSlope 斜率低于正弦最大斜率的该比例视为平坦
+	saturationFlatSlope = 0.05
+	// saturationFlatMinCycle 平坦段至少持续的周波比例
+	saturationFlatMinCycle = 1.0 / 16
+	// saturationDCRatio 周波平均值与峰值之比超过该值视为存在明显直流分量
+	saturationDCRatio = 0.2
+)
+
+const (
+	SaturationSpikes   = "spikes"   // 二阶差分尖峰(波形突变)
+	SaturationFlatTop  = "flattop"  // 非峰值处的平坦段(饱和期间二次电流接近零或被削平)
+	SaturationDCOffset = "dcoffset" // 明显的衰减直流分量, 饱和的诱因
+)
+
+// SaturationInterval CT 饱和疑似区间(采样序号闭区间)及置信度(0~1)
+type SaturationInterval struct {
+	Start      int      `json:"start"`
+	End        int      `json:"end"`
+	Confidence float64  `json:"confidence"`
+	Indicators []string `json:"indicators"`
+}
+
+// DetectCTSaturation 按周波扫描电流通道, 根据波形特征标记疑似 CT 饱和区间
+// 每个周波的得分: 二阶差分尖峰 0.45、平坦段 0.45、直流分量 0.1; 出现尖峰或平坦段的周波视为疑似饱和,
+// 相邻疑似周波合并为区间, 区间置信度取各周波得分的平均值
+func DetectCTSaturation(y []float64, fs, f float64) []SaturationInterval {
+	out := make([]SaturationInterval, 0)
+	cycle := int(math.Round(fs / f))
+	if cycle < 4 || len(y) < 2*cycle {
+		return out
+	}
+	omega := 2 * math.Pi * f / fs
+
+	globalPeak := 0.0
+	for _, v := range y {
+		globalPeak = math.Max(globalPeak, math.Abs(v))
+	}
+	if globalPeak == 0 {
+		return out
+	}
+
+	var cur *SaturationInterval
+	var scoreSum float64
+	var blocks int
+	flush := func() {
+		if cur != nil {
+			cur.Confidence = math.Min(1, scoreSum/float64(blocks))
+			out = append(out, *cur)
+			cur = nil
+		}
+	}
+
+	// 各周波自身峰值; 故障起始与切除所在周波的突变不是饱和特征, 只判断前后周波均有较大电流的周波
+	blockPeak := make([]float64, len(y)/cycle)
+	for b := range blockPeak {
+		for k := b * cycle; k < (b+1)*cycle; k++ {
+			blockPeak[b] = math.Max(blockPeak[b], math.Abs(y[k]))
+		}
+	}
+	active := func(b int) bool {
+		return b >= 0 && b < len(blockPeak) && blockPeak[b] >= saturationActiveRatio*globalPeak
+	}
+
+	for b := 1; b < len(blockPeak); b++ {
+		start, end := b*cycle, (b+1)*cycle-1
+		if !active(b-1) || !active(b) || !active(b+1) {
+			flush()
+			continue
+		}
+		peak := math.Max(blockPeak[b-1], blockPeak[b])
+
+		// 1. 二阶差分尖峰
+		spikeThreshold := peak * math.Max(saturationSpikeRatio*omega*omega, saturationSpikeFloor)
+		spikes := false
+		for k := max(1, start); k <= end && k+1 < len(y); k++ {
+			if math.Abs(y[k+1]-2*y[k]+y[k-1]) > spikeThreshold {
+				spikes = true
+				break
+			}
+		}
+
+		// 2. 远离正弦峰值处的平坦段
+		flatThreshold := saturationFlatSlope * peak * omega
+		minRun := max(2, int(math.Ceil(saturationFlatMinCycle*float64(cycle))))
+		flat := false
+		run, runAbs := 0, 0.0
+		for k := start; k <= end; k++ {
+			if math.Abs(y[k]-y[k-1]) < flatThreshold {
+				run++
+				runAbs += math.Abs(y[k])
+				if run >= minRun && runAbs/float64(run) < 0.5*peak {
+					flat = true
+					break
+				}
+			} else {
+				run, runAbs = 0, 0
+			}
+		}
+
+		// 3. 直流分量
+		mean := 0.0
+		for k := start; k <= end; k++ {
+			mean += y[k]
+		}
+		dc := math.Abs(mean/float64(cycle)) > saturationDCRatio*peak
+
+		if !spikes && !flat {
+			flush()
+			continue
+		}
+		score := 0.0
+		indicators := make([]string, 0, 3)
+		if spikes {
+			score += 0.45
+			indicators = append(indicators, SaturationSpikes)
+		}
+		if flat {
+			score += 0.45
+			indicators = append(indicators, SaturationFlatTop)
+		}
+		if dc {
+			score += 0.1
+			indicators = append(indicators, SaturationDCOffset)
+		}
+
+		if cur == nil {
+			cur = &SaturationInterval{Start: start, Indicators: indicators}
+			scoreSum, blocks = 0, 0
+		} else {
+			for _, ind := range indicators {
+				if !slices.Contains(cur.Indicators, ind) {
+					cur.Indicators = append(cur.Indicators, ind)
+				}
+			}
+		}
+		cur.End = end
+		scoreSum += score
+		blocks++
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,48 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// opA 旋转算子 a = 1∠120°
+var opA = cmplx.Rect(1, 2*math.Pi/3)
+
+// SymmetricalComponents 由 A/B/C 三相相量计算零序、正序、负序分量
+//   - X0 = (Xa + Xb + Xc) / 3
+//   - X1 = (Xa + a·Xb + a²·Xc) / 3
+//   - X2 = (Xa + a²·Xb + a·Xc) / 3
+func SymmetricalComponents(a, b, c complex128) (complex128, complex128, complex128) {
+	a2 := opA * opA
+	zero := (a + b + c) / 3
+	pos := (a + opA*b + a2*c) / 3
+	neg := (a + a2*b + opA*c) / 3
+	return zero, pos, neg
+}
+
+// ThreePhasePhasors 三相相量序列, 按 A/B/C 顺序
+type ThreePhasePhasors [3][]complex128
+
+// ThreePhaseGroupPhasors 计算三相通道组(A/B/C 顺序)每个采样点的基波相量(额定频率)
+// 数值按 opts 取值后再换算为基本单位(V/A), 便于直接计算欧姆阻抗
+func ThreePhaseGroupPhasors(meta *Metadata, dat *ChannelData, channels []int, opts AnalogOptions) (ThreePhasePhasors, error) {
+	var out ThreePhasePhasors
+	if len(channels) != 3 {
+		return out, fmt.Errorf("three-phase group requires 3 channels, got %d", len(channels))
+	}
+	fs := meta.SampleRateAt(0)
+	for k, chNum := range channels {
+		y, unit, err := AnalogValues(meta, dat, chNum, opts)
+		if err != nil {
+			return out, err
+		}
+		if base := UnitBaseFactor(unit); base != 1 {
+			for i := range y {
+				y[i] *= base
+			}
+		}
+		out[k] = SlidingPhasors(y, fs, meta.NominalFrequency())
+	}
+	return out, nil
+}
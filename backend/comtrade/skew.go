@@ -0,0 +1,30 @@
+package comtrade
+
+import "math"
+
+// CompensateSkew 将带有采样时间偏移的通道重新插值到公共时间网格上
+// COMTRADE 中 skew 为该通道相对于采样时刻的延迟(微秒), 即第 k 个采样实际发生在 t_k + skew,
+// 因此公共网格上 t_k 处的值取自原序列 k - skew·fs 处, 采用线性插值, 越界部分取端点值
+func CompensateSkew(y []float64, skewMicros, fs float64) []float64 {
+	n := len(y)
+	if n == 0 || skewMicros == 0 || fs <= 0 {
+		return y
+	}
+	shift := skewMicros * 1e-6 * fs
+
+	out := make([]float64, n)
+	for k := range out {
+		pos := float64(k) - shift
+		switch {
+		case pos <= 0:
+			out[k] = y[0]
+		case pos >= float64(n-1):
+			out[k] = y[n-1]
+		default:
+			i := int(math.Floor(pos))
+			frac := pos - float64(i)
+			out[k] = y[i] + frac*(y[i+1]-y[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package comtrade
+
+import "math"
+
+// AnalogStats 模拟量通道在窗口内的统计量(全分辨率), 时间为相对记录开始的毫秒数
+//   - Mean:     窗口内算术平均值
+//   - DCOffset: 窗口内整数个额定周波的平均值, 消除不完整周波对直流分量的影响
+//   - Crest:    峰值因数 max(|Min|, |Max|) / RMS
+type AnalogStats struct {
+	Samples    int     `json:"samples"`
+	Min        float64 `json:"min"`
+	MinTime    float32 `json:"minTime"`
+	Max        float64 `json:"max"`
+	MaxTime    float32 `json:"maxTime"`
+	Mean       float64 `json:"mean"`
+	DCOffset   float64 `json:"dcOffset"`
+	RMS        float64 `json:"rms"`
+	PeakToPeak float64 `json:"peakToPeak"`
+	Crest      float64 `json:"crestFactor"`
+}
+
+// AnalogStatistics 计算采样 [start, end] 内的统计量
+func AnalogStatistics(y []float64, times []float32, start, end int, fs, f float64) AnalogStats {
+	end = min(end, len(y)-1, len(times)-1)
+	if start < 0 || start > end {
+		return AnalogStats{}
+	}
+	out := AnalogStats{Samples: end - start + 1, Min: y[start], Max: y[start], MinTime: times[start], MaxTime: times[start]}
+	var sum, sumSq float64
+	for i := start; i <= end; i++ {
+		v := y[i]
+		sum += v
+		sumSq += v * v
+		if v < out.Min {
+			out.Min, out.MinTime = v, times[i]
+		}
+		if v > out.Max {
+			out.Max, out.MaxTime = v, times[i]
+		}
+	}
+	n := float64(out.Samples)
+	out.Mean = sum / n
+	out.RMS = math.Sqrt(sumSq / n)
+	out.PeakToPeak = out.Max - out.Min
+	if out.RMS > 0 {
+		out.Crest = math.Max(math.Abs(out.Min), math.Abs(out.Max)) / out.RMS
+	}
+
+	out.DCOffset = out.Mean
+	if fs > 0 && f > 0 {
+		cycle := fs / f
+		if cycles := math.Floor(n / cycle); cycles >= 1 {
+			m := int(math.Round(cycles * cycle))
+			dc := 0.0
+			for i := start; i < start+m; i++ {
+				dc += y[i]
+			}
+			out.DCOffset = dc / float64(m)
+		}
+	}
+	return out
+}
+
+// DigitalStats 开关量通道在窗口内的变位次数及处于各状态的时间(毫秒)
+type DigitalStats struct {
+	Samples     int     `json:"samples"`
+	Transitions int     `json:"transitions"`
+	Rising      int     `json:"rising"`
+	Falling     int     `json:"falling"`
+	TimeHigh    float64 `json:"timeHigh"`
+	TimeLow     float64 `json:"timeLow"`
+	Initial     int8    `json:"initial"`
+	Final       int8    `json:"final"`
+}
+
+// DigitalStatistics 计算采样 [start, end] 内的开关量统计
+// 每个采样代表其到下一个采样之间的时间, 窗口最后一个采样沿用前一个采样间隔
+func DigitalStatistics(y []int8, times []float32, start, end int) DigitalStats {
+	end = min(end, len(y)-1, len(times)-1)
+	if start < 0 || start > end {
+		return DigitalStats{}
+	}
+	out := DigitalStats{Samples: end - start + 1, Initial: y[start], Final: y[end]}
+	for i := start; i <= end; i++ {
+		if i > start && y[i] != y[i-1] {
+			out.Transitions++
+			if y[i] > y[i-1] {
+				out.Rising++
+			} else {
+				out.Falling++
+			}
+		}
+
+		var dt float64
+		switch {
+		case i < end:
+			dt = float64(times[i+1] - times[i])
+		case i > start:
+			dt = float64(times[i] - times[i-1])
+		}
+		if y[i] != 0 {
+			out.TimeHigh += dt
+		} else {
+			out.TimeLow += dt
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package comtrade
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+const (
+	ParkAngleTracked = "tracked" // 由三相正序基波相量跟踪电角度
+	ParkAngleFixed   = "fixed"   // 按固定频率与初相角计算电角度
+)
+
+// ClarkeComponents αβ0 分量(幅值不变形式)
+type ClarkeComponents struct {
+	Alpha, Beta, Zero []float64
+}
+
+// ClarkeTransform 三相瞬时值的 Clarke 变换(幅值不变形式)
+//
+//	α = 2/3·(a - b/2 - c/2), β = (b - c)/√3, 0 = (a + b + c)/3
+func ClarkeTransform(a, b, c []float64) ClarkeComponents {
+	n := min(len(a), len(b), len(c))
+	out := ClarkeComponents{Alpha: make([]float64, n), Beta: make([]float64, n), Zero: make([]float64, n)}
+	for i := range n {
+		out.Alpha[i] = (2*a[i] - b[i] - c[i]) / 3
+		out.Beta[i] = (b[i] - c[i]) / math.Sqrt(3)
+		out.Zero[i] = (a[i] + b[i] + c[i]) / 3
+	}
+	return out
+}
+
+// ParkComponents dq0 分量及所用电角度(弧度)
+type ParkComponents struct {
+	D, Q, Zero []float64
+	Theta      []float64
+}
+
+// ParkTransform 在电角度 theta 下将 αβ0 分量旋转为 dq0 分量
+// 以 a = cosθ 为参考, 对称正序三相量变换后 d 为相幅值、q 为 0
+func ParkTransform(ab ClarkeComponents, theta []float64) ParkComponents {
+	n := min(len(ab.Alpha), len(theta))
+	out := ParkComponents{D: make([]float64, n), Q: make([]float64, n), Zero: ab.Zero[:n], Theta: theta[:n]}
+	for i := range n {
+		s, c := math.Sincos(theta[i])
+		out.D[i] = ab.Alpha[i]*c + ab.Beta[i]*s
+		out.Q[i] = -ab.Alpha[i]*s + ab.Beta[i]*c
+	}
+	return out
+}
+
+// FixedAngle 按固定频率 f(Hz) 与初相角 phase(弧度)生成 n 个采样点的电角度
+func FixedAngle(n int, fs, f, phase float64) []float64 {
+	out := make([]float64, n)
+	w := 2 * math.Pi * f / fs
+	for i := range out {
+		out[i] = w*float64(i) + phase
+	}
+	return out
+}
+
+// TrackedAngle 由三相瞬时值的正序基波相量跟踪电角度: θ(k) = ω·k + arg(P1(k))
+// 相量以采样序号 0 为参考, 额定频率下 arg(P1) 保持不变, 频率偏移时随之缓慢变化
+func TrackedAngle(a, b, c []float64, fs, f float64) ([]float64, error) {
+	n := min(len(a), len(b), len(c))
+	if _, _, ok := cycleWindow(n, 0, fs, f); !ok {
+		return nil, fmt.Errorf("record shorter than one cycle, cannot track phase angle")
+	}
+	pa := SlidingPhasors(a[:n], fs, f)
+	pb := SlidingPhasors(b[:n], fs, f)
+	pc := SlidingPhasors(c[:n], fs, f)
+	w := 2 * math.Pi * f / fs
+	out := make([]float64, n)
+	for i := range out {
+		_, p1, _ := SymmetricalComponents(pa[i], pb[i], pc[i])
+		out[i] = w*float64(i) + cmplx.Phase(p1)
+	}
+	return out, nil
+}
@@ -0,0 +1,201 @@
+package comtrade
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// cfgTimeLayout CFG 中开始时刻与触发时刻的格式
+const cfgTimeLayout = "02/01/2006,15:04:05.000000"
+
+// formatCFGNumber 以不含指数的最短形式输出实数, 解析后与原值完全相同
+func formatCFGNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// cfgField 去掉字段中的逗号与换行, 避免破坏 CFG 的行列结构
+func cfgField(s string) string {
+	return strings.NewReplacer(",", "_", "\r", "", "\n", "").Replace(s)
+}
+
+// WriteCFG 按 meta.Version 写出 CFG 文件(UTF-8, CRLF 换行); 1991 版不含变比、相别等字段
+func WriteCFG(w io.Writer, meta *Metadata) error {
+	bw := bufio.NewWriter(w)
+	line := func(format string, args ...any) {
+		fmt.Fprintf(bw, format+"\r\n", args...)
+	}
+
+	if meta.Version == "1991" {
+		line("%s,%s", cfgField(meta.Station), cfgField(meta.Relay))
+	} else {
+		line("%s,%s,%s", cfgField(meta.Station), cfgField(meta.Relay), meta.Version)
+	}
+	line("%d,%dA,%dD", len(meta.AnalogChannels)+len(meta.DigitalChannels), len(meta.AnalogChannels), len(meta.DigitalChannels))
+	for _, ch := range meta.AnalogChannels {
+		fields := []string{
+			strconv.Itoa(ch.ChannelNumber), cfgField(ch.ChannelName), cfgField(ch.Phase), cfgField(ch.CCBM), cfgField(ch.Unit),
+			formatCFGNumber(ch.Multiplier), formatCFGNumber(ch.Offset), formatCFGNumber(ch.Skew),
+			formatCFGNumber(ch.MinValue), formatCFGNumber(ch.MaxValue),
+		}
+		if meta.Version != "1991" {
+			fields = append(fields, formatCFGNumber(ch.Primary), formatCFGNumber(ch.Secondary), cfgField(ch.PS))
+		}
+		line("%s", strings.Join(fields, ","))
+	}
+	for _, ch := range meta.DigitalChannels {
+		if meta.Version == "1991" {
+			line("%d,%s,%d", ch.ChannelNumber, cfgField(ch.ChannelName), ch.Y)
+		} else {
+			line("%d,%s,%s,%s,%d", ch.ChannelNumber, cfgField(ch.ChannelName), cfgField(ch.Phase), cfgField(ch.CCBM), ch.Y)
+		}
+	}
+	line("%s", formatCFGNumber(meta.Frequency))
+	if len(meta.SampleRates) == 0 {
+		line("0")
+		line("0,%d", lastSampleNum(meta))
+	} else {
+		line("%d", len(meta.SampleRates))
+		for _, sr := range meta.SampleRates {
+			line("%s,%d", formatCFGNumber(sr.SampRate), sr.LastSampleNum)
+		}
+	}
+	line("%s", meta.StartTime.Format(cfgTimeLayout))
+	line("%s", meta.EndTime.Format(cfgTimeLayout))
+	line("%s", strings.ToUpper(meta.DataFileType))
+	if meta.Version != "1991" {
+		line("%s", formatCFGNumber(meta.TimeMultiplier))
+	}
+	if meta.Version == "2013" {
+		line("%s,%s", orDefault(meta.TimeCode, "0"), orDefault(meta.LocalCode, "0"))
+		line("%s,%s", orDefault(meta.TmqCode, "0"), orDefault(meta.LeapSecond, "0"))
+	}
+	return bw.Flush()
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// lastSampleNum 返回采样率段中记录的末采样号, 缺失时为 0
+func lastSampleNum(meta *Metadata) int {
+	if n := len(meta.SampleRates); n > 0 {
+		return meta.SampleRates[n-1].LastSampleNum
+	}
+	return 0
+}
+
+// analogColumns 按 CFG 中的通道顺序返回各模拟量通道的记录值(码值), 长度不足 n 的部分补 0
+func analogColumns(meta *Metadata, dat *ChannelData, n int) [][]float64 {
+	cols := make([][]float64, len(meta.AnalogChannels))
+	for k, ch := range meta.AnalogChannels {
+		col := make([]float64, n)
+		ints, floats, err := dat.GetAnalogData(ch.ChannelNumber)
+		if err == nil {
+			if len(floats) >= len(ints) {
+				for i := range min(n, len(floats)) {
+					col[i] = float64(floats[i])
+				}
+			} else {
+				for i := range min(n, len(ints)) {
+					col[i] = float64(ints[i])
+				}
+			}
+		}
+		cols[k] = col
+	}
+	return cols
+}
+
+// digitalColumns 按 CFG 中的通道顺序返回各开关量通道的数据
+func digitalColumns(meta *Metadata, dat *ChannelData, n int) [][]int8 {
+	cols := make([][]int8, len(meta.DigitalChannels))
+	for k, ch := range meta.DigitalChannels {
+		col := make([]int8, n)
+		if y, err := dat.GetDigitalData(ch.ChannelNumber); err == nil {
+			copy(col, y)
+		}
+		cols[k] = col
+	}
+	return cols
+}
+
+// WriteDAT 按 meta.DataFileType 写出数据文件, 样本序号从 1 开始
+// 整数格式的码值四舍五入并限制在格式范围内, 格式的缺失值码原样写出;
+// 需要保证精度时先用 Convert 重新计算比例系数
+func WriteDAT(w io.Writer, meta *Metadata, dat *ChannelData) error {
+	n := len(dat.Timestamps)
+	analog := analogColumns(meta, dat, n)
+	digital := digitalColumns(meta, dat, n)
+	limit, integer := fileTypeLimit(meta.DataFileType)
+	if limit == 0 {
+		return fmt.Errorf("unsupported data file type: %s", meta.DataFileType)
+	}
+	missing, hasMissing := missingCode(meta.DataFileType)
+	code := func(v float64) int64 {
+		if hasMissing && v == missing {
+			return int64(missing)
+		}
+		return int64(math.Max(-limit, math.Min(limit, math.Round(v))))
+	}
+
+	bw := bufio.NewWriterSize(w, 64*1024)
+	if meta.DataFileType == "ascii" {
+		row := make([]byte, 0, 256)
+		for i := range n {
+			row = strconv.AppendInt(row[:0], int64(i+1), 10)
+			row = append(row, ',')
+			row = strconv.AppendInt(row, int64(dat.Timestamps[i]), 10)
+			for _, col := range analog {
+				row = append(row, ',')
+				row = strconv.AppendInt(row, code(col[i]), 10)
+			}
+			for _, col := range digital {
+				row = append(row, ',')
+				row = strconv.AppendInt(row, int64(col[i]), 10)
+			}
+			row = append(row, '\r', '\n')
+			if _, err := bw.Write(row); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	}
+
+	words := (len(digital) + 15) / 16
+	row := make([]byte, 0, 8+4*len(analog)+2*words)
+	for i := range n {
+		row = binary.LittleEndian.AppendUint32(row[:0], uint32(i+1))
+		row = binary.LittleEndian.AppendUint32(row, uint32(dat.Timestamps[i]))
+		for _, col := range analog {
+			switch {
+			case !integer:
+				row = binary.LittleEndian.AppendUint32(row, math.Float32bits(float32(col[i])))
+			case meta.DataFileType == "binary":
+				row = binary.LittleEndian.AppendUint16(row, uint16(int16(code(col[i]))))
+			default:
+				row = binary.LittleEndian.AppendUint32(row, uint32(int32(code(col[i]))))
+			}
+		}
+		for wd := range words {
+			var packed uint16
+			for b := range 16 {
+				if d := wd*16 + b; d < len(digital) && digital[d][i] != 0 {
+					packed |= 1 << b
+				}
+			}
+			row = binary.LittleEndian.AppendUint16(row, packed)
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
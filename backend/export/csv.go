@@ -0,0 +1,67 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"math"
+)
+
+// CSVWriter 以 CSV 格式按行写出, 可选写入 UTF-8 BOM 以便 Excel 正确识别中文通道名
+type CSVWriter struct {
+	buf   *bufio.Writer
+	w     *csv.Writer
+	bom   bool
+	cells []string
+}
+
+// NewCSVWriter 创建 CSV 写出器, delimiter 为 0 时使用逗号
+func NewCSVWriter(w io.Writer, delimiter rune, bom bool) *CSVWriter {
+	buf := bufio.NewWriterSize(w, 64<<10)
+	cw := csv.NewWriter(buf)
+	if delimiter != 0 {
+		cw.Comma = delimiter
+	}
+	return &CSVWriter{buf: buf, w: cw, bom: bom}
+}
+
+func (cw *CSVWriter) WriteHeader(timeHeader string, cols []Column) error {
+	if cw.bom {
+		if _, err := cw.buf.WriteString("\uFEFF"); err != nil {
+			return err
+		}
+	}
+	header := make([]string, 0, len(cols)+1)
+	header = append(header, timeHeader)
+	for _, col := range cols {
+		header = append(header, col.Header())
+	}
+	cw.cells = make([]string, len(header))
+	return cw.w.Write(header)
+}
+
+// WriteRow 写出一行, NaN/Inf 写为空字段(与 XLSX 的空单元格一致)
+func (cw *CSVWriter) WriteRow(row Row) error {
+	if row.Label != "" {
+		cw.cells[0] = row.Label
+	} else {
+		cw.cells[0] = formatFloat(row.Time)
+	}
+	for i, v := range row.Values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			cw.cells[i+1] = ""
+		} else {
+			cw.cells[i+1] = formatFloat(v)
+		}
+	}
+	return cw.w.Write(cw.cells[:len(row.Values)+1])
+}
+
+// Close 写出缓冲区中剩余的数据
+func (cw *CSVWriter) Close() error {
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return err
+	}
+	return cw.buf.Flush()
+}
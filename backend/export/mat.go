@@ -0,0 +1,229 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// MAT v5 数据类型与数组类别(见 MATLAB "MAT-File Format" 文档)
+const (
+	miINT8   = 1
+	miUINT16 = 4
+	miINT32  = 5
+	miUINT32 = 6
+	miDOUBLE = 9
+	miMATRIX = 14
+
+	mxSTRUCT = 2
+	mxCHAR   = 4
+	mxDOUBLE = 6
+)
+
+// MATMaxBytes 单个 MAT v5 变量的最大字节数(数据元素长度字段为 32 位)
+const MATMaxBytes = math.MaxUint32 - 64
+
+// MATValue MAT 文件中的数组值; 数值向量按需取值, 写出时不需要整块内存
+type MATValue interface {
+	// size 返回 miMATRIX 元素内容的字节数(不含 8 字节标签)
+	size() int64
+	write(w *bufio.Writer, name string) error
+}
+
+// MATVariable 顶层变量
+type MATVariable struct {
+	Name  string
+	Value MATValue
+}
+
+// matDouble 实数双精度矩阵, at(i) 按列优先顺序返回第 i 个元素
+type matDouble struct {
+	rows, cols int
+	at         func(i int) float64
+}
+
+// MATVector 返回 n×1 列向量, 元素由 at 按需计算
+func MATVector(n int, at func(i int) float64) MATValue {
+	return matDouble{rows: n, cols: 1, at: at}
+}
+
+// MATMatrix 返回 rows×cols 矩阵, data 为按行排列的元素
+func MATMatrix(rows, cols int, data []float64) MATValue {
+	return matDouble{rows: rows, cols: cols, at: func(i int) float64 { return data[(i%rows)*cols+i/rows] }}
+}
+
+// MATScalar 返回 1×1 数值
+func MATScalar(v float64) MATValue {
+	return matDouble{rows: 1, cols: 1, at: func(int) float64 { return v }}
+}
+
+func (m matDouble) size() int64 {
+	return matHeaderSize(0) + 8 + pad8(int64(m.rows*m.cols)*8)
+}
+
+func (m matDouble) write(w *bufio.Writer, name string) error {
+	writeMatrixHeader(w, m.size(), mxDOUBLE, m.rows, m.cols, name)
+	n := m.rows * m.cols
+	writeTag(w, miDOUBLE, n*8)
+	var b [8]byte
+	for i := range n {
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(m.at(i)))
+		if _, err := w.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return writePadding(w, int64(n)*8)
+}
+
+// matChar 字符数组, 以 UTF-16 码元存储以支持中文
+type matChar []uint16
+
+// MATString 返回 1×n 字符数组, 空字符串为 0×0
+func MATString(s string) MATValue {
+	return matChar(utf16.Encode([]rune(s)))
+}
+
+func (m matChar) size() int64 {
+	return matHeaderSize(0) + 8 + pad8(int64(len(m))*2)
+}
+
+func (m matChar) write(w *bufio.Writer, name string) error {
+	rows := min(1, len(m))
+	writeMatrixHeader(w, m.size(), mxCHAR, rows, len(m), name)
+	writeTag(w, miUINT16, len(m)*2)
+	for _, u := range m {
+		binary.Write(w, binary.LittleEndian, u)
+	}
+	return writePadding(w, int64(len(m))*2)
+}
+
+// matStruct 1×N 结构体数组
+type matStruct struct {
+	fields   []string
+	elements [][]MATValue
+}
+
+// MATStruct 返回 1×len(elements) 的结构体数组, 每个元素按 fields 顺序给出各字段的值
+func MATStruct(fields []string, elements ...[]MATValue) MATValue {
+	return matStruct{fields: fields, elements: elements}
+}
+
+// fieldNameLength 字段名槽位长度(含结尾 NUL), 不小于 MATLAB 常用的 32
+func (m matStruct) fieldNameLength() int {
+	n := 32
+	for _, f := range m.fields {
+		n = max(n, len(f)+1)
+	}
+	return n
+}
+
+func (m matStruct) size() int64 {
+	s := matHeaderSize(0) + 16 + 8 + pad8(int64(len(m.fields)*m.fieldNameLength()))
+	for _, el := range m.elements {
+		for _, v := range el {
+			s += 8 + v.size()
+		}
+	}
+	return s
+}
+
+func (m matStruct) write(w *bufio.Writer, name string) error {
+	writeMatrixHeader(w, m.size(), mxSTRUCT, 1, len(m.elements), name)
+	fl := m.fieldNameLength()
+	writeTag(w, miINT32, 4)
+	binary.Write(w, binary.LittleEndian, int32(fl))
+	writePadding(w, 4)
+
+	writeTag(w, miINT8, len(m.fields)*fl)
+	for _, f := range m.fields {
+		slot := make([]byte, fl)
+		copy(slot, f)
+		w.Write(slot)
+	}
+	writePadding(w, int64(len(m.fields)*fl))
+
+	for _, el := range m.elements {
+		for _, v := range el {
+			if err := v.write(w, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func pad8(n int64) int64 {
+	return (n + 7) &^ 7
+}
+
+// matHeaderSize 数组标志、维度与名称子元素的字节数
+func matHeaderSize(nameLen int) int64 {
+	return 16 + 16 + 8 + pad8(int64(nameLen))
+}
+
+func writeTag(w *bufio.Writer, typ uint32, n int) {
+	binary.Write(w, binary.LittleEndian, [2]uint32{typ, uint32(n)})
+}
+
+func writePadding(w *bufio.Writer, n int64) error {
+	_, err := w.Write(make([]byte, pad8(n)-n))
+	return err
+}
+
+// writeMatrixHeader 写出 miMATRIX 标签及数组标志、维度、名称; size 为不含名称的内容长度
+func writeMatrixHeader(w *bufio.Writer, size int64, class uint32, rows, cols int, name string) {
+	writeTag(w, miMATRIX, int(size+pad8(int64(len(name)))))
+	writeTag(w, miUINT32, 8)
+	binary.Write(w, binary.LittleEndian, [2]uint32{class, 0})
+	writeTag(w, miINT32, 8)
+	binary.Write(w, binary.LittleEndian, [2]int32{int32(rows), int32(cols)})
+	writeTag(w, miINT8, len(name))
+	w.WriteString(name)
+	writePadding(w, int64(len(name)))
+}
+
+// Bytes 返回变量数据元素的字节数(不含 8 字节标签), 超过 MATMaxBytes 时无法写出
+func (v MATVariable) Bytes() int64 {
+	return v.Value.size() + pad8(int64(len(v.Name)))
+}
+
+// MATFileSize 返回写出全部变量后的文件字节数
+func MATFileSize(vars []MATVariable) int64 {
+	n := int64(128)
+	for _, v := range vars {
+		n += 8 + v.Bytes()
+	}
+	return n
+}
+
+// WriteMAT 按 MAT v5 格式(小端, 不压缩)写出变量, 可由 MATLAB load 与 scipy.io.loadmat 读取
+func WriteMAT(w io.Writer, vars []MATVariable) error {
+	for _, v := range vars {
+		if v.Bytes() > MATMaxBytes {
+			return fmt.Errorf("mat variable %s exceeds %d bytes", v.Name, int64(MATMaxBytes))
+		}
+	}
+
+	bw := bufio.NewWriterSize(w, 64<<10)
+	header := make([]byte, 128)
+	// 116 字节说明文本(空格填充) + 8 字节子系统数据偏移(0) + 版本 + 字节序标识
+	for i := range 116 {
+		header[i] = ' '
+	}
+	copy(header, fmt.Sprintf("MATLAB 5.0 MAT-file, Platform: GLNXA64, Created on: %s", time.Now().Format("Mon Jan _2 15:04:05 2006")))
+	binary.LittleEndian.PutUint16(header[124:], 0x0100)
+	header[126], header[127] = 'I', 'M'
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	for _, v := range vars {
+		if err := v.Value.write(bw, v.Name); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,405 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ParquetKind 列的逻辑类型
+type ParquetKind int
+
+const (
+	ParquetString    ParquetKind = iota // BYTE_ARRAY, UTF8
+	ParquetDouble                       // DOUBLE
+	ParquetTimestamp                    // INT64, TIMESTAMP(MICROS), 录波时间为当地时间, 不做时区换算
+	ParquetInt32                        // INT32
+)
+
+const (
+	ParquetCompressionNone = "none"
+	ParquetCompressionGzip = "gzip"
+)
+
+// ParquetDefaultRowGroupRows 缺省的行组行数, 决定写出时缓存的数据量
+const ParquetDefaultRowGroupRows = 1 << 16
+
+// parquet.thrift 中的枚举值
+const (
+	parquetTypeInt32     = 1
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8 = 0
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetCodecGzip         = 2
+
+	parquetPageData = 0
+)
+
+// ParquetField 列定义, 所有列均为 REQUIRED 的扁平列
+type ParquetField struct {
+	Name string
+	Kind ParquetKind
+}
+
+func (f ParquetField) physicalType() int32 {
+	switch f.Kind {
+	case ParquetString:
+		return parquetTypeByteArray
+	case ParquetTimestamp:
+		return parquetTypeInt64
+	case ParquetInt32:
+		return parquetTypeInt32
+	default:
+		return parquetTypeDouble
+	}
+}
+
+// ParquetOptions 写出选项
+//   - RowGroupRows: 每个行组的行数, <=0 时取 ParquetDefaultRowGroupRows
+//   - Compression:  none|gzip, 缺省为 gzip
+//   - Metadata:     写入文件尾的键值元数据
+type ParquetOptions struct {
+	RowGroupRows int
+	Compression  string
+	Metadata     [][2]string
+}
+
+type parquetChunk struct {
+	offset       int64
+	compressed   int64
+	uncompressed int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+	bytes  int64
+}
+
+// ParquetWriter 按行写出 Parquet 文件: 各列以 PLAIN 编码缓存在内存中, 每满一个行组写出一次, 文件尾最后写出
+// 使用方式: 对每一行依次调用 SetXxx 设置各列的值, 再调用 EndRow
+type ParquetWriter struct {
+	w       io.Writer
+	offset  int64
+	fields  []ParquetField
+	opts    ParquetOptions
+	columns []bytes.Buffer
+	rows    int
+	total   int64
+	groups  []parquetRowGroup
+}
+
+// NewParquetWriter 创建 Parquet 写出器并写出文件头
+func NewParquetWriter(w io.Writer, fields []ParquetField, opts ParquetOptions) (*ParquetWriter, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("parquet schema has no columns")
+	}
+	if opts.RowGroupRows <= 0 {
+		opts.RowGroupRows = ParquetDefaultRowGroupRows
+	}
+	if opts.Compression == "" {
+		opts.Compression = ParquetCompressionGzip
+	}
+	if opts.Compression != ParquetCompressionNone && opts.Compression != ParquetCompressionGzip {
+		return nil, fmt.Errorf("unsupported parquet compression %q", opts.Compression)
+	}
+	pw := &ParquetWriter{w: w, fields: fields, opts: opts, columns: make([]bytes.Buffer, len(fields))}
+	if err := pw.write([]byte("PAR1")); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *ParquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// SetString 设置当前行第 col 列(ParquetString)的值
+func (pw *ParquetWriter) SetString(col int, s string) {
+	buf := &pw.columns[col]
+	buf.Write(binary.LittleEndian.AppendUint32(buf.AvailableBuffer(), uint32(len(s))))
+	buf.WriteString(s)
+}
+
+// SetDouble 设置当前行第 col 列(ParquetDouble)的值
+func (pw *ParquetWriter) SetDouble(col int, v float64) {
+	buf := &pw.columns[col]
+	buf.Write(binary.LittleEndian.AppendUint64(buf.AvailableBuffer(), math.Float64bits(v)))
+}
+
+// SetTimestamp 设置当前行第 col 列(ParquetTimestamp)的值, 单位为微秒
+func (pw *ParquetWriter) SetTimestamp(col int, micros int64) {
+	buf := &pw.columns[col]
+	buf.Write(binary.LittleEndian.AppendUint64(buf.AvailableBuffer(), uint64(micros)))
+}
+
+// SetInt32 设置当前行第 col 列(ParquetInt32)的值
+func (pw *ParquetWriter) SetInt32(col int, v int32) {
+	buf := &pw.columns[col]
+	buf.Write(binary.LittleEndian.AppendUint32(buf.AvailableBuffer(), uint32(v)))
+}
+
+// EndRow 结束当前行, 行组已满时写出
+func (pw *ParquetWriter) EndRow() error {
+	pw.rows++
+	if pw.rows >= pw.opts.RowGroupRows {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+// flushRowGroup 将缓存的各列作为一个行组写出, 每列一个数据页
+func (pw *ParquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{rows: int64(pw.rows), chunks: make([]parquetChunk, len(pw.fields))}
+	for i := range pw.fields {
+		data := pw.columns[i].Bytes()
+		page := data
+		if pw.opts.Compression == ParquetCompressionGzip {
+			var zb bytes.Buffer
+			zw := gzip.NewWriter(&zb)
+			zw.Write(data)
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			page = zb.Bytes()
+		}
+
+		var t thriftWriter
+		t.i32(1, parquetPageData)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(page)))
+		t.structBegin(5)
+		t.i32(1, int32(pw.rows))
+		t.i32(2, parquetEncodingPlain)
+		t.i32(3, parquetEncodingRLE)
+		t.i32(4, parquetEncodingRLE)
+		t.structEnd()
+		t.stop()
+
+		chunk := parquetChunk{
+			offset:       pw.offset,
+			compressed:   int64(len(t.buf) + len(page)),
+			uncompressed: int64(len(t.buf) + len(data)),
+		}
+		if err := pw.write(t.buf); err != nil {
+			return err
+		}
+		if err := pw.write(page); err != nil {
+			return err
+		}
+		group.chunks[i] = chunk
+		group.bytes += chunk.uncompressed
+		pw.columns[i].Reset()
+	}
+	pw.groups = append(pw.groups, group)
+	pw.total += int64(pw.rows)
+	pw.rows = 0
+	return nil
+}
+
+// Close 写出剩余行与文件尾(FileMetaData)
+func (pw *ParquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	codec := int32(parquetCodecUncompressed)
+	if pw.opts.Compression == ParquetCompressionGzip {
+		codec = parquetCodecGzip
+	}
+
+	var t thriftWriter
+	t.i32(1, 1)
+
+	t.listBegin(2, thriftStruct, len(pw.fields)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(pw.fields)))
+	t.structEnd()
+	for _, f := range pw.fields {
+		t.elemBegin()
+		t.i32(1, f.physicalType())
+		t.i32(3, parquetRequired)
+		t.binary(4, f.Name)
+		switch f.Kind {
+		case ParquetString:
+			t.i32(6, parquetConvertedUTF8)
+			t.structBegin(10)
+			t.structBegin(1) // STRING
+			t.structEnd()
+			t.structEnd()
+		case ParquetTimestamp:
+			// TIMESTAMP_MICROS 转换类型隐含 UTC, 与当地时间语义不符, 只写逻辑类型
+			t.structBegin(10)
+			t.structBegin(8) // TIMESTAMP
+			t.bool(1, false)
+			t.structBegin(2)
+			t.structBegin(2) // MICROS
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, pw.total)
+
+	t.listBegin(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		t.elemBegin()
+		t.listBegin(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			t.elemBegin()
+			t.i64(2, c.offset)
+			t.structBegin(3)
+			t.i32(1, pw.fields[i].physicalType())
+			t.listBegin(2, thriftI32, 2)
+			t.elemI32(parquetEncodingPlain)
+			t.elemI32(parquetEncodingRLE)
+			t.listBegin(3, thriftBinary, 1)
+			t.elemBinary(pw.fields[i].Name)
+			t.i32(4, codec)
+			t.i64(5, g.rows)
+			t.i64(6, c.uncompressed)
+			t.i64(7, c.compressed)
+			t.i64(9, c.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, g.bytes)
+		t.i64(3, g.rows)
+		t.structEnd()
+	}
+
+	if len(pw.opts.Metadata) > 0 {
+		t.listBegin(5, thriftStruct, len(pw.opts.Metadata))
+		for _, kv := range pw.opts.Metadata {
+			t.elemBegin()
+			t.binary(1, kv[0])
+			t.binary(2, kv[1])
+			t.structEnd()
+		}
+	}
+	t.binary(6, "comtradeviewer")
+	t.stop()
+
+	footer := binary.LittleEndian.AppendUint32(t.buf, uint32(len(t.buf)))
+	footer = append(footer, "PAR1"...)
+	return pw.write(footer)
+}
+
+// Thrift compact protocol 类型
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter Parquet 元数据所需的 Thrift compact protocol 编码子集, 字段需按编号递增写出
+type thriftWriter struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.elemBinary(s)
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+// elemBegin 开始一个嵌套结构体(作为字段或列表元素)
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf = append(t.buf, 0)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xf0|elemType)
+		t.varint(uint64(n))
+	}
+}
+
+func (t *thriftWriter) elemI32(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) elemBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
@@ -0,0 +1,42 @@
+// Package export 将通道数据写出为表格或科学计算文件格式, 各写出器按行流式输出, 不在内存中缓存整个文件
+package export
+
+import "strconv"
+
+// Column 数据列(时间列之外)
+//   - Kind:   analog|digital|derived, 与波形接口的 type 一致
+//   - Number: 通道编号, 派生量为 0
+type Column struct {
+	Name   string
+	Unit   string
+	Phase  string
+	Kind   string
+	Number int
+}
+
+// Header 返回列标题, 有单位时形如 "Ia (kA)"
+func (col Column) Header() string {
+	if col.Unit == "" {
+		return col.Name
+	}
+	return col.Name + " (" + col.Unit + ")"
+}
+
+// Row 一行数据: 时间为 Label(绝对时间字符串)或 Time(相对时间, 毫秒), Label 非空时优先
+type Row struct {
+	Time   float64
+	Label  string
+	Values []float64
+}
+
+// RowWriter 按行写出表格数据
+type RowWriter interface {
+	WriteHeader(timeHeader string, cols []Column) error
+	WriteRow(row Row) error
+	Close() error
+}
+
+// formatFloat 以最短且可还原的形式格式化数值
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,155 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// XLSXMaxRows Excel 工作表的最大行数(含标题行)
+const XLSXMaxRows = 1048576
+
+// xlsxStaticParts 工作簿中除工作表外的固定部件
+var xlsxStaticParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// XLSXWriter 以 Office Open XML 工作簿(单个工作表)按行写出, 字符串使用内联字符串, 无需共享字符串表
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+// NewXLSXWriter 创建 XLSX 写出器, sheetName 为工作表名称(最长 31 个字符)
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(f, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`, xmlEscape(sanitizeSheetName(sheetName))); err != nil {
+		return nil, err
+	}
+
+	// 工作表必须最后写入, 以便逐行流式输出
+	sf, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriterSize(sf, 64<<10)
+	if _, err := sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &XLSXWriter{zw: zw, sheet: sheet}, nil
+}
+
+func (xw *XLSXWriter) WriteHeader(timeHeader string, cols []Column) error {
+	xw.rows++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.rows)
+	xw.writeString(timeHeader)
+	for _, col := range cols {
+		xw.writeString(col.Header())
+	}
+	_, err := xw.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (xw *XLSXWriter) WriteRow(row Row) error {
+	if xw.rows >= XLSXMaxRows {
+		return fmt.Errorf("xlsx sheet row limit %d exceeded", XLSXMaxRows)
+	}
+	xw.rows++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.rows)
+	if row.Label != "" {
+		xw.writeString(row.Label)
+	} else {
+		xw.writeNumber(row.Time)
+	}
+	for _, v := range row.Values {
+		xw.writeNumber(v)
+	}
+	_, err := xw.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Close 结束工作表并写出 zip 目录
+func (xw *XLSXWriter) Close() error {
+	if _, err := xw.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zw.Close()
+}
+
+func (xw *XLSXWriter) writeString(s string) {
+	xw.sheet.WriteString(`<c t="inlineStr"><is><t>`)
+	xw.sheet.WriteString(xmlEscape(s))
+	xw.sheet.WriteString(`</t></is></c>`)
+}
+
+// writeNumber 写出数值单元格, NaN/Inf 写为空单元格
+func (xw *XLSXWriter) writeNumber(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		xw.sheet.WriteString(`<c/>`)
+		return
+	}
+	xw.sheet.WriteString(`<c><v>`)
+	xw.sheet.WriteString(formatFloat(v))
+	xw.sheet.WriteString(`</v></c>`)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// sanitizeSheetName 去除工作表名称中不允许的字符并截断到 31 个字符
+func sanitizeSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/cmplx"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+)
+
+// parseChannelList 解析形如 "1,2,3" 的通道号列表, 忽略非法项并升序排列
+func parseChannelList(s string) []int {
+	out := make([]int, 0)
+	for chID := range strings.SplitSeq(s, ",") {
+		chID = strings.TrimSpace(chID)
+		if chID == "" {
+			continue
+		}
+		chNum, err := strconv.Atoi(chID)
+		if err != nil {
+			continue
+		}
+		out = append(out, chNum)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// parseOrderedChannels 按给定顺序解析通道号列表(用于 A/B/C 三相分组), 任一项非法时返回 nil
+func parseOrderedChannels(s string) []int {
+	out := make([]int, 0, 3)
+	for chID := range strings.SplitSeq(s, ",") {
+		chNum, err := strconv.Atoi(strings.TrimSpace(chID))
+		if err != nil {
+			return nil
+		}
+		out = append(out, chNum)
+	}
+	return out
+}
+
+// parseIndexParam 解析采样序号参数, 缺省或非法时返回 def, 结果限制在 [0, n-1]
+func parseIndexParam(c *gin.Context, name string, def int, n int) int {
+	v := def
+	if s := c.Query(name); s != "" {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			v = int(f)
+		}
+	}
+	return max(0, min(n-1, v))
+}
+
+// parseIndexWindow 解析 startTime/endTime 采样序号窗口, 缺省为整个记录
+func parseIndexWindow(c *gin.Context, n int) (int, int) {
+	start, end := 0, n-1
+	if st := c.Query("startTime"); st != "" {
+		if v, err := strconv.ParseFloat(st, 64); err == nil {
+			start = int(v)
+		}
+	}
+	if et := c.Query("endTime"); et != "" {
+		if v, err := strconv.ParseFloat(et, 64); err == nil {
+			end = int(v)
+		}
+	}
+	start = max(0, start)
+	end = min(n-1, end)
+	if start > end {
+		start, end = 0, n-1
+	}
+	return start, end
+}
+
+// evenIndices 在 [start, end] 内等间隔选取不超过 targetPoints 个采样序号
+func evenIndices(start, end, targetPoints int) []int {
+	step := max(1, (end-start+1)/max(1, targetPoints))
+	out := make([]int, 0, (end-start)/step+1)
+	for i := start; i <= end; i += step {
+		out = append(out, i)
+	}
+	return out
+}
+
+// indicesToTimes 将采样序号映射为毫秒时间
+func indicesToTimes(timestamps []float32, indices []int) []float32 {
+	out := make([]float32, len(indices))
+	for i, idx := range indices {
+		if idx >= 0 && idx < len(timestamps) {
+			out[i] = timestamps[idx]
+		}
+	}
+	return out
+}
+
+// parseAnalogOptions 解析 values=primary|secondary|raw 与 skew=compensate|none 参数, 非法时写入错误响应并返回 false
+func parseAnalogOptions(c *gin.Context) (comtrade.AnalogOptions, bool) {
+	return parseAnalogOptionsWithDefault(c, comtrade.ValueSideRaw)
+}
+
+// parseAnalogOptionsWithDefault 同 parseAnalogOptions, 未指定 values 时使用 defaultSide
+func parseAnalogOptionsWithDefault(c *gin.Context, defaultSide string) (comtrade.AnalogOptions, bool) {
+	side := c.DefaultQuery("values", defaultSide)
+	if !comtrade.IsValidValueSide(side) {
+		writeError(c, http.StatusBadRequest, "INVALID_VALUE_SIDE", "无效的数值侧参数", gin.H{"expected": "primary|secondary|raw"})
+		return comtrade.AnalogOptions{}, false
+	}
+	skew := c.DefaultQuery("skew", "none")
+	if skew != "none" && skew != "compensate" {
+		writeError(c, http.StatusBadRequest, "INVALID_SKEW_MODE", "无效的通道时间偏移补偿参数", gin.H{"expected": "compensate|none"})
+		return comtrade.AnalogOptions{}, false
+	}
+	return comtrade.AnalogOptions{Side: side, CompensateSkew: skew == "compensate"}, true
+}
+
+// trackFrequency 跟踪指定电压通道的频率, 失败时写入错误响应并返回 false
+func trackFrequency(c *gin.Context, meta *comtrade.Metadata, dat *comtrade.ChannelData, channel int, method string, start, end int) (*comtrade.FrequencyTrack, bool) {
+	y, err := comtrade.ScaledAnalogData(meta, dat, channel)
+	if err != nil {
+		writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": channel})
+		return nil, false
+	}
+	track, err := comtrade.EstimateFrequency(y, start, end, meta.SampleRateAt(start), meta.NominalFrequency(), method)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "FREQUENCY_TRACK_FAILED", "频率跟踪失败", gin.H{"detail": err.Error()})
+		return nil, false
+	}
+	return track, true
+}
+
+// saveAutoAnnotations 用新的自动标注替换数据集中同一来源(source)的旧自动标注, 返回新标注ID
+func saveAutoAnnotations(ctx context.Context, stor storage.Storage, id string, source string, anns []map[string]any) ([]string, error) {
+	var out []map[string]any
+	if data, err := readComtradeFile(ctx, stor, id, "annotations.json"); err == nil {
+		_ = json.Unmarshal(data, &out)
+	}
+
+	kept := make([]map[string]any, 0, len(out)+len(anns))
+	for _, a := range out {
+		if v, ok := a["source"].(string); ok && v == source {
+			continue
+		}
+		kept = append(kept, a)
+	}
+
+	ids := make([]string, 0, len(anns))
+	base := time.Now().UnixNano()
+	for i, ann := range anns {
+		annID := strconv.FormatInt(base+int64(i), 10)
+		ann["id"] = annID
+		ann["source"] = source
+		ann["auto"] = true
+		kept = append(kept, ann)
+		ids = append(ids, annID)
+	}
+
+	b, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeComtradeFile(ctx, stor, filepath.Join(id, "annotations.json"), b); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// registerAnalysisRoutes 注册波形分析相关接口
+func registerAnalysisRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	// 频率跟踪
+	r.GET("/api/datasets/:id/frequency", gzip.Gzip(gzip.BestSpeed), func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		channel, err := strconv.Atoi(c.Query("channel"))
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "请通过查询参数channel指定电压通道, 例如?channel=1"})
+			return
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		start, end := parseIndexWindow(c, len(timestamps))
+
+		method := c.DefaultQuery("method", comtrade.FrequencyMethodZeroCrossing)
+		track, ok := trackFrequency(c, meta, dat, channel, method, start, end)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"channel":   channel,
+			"method":    track.Method,
+			"nominal":   track.Nominal,
+			"indices":   track.Indices,
+			"times":     indicesToTimes(timestamps, track.Indices),
+			"frequency": track.Frequency,
+			"dfdt":      track.DfDt,
+			"window":    map[string]int{"start": start, "end": end},
+		})
+	})
+
+	// 基波相量与有效值(DFT/RMS), 可选使用跟踪频率
+	r.GET("/api/datasets/:id/phasors", gzip.Gzip(gzip.BestSpeed), func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		analogChannels := parseChannelList(c.Query("A"))
+		if len(analogChannels) == 0 {
+			writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "请通过查询参数A指定模拟通道, 例如?A=1,2,3"})
+			return
+		}
+
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		targetPoints := 2000
+		if tp := c.Query("targetPoints"); tp != "" {
+			if v, err := strconv.Atoi(tp); err == nil && v > 0 {
+				targetPoints = v
+			}
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		start, end := parseIndexWindow(c, len(timestamps))
+		fs := meta.SampleRateAt(start)
+
+		// 频率来源: nominal(默认) 或 tracked
+		var freq comtrade.FrequencySource = comtrade.FixedFrequency(meta.NominalFrequency())
+		freqMode := c.DefaultQuery("freq", "nominal")
+		switch freqMode {
+		case "nominal":
+		case "tracked":
+			freqChannel := analogChannels[0]
+			if fc := c.Query("freqChannel"); fc != "" {
+				if v, err := strconv.Atoi(fc); err == nil {
+					freqChannel = v
+				}
+			}
+			method := c.DefaultQuery("freqMethod", comtrade.FrequencyMethodZeroCrossing)
+			track, ok := trackFrequency(c, meta, dat, freqChannel, method, start, end)
+			if !ok {
+				return
+			}
+			freq = track
+		default:
+			writeError(c, http.StatusBadRequest, "INVALID_FREQ_MODE", "无效的频率来源", gin.H{"expected": "nominal|tracked"})
+			return
+		}
+
+		indices := evenIndices(start, end, targetPoints)
+		series := make([]map[string]any, 0, len(analogChannels))
+		for _, chNum := range analogChannels {
+			ch, ok := meta.AnalogChannelByNumber(chNum)
+			if !ok {
+				continue
+			}
+			y, unit, err := comtrade.AnalogValues(meta, dat, chNum, opts)
+			if err != nil {
+				continue
+			}
+
+			phasors := comtrade.PhasorSeries(y, indices, fs, freq)
+			magnitude := make([]float64, len(phasors))
+			angle := make([]float64, len(phasors))
+			for i, p := range phasors {
+				magnitude[i] = cmplx.Abs(p)
+				angle[i] = comtrade.PhasorAngleDegrees(p)
+			}
+
+			series = append(series, map[string]any{
+				"channel":   chNum,
+				"name":      ch.ChannelName,
+				"unit":      unit,
+				"rms":       comtrade.RMSSeries(y, indices, fs, freq),
+				"magnitude": magnitude,
+				"angle":     angle,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"freq":    freqMode,
+			"values":  opts.Side,
+			"skew":    opts.CompensateSkew,
+			"nominal": meta.NominalFrequency(),
+			"indices": indices,
+			"times":   indicesToTimes(timestamps, indices),
+			"series":  series,
+			"window":  map[string]int{"start": start, "end": end},
+		})
+	})
+
+	// 单端故障测距: 各回路视在阻抗轨迹与电抗法/Takagi法测距
+	r.GET("/api/datasets/:id/faultlocation", gzip.Gzip(gzip.BestSpeed), func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		voltages := parseOrderedChannels(c.Query("V"))
+		currents := parseOrderedChannels(c.Query("I"))
+		if len(voltages) != 3 || len(currents) != 3 {
+			writeError(c, http.StatusBadRequest, "INVALID_THREE_PHASE_GROUP", "需要指定三相电压与电流通道", gin.H{"hint": "例如?V=1,2,3&I=4,5,6 (按A,B,C顺序)"})
+			return
+		}
+
+		var line comtrade.LineParams
+		for name, dst := range map[string]*float64{"r1": &line.R1, "x1": &line.X1, "r0": &line.R0, "x0": &line.X0, "length": &line.Length} {
+			v, err := strconv.ParseFloat(c.Query(name), 64)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, "INVALID_LINE_PARAMS", "线路参数无效", gin.H{"param": name, "hint": "需提供 r1,x1,r0,x0(一次侧Ω/km) 与 length(km)"})
+				return
+			}
+			*dst = v
+		}
+		if err := line.Validate(); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_LINE_PARAMS", "线路参数无效", gin.H{"detail": err.Error()})
+			return
+		}
+
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+		// 阻抗以一次侧欧姆计算, 未显式指定时按一次值取数
+		if opts.Side == comtrade.ValueSideRaw {
+			opts.Side = comtrade.ValueSidePrimary
+		}
+
+		v, err := comtrade.ThreePhaseGroupPhasors(meta, dat, voltages, opts)
+		if err != nil {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"detail": err.Error()})
+			return
+		}
+		i, err := comtrade.ThreePhaseGroupPhasors(meta, dat, currents, opts)
+		if err != nil {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"detail": err.Error()})
+			return
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		n := min(len(timestamps), len(v[0]))
+		if n == 0 {
+			writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+			return
+		}
+		cycle := int(meta.SampleRateAt(0) / meta.NominalFrequency())
+		trigger := meta.TriggerIndex(timestamps)
+		at := parseIndexParam(c, "at", trigger+2*cycle, n)
+		pre := parseIndexParam(c, "pre", trigger-2*cycle, n)
+
+		targetPoints := 500
+		if tp := c.Query("targetPoints"); tp != "" {
+			if v, err := strconv.Atoi(tp); err == nil && v > 0 {
+				targetPoints = v
+			}
+		}
+		start, end := parseIndexWindow(c, n)
+		indices := evenIndices(start, end, targetPoints)
+
+		k0 := line.K0()
+		loops := make([]map[string]any, 0, len(comtrade.FaultLoops))
+		estimates := make([]comtrade.FaultDistance, 0, len(comtrade.FaultLoops))
+		for _, loop := range comtrade.FaultLoops {
+			rs := make([]float64, len(indices))
+			xs := make([]float64, len(indices))
+			for j, idx := range indices {
+				z := comtrade.LoopImpedance(loop, v, i, k0, idx)
+				rs[j], xs[j] = real(z), imag(z)
+			}
+			loops = append(loops, map[string]any{"loop": loop, "r": rs, "x": xs})
+			estimates = append(estimates, comtrade.EstimateFaultDistance(loop, v, i, line, at, pre))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"line":      line,
+			"k0":        map[string]float64{"re": real(k0), "im": imag(k0)},
+			"at":        at,
+			"pre":       pre,
+			"trigger":   trigger,
+			"indices":   indices,
+			"times":     indicesToTimes(timestamps, indices),
+			"loops":     loops,
+			"estimates": estimates,
+			"window":    map[string]int{"start": start, "end": end},
+		})
+	})
+
+	// 故障类型识别, 结果保存为自动标注
+	r.POST("/api/datasets/:id/faulttype", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		currentChs := parseOrderedChannels(c.Query("I"))
+		if len(currentChs) != 3 {
+			writeError(c, http.StatusBadRequest, "INVALID_THREE_PHASE_GROUP", "需要指定三相电流通道", gin.H{"hint": "例如?I=4,5,6&V=1,2,3 (按A,B,C顺序, V可选)"})
+			return
+		}
+		voltageChs := parseOrderedChannels(c.Query("V"))
+		if c.Query("V") != "" && len(voltageChs) != 3 {
+			writeError(c, http.StatusBadRequest, "INVALID_THREE_PHASE_GROUP", "需要指定三相电压通道", gin.H{"hint": "例如?I=4,5,6&V=1,2,3 (按A,B,C顺序, V可选)"})
+			return
+		}
+
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		var currents, voltages [3][]float64
+		for k, chNum := range currentChs {
+			if currents[k], _, err = comtrade.AnalogValues(meta, dat, chNum, opts); err != nil {
+				writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+				return
+			}
+		}
+		for k, chNum := range voltageChs {
+			if voltages[k], _, err = comtrade.AnalogValues(meta, dat, chNum, opts); err != nil {
+				writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+				return
+			}
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		trigger := meta.TriggerIndex(timestamps)
+		result := comtrade.ClassifyFault(currents, voltages, meta.SampleRateAt(0), meta.NominalFrequency(), trigger)
+
+		response := gin.H{"result": result, "trigger": trigger}
+		if result.Type == "" {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		// 起止时刻: 相对记录开始的毫秒数与绝对时间
+		startMs := float64(timestamps[result.Inception])
+		endMs := float64(timestamps[len(timestamps)-1])
+		if result.Clearance >= 0 {
+			endMs = float64(timestamps[result.Clearance])
+		}
+		inceptionAt := meta.StartTime.Add(time.Duration(startMs * float64(time.Millisecond)))
+		response["inceptionTime"] = startMs
+		response["inceptionAt"] = inceptionAt
+		if result.Clearance >= 0 {
+			response["clearanceTime"] = endMs
+			response["clearanceAt"] = meta.StartTime.Add(time.Duration(endMs * float64(time.Millisecond)))
+			response["durationMs"] = endMs - startMs
+		}
+
+		note := fmt.Sprintf("故障类型: %s", result.Type)
+		if result.Clearance >= 0 {
+			note += fmt.Sprintf(", 持续 %.1f ms", endMs-startMs)
+		}
+		ids, err := saveAutoAnnotations(ctx, stor, id, "faulttype", []map[string]any{{
+			"type":  "range",
+			"start": startMs,
+			"end":   endMs,
+			"note":  note,
+			"fault": result,
+		}})
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, "ANNOTATIONS_WRITE_ERROR", "写入标注失败", gin.H{"detail": err.Error()})
+			return
+		}
+		response["annotationId"] = ids[0]
+
+		c.JSON(http.StatusOK, response)
+	})
+
+	// 开关量变位事件顺序记录(SOE)
+	r.GET("/api/datasets/:id/events", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		digitalChannels := parseChannelList(c.Query("D"))
+		events := comtrade.DigitalEvents(meta, dat, digitalChannels, timestamps)
+
+		// 记录开始时已处于非正常状态的通道
+		initial := make([]map[string]any, 0)
+		for _, ch := range meta.DigitalChannels {
+			if len(digitalChannels) > 0 && !slices.Contains(digitalChannels, ch.ChannelNumber) {
+				continue
+			}
+			y, err := dat.GetDigitalData(ch.ChannelNumber)
+			if err != nil || len(y) == 0 || int(y[0]) == ch.Y {
+				continue
+			}
+			initial = append(initial, map[string]any{"channel": ch.ChannelNumber, "name": ch.ChannelName, "state": y[0], "normalState": ch.Y})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"startTime":       meta.StartTime,
+			"triggerTime":     meta.EndTime,
+			"events":          events,
+			"initialAbnormal": initial,
+		})
+	})
+
+	// 通道统计量(全分辨率)
+	r.GET("/api/datasets/:id/stats", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		analogChannels := parseChannelList(c.Query("A"))
+		digitalChannels := parseChannelList(c.Query("D"))
+		if len(analogChannels) == 0 && len(digitalChannels) == 0 {
+			writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "请通过查询参数A和D指定所需的模拟和数字通道, 例如?A=1,2,3&D=1,2"})
+			return
+		}
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		if len(timestamps) == 0 {
+			writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+			return
+		}
+		start, end := parseIndexWindow(c, len(timestamps))
+
+		analog := make([]map[string]any, 0, len(analogChannels))
+		for _, chNum := range analogChannels {
+			ch, ok := meta.AnalogChannelByNumber(chNum)
+			if !ok {
+				continue
+			}
+			y, unit, err := comtrade.AnalogValues(meta, dat, chNum, opts)
+			if err != nil {
+				continue
+			}
+			analog = append(analog, map[string]any{
+				"channel": chNum,
+				"name":    ch.ChannelName,
+				"unit":    unit,
+				"stats":   comtrade.AnalogStatistics(y, timestamps, start, end, meta.SampleRateAt(start), meta.NominalFrequency()),
+			})
+		}
+
+		digital := make([]map[string]any, 0, len(digitalChannels))
+		for _, chNum := range digitalChannels {
+			ch, ok := meta.DigitalChannelByNumber(chNum)
+			if !ok {
+				continue
+			}
+			y, err := dat.GetDigitalData(chNum)
+			if err != nil {
+				continue
+			}
+			digital = append(digital, map[string]any{
+				"channel": chNum,
+				"name":    ch.ChannelName,
+				"stats":   comtrade.DigitalStatistics(y, timestamps, start, end),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"analog":  analog,
+			"digital": digital,
+			"values":  opts.Side,
+			"skew":    opts.CompensateSkew,
+			"window":  map[string]any{"start": start, "end": end, "startTime": timestamps[start], "endTime": timestamps[end]},
+		})
+	})
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"math"
+	"net/http"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// compareMaxPoints 公共时间轴的最大点数, 超出时自动降低公共采样率
+	compareMaxPoints = 200000
+	// correlationMaxPoints 互相关计算所用网格的最大点数, 超出时以更粗的时间分辨率搜索
+	correlationMaxPoints = 50000
+	// correlationMaxLags 互相关单侧搜索的最大滞后点数, 超出时同样放粗时间分辨率, 计算量不超过约 1e8 次乘加
+	correlationMaxLags = 1000
+	// compareMaxLagMs maxLagMs 的上限(毫秒)
+	compareMaxLagMs = 2000
+)
+
+// compareItem 参与比较的一条记录及其通道
+//   - Offset:             额外叠加的时间偏移(毫秒), 正值表示向后平移
+//   - CorrelationChannel: 参与互相关对齐的模拟量通道, 0 表示该记录不做互相关
+type compareItem struct {
+	DatasetID          string  `json:"datasetId"`
+	A                  []int   `json:"A"`
+	D                  []int   `json:"D"`
+	Offset             float64 `json:"offset"`
+	CorrelationChannel int     `json:"correlationChannel"`
+}
+
+// compareRequest 多记录比较请求体
+//   - Align:     absolute|trigger|offset, 默认 trigger
+//   - Rate:      公共采样率(Hz), 默认取各记录中最高的采样率
+//   - MaxLagMs:  互相关搜索范围(毫秒), 默认 100, 不超过 compareMaxLagMs
+//   - StartTime/EndTime: 公共时间轴上的窗口(毫秒), 缺省为所有记录的并集
+type compareRequest struct {
+	Items     []compareItem `json:"items"`
+	Align     string        `json:"align"`
+	Rate      float64       `json:"rate"`
+	MaxLagMs  float64       `json:"maxLagMs"`
+	StartTime *float64      `json:"startTime"`
+	EndTime   *float64      `json:"endTime"`
+}
+
+// compareRecord 已加载并对齐的记录
+type compareRecord struct {
+	item  compareItem
+	meta  *comtrade.Metadata
+	dat   *comtrade.ChannelData
+	times []float32
+	shift float64
+}
+
+// registerCompareRoutes 注册多记录比较接口
+func registerCompareRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	r.POST("/api/compare", gzip.Gzip(gzip.BestSpeed), func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req compareRequest
+		if err := c.BindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "BAD_JSON", "JSON格式错误", gin.H{"detail": err.Error()})
+			return
+		}
+		if len(req.Items) == 0 {
+			writeError(c, http.StatusBadRequest, "NO_DATASETS_SPECIFIED", "未指定参与比较的数据集", gin.H{"hint": "例如 {\"items\":[{\"datasetId\":\"...\",\"A\":[1,2,3]},{\"datasetId\":\"...\",\"A\":[1,2,3]}],\"align\":\"trigger\"}"})
+			return
+		}
+		if req.Align == "" {
+			req.Align = comtrade.AlignTrigger
+		}
+		if !comtrade.IsValidAlignMode(req.Align) {
+			writeError(c, http.StatusBadRequest, "INVALID_ALIGN_MODE", "无效的对齐方式", gin.H{"expected": "absolute|trigger|offset"})
+			return
+		}
+		if req.MaxLagMs <= 0 {
+			req.MaxLagMs = 100
+		}
+		if req.MaxLagMs > compareMaxLagMs {
+			writeError(c, http.StatusBadRequest, "INVALID_MAX_LAG", "互相关搜索范围过大", gin.H{"maxLagMs": req.MaxLagMs, "limit": compareMaxLagMs})
+			return
+		}
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		// 1. 加载记录并按对齐方式计算时间偏移
+		records := make([]*compareRecord, len(req.Items))
+		var earliest *comtrade.Metadata
+		for i, item := range req.Items {
+			meta, dat, err := parseComtrade(cache, stor, item.DatasetID, ctx, c)
+			if err != nil {
+				status, code, msg, details := parseErrorResponse(err)
+				details["datasetId"] = item.DatasetID
+				writeError(c, status, code, msg, details)
+				return
+			}
+			if len(dat.Timestamps) == 0 {
+				writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": item.DatasetID})
+				return
+			}
+			records[i] = &compareRecord{
+				item:  item,
+				meta:  meta,
+				dat:   dat,
+				times: comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps)),
+			}
+			if earliest == nil || meta.StartTime.Before(earliest.StartTime) {
+				earliest = meta
+			}
+		}
+
+		rate := req.Rate
+		if rate <= 0 {
+			for _, rec := range records {
+				rate = math.Max(rate, rec.meta.SampleRateAt(0))
+			}
+		}
+		for _, rec := range records {
+			rec.shift = comtrade.AlignmentShift(rec.meta, req.Align, earliest) + rec.item.Offset
+		}
+
+		// 2. 公共时间轴: 各记录对齐后时间范围的并集, 可由 startTime/endTime 截取
+		start, end := math.Inf(1), math.Inf(-1)
+		for _, rec := range records {
+			start = math.Min(start, float64(rec.times[0])+rec.shift)
+			end = math.Max(end, float64(rec.times[len(rec.times)-1])+rec.shift)
+		}
+		if req.StartTime != nil {
+			start = math.Max(start, *req.StartTime)
+		}
+		if req.EndTime != nil {
+			end = math.Min(end, *req.EndTime)
+		}
+		if end <= start {
+			writeError(c, http.StatusBadRequest, "EMPTY_COMPARE_WINDOW", "比较窗口为空", gin.H{"start": start, "end": end})
+			return
+		}
+		if (end-start)*rate/1000 > compareMaxPoints {
+			rate = compareMaxPoints * 1000 / (end - start)
+		}
+		step := 1000 / rate
+
+		// 3. 互相关对齐: 以第一个指定了相关通道的记录为参考
+		correlations := make([]map[string]any, len(records))
+		var reference []float64
+		corrStep := max(step, (end-start)/correlationMaxPoints, req.MaxLagMs/correlationMaxLags)
+		corrGrid := comtrade.UniformTimes(start, end, corrStep)
+		for i, rec := range records {
+			if rec.item.CorrelationChannel <= 0 {
+				continue
+			}
+			y, _, err := comtrade.AnalogValues(rec.meta, rec.dat, rec.item.CorrelationChannel, opts)
+			if err != nil {
+				writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"datasetId": rec.item.DatasetID, "channel": rec.item.CorrelationChannel})
+				return
+			}
+			resampled := comtrade.InterpolateLinear(comtrade.TimesToFloat64(rec.times, rec.shift), y, corrGrid)
+			if reference == nil {
+				reference = resampled
+				correlations[i] = map[string]any{"reference": true}
+				continue
+			}
+			lag, coeff := comtrade.CrossCorrelationLag(reference, resampled, int(req.MaxLagMs/corrStep))
+			rec.shift -= float64(lag) * corrStep
+			correlations[i] = map[string]any{"lagMs": float64(lag) * corrStep, "coefficient": coeff}
+		}
+
+		// 4. 重采样到公共时间轴, 每条序列只覆盖其所属记录的时间范围
+		times := comtrade.UniformTimes(start, end, step)
+		items := make([]map[string]any, 0, len(records))
+		series := make([]map[string]any, 0)
+		for i, rec := range records {
+			srcTimes := comtrade.TimesToFloat64(rec.times, rec.shift)
+			first := max(0, int(math.Ceil((srcTimes[0]-start)/step-1e-9)))
+			last := min(len(times)-1, int(math.Floor((srcTimes[len(srcTimes)-1]-start)/step+1e-9)))
+
+			info := map[string]any{
+				"datasetId": rec.item.DatasetID,
+				"station":   rec.meta.Station,
+				"startTime": rec.meta.StartTime,
+				"shift":     rec.shift,
+			}
+			if correlations[i] != nil {
+				info["correlation"] = correlations[i]
+			}
+			items = append(items, info)
+			if first > last {
+				continue
+			}
+			grid := times[first : last+1]
+
+			for _, chNum := range rec.item.A {
+				ch, ok := rec.meta.AnalogChannelByNumber(chNum)
+				if !ok {
+					continue
+				}
+				y, unit, err := comtrade.AnalogValues(rec.meta, rec.dat, chNum, opts)
+				if err != nil {
+					continue
+				}
+				series = append(series, map[string]any{
+					"datasetId": rec.item.DatasetID,
+					"channel":   chNum,
+					"type":      "analog",
+					"name":      ch.ChannelName,
+					"unit":      unit,
+					"offset":    first,
+					"y":         comtrade.InterpolateLinear(srcTimes, y, grid),
+				})
+			}
+			for _, chNum := range rec.item.D {
+				ch, ok := rec.meta.DigitalChannelByNumber(chNum)
+				if !ok {
+					continue
+				}
+				y, err := rec.dat.GetDigitalData(chNum)
+				if err != nil {
+					continue
+				}
+				series = append(series, map[string]any{
+					"datasetId": rec.item.DatasetID,
+					"channel":   chNum,
+					"type":      "digital",
+					"name":      ch.ChannelName,
+					"offset":    first,
+					"y":         comtrade.InterpolateHold(srcTimes, y, grid),
+				})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"align":  req.Align,
+			"rate":   rate,
+			"start":  start,
+			"step":   step,
+			"times":  times,
+			"items":  items,
+			"series": series,
+			"values": opts.Side,
+			"skew":   opts.CompensateSkew,
+		})
+	})
+}
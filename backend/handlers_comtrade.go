@@ -10,7 +10,6 @@ import (
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -59,14 +58,17 @@ func listDatasets(stor storage.Storage) ([]DatasetInfo, error) {
 		parts := strings.Split(file, "/")
 		if len(parts) >= 2 {
 			id := parts[0]
-			if id == "" || id == "." {
+			// 以下划线开头的目录保存全局数据(如报告模板), 不是数据集
+			if id == "" || id == "." || strings.HasPrefix(id, "_") {
 				continue
 			}
 			size, _ := stor.GetFileSize(ctx, file)
 			info := datasets[id]
 			info.DatasetID = id
-			// remove extension for name
-			info.Name = strings.TrimSuffix(parts[1], filepath.Ext(parts[1]))
+			// 名称取自 CFG 文件名(去掉扩展名), 不受 derived.json 等附属文件影响
+			if strings.EqualFold(filepath.Ext(parts[1]), ".cfg") || info.Name == "" {
+				info.Name = strings.TrimSuffix(parts[1], filepath.Ext(parts[1]))
+			}
 			info.SizeBytes += size
 			datasets[id] = info
 		}
@@ -129,6 +131,19 @@ func writeComtradeFile(ctx context.Context, stor storage.Storage, path string, d
 	return stor.SaveFile(ctx, path, bytes.NewReader(data))
 }
 
+// loadDataset 从存储读取并解析数据集
+func loadDataset(ctx context.Context, stor storage.Storage, id string) (*comtrade.Metadata, *comtrade.ChannelData, error) {
+	cfgBytes, err := readComtradeFile(ctx, stor, id, "cfg")
+	if err != nil {
+		return nil, nil, err
+	}
+	datBytes, err := readComtradeFile(ctx, stor, id, "dat")
+	if err != nil {
+		return nil, nil, err
+	}
+	return comtrade.ParseComtradeFromBytes(cfgBytes, datBytes)
+}
+
 func parseComtrade(cache *comtrade.DatasetCache, stor storage.Storage, id string, ctx context.Context, c *gin.Context) (*comtrade.Metadata, *comtrade.ChannelData, error) {
 	var meta *comtrade.Metadata
 	var dat *comtrade.ChannelData
@@ -141,29 +156,62 @@ func parseComtrade(cache *comtrade.DatasetCache, stor storage.Storage, id string
 	} else {
 		fmt.Printf("Cache miss for dataset %s, parsing from storage\n", id)
 
-		cfgBytes, err := readComtradeFile(ctx, stor, id, "cfg")
+		m, d, err := loadDataset(ctx, stor, id)
 		if err != nil {
 			return nil, nil, err
 		}
+		meta = m
+		dat = d
 
-		datBytes, err := readComtradeFile(ctx, stor, id, "dat")
+		cache.Set(id, meta, dat)
+	}
+
+	// 按 rate=(Hz)&resample=linear|sinc 重采样为单一采样率, 结果同样缓存
+	if c != nil && c.Query("rate") != "" {
+		rate, err := strconv.ParseFloat(c.Query("rate"), 64)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, fmt.Errorf("%w: rate %q is not a number", comtrade.ErrInvalidResample, c.Query("rate"))
 		}
-
-		m, d, err := comtrade.ParseComtradeFromBytes(cfgBytes, datBytes)
+		method := c.DefaultQuery("resample", comtrade.ResampleMethodLinear)
+		if !comtrade.IsValidResampleMethod(method) {
+			return nil, nil, fmt.Errorf("%w: unsupported method %q", comtrade.ErrInvalidResample, method)
+		}
+		key := fmt.Sprintf("%s@%g/%s", id, rate, method)
+		if cachedMeta, cachedDat, ok := cache.Get(key); ok {
+			return cachedMeta, cachedDat, nil
+		}
+		m, d, err := comtrade.ResampleDataset(meta, dat, rate, method)
 		if err != nil {
 			return nil, nil, err
 		}
-		meta = m
-		dat = d
-
-		cache.Set(id, meta, dat)
+		cache.Set(key, m, d)
+		meta, dat = m, d
 	}
 
 	return meta, dat, nil
 }
 
+// removeDataset 删除数据集目录下的全部文件并清除其解析缓存, cache 可为 nil
+func removeDataset(ctx context.Context, stor storage.Storage, cache *comtrade.DatasetCache, id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid dataset id %q", id)
+	}
+	if cache != nil {
+		cache.Delete(id)
+	}
+	// 以 "id/" 为前缀列出, 避免对象存储按前缀匹配到其他 ID(如 "1" 匹配 "10")
+	files, err := stor.ListFiles(ctx, id+"/")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := stor.DeleteFile(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // registerComtradeRoutes 注册与 COMTRADE 相关的所有接口
 func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 	// Initialize LRU cache: keep last 10 datasets in memory
@@ -203,11 +251,30 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 			return
 		}
 		if err := saveUploadedFileToStorage(ctx, stor, fh, "dat", datasetID); err != nil {
-			writeError(c, http.StatusBadRequest, "DAT_SAVE_FAILED", "保存数据文件失败", gin.H{"detail": err.Error()})
+			// 已保存的 CFG 一并删除, 避免留下不完整的数据集
+			details := gin.H{"detail": err.Error()}
+			if err := removeDataset(ctx, stor, cache, datasetID); err != nil {
+				details["cleanup"] = err.Error()
+			}
+			writeError(c, http.StatusBadRequest, "DAT_SAVE_FAILED", "保存数据文件失败", details)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"datasetId": datasetID, "name": datasetID})
+		// 清单: 记录原始文件名, CFG 文件名符合 C37.232 规则时解析字段并与 CFG 核对;
+		// 清单是辅助信息, 生成或保存失败时数据集仍然可用, 只在 warnings 中提示
+		var comname *comtrade.COMName
+		warnings := make([]string, 0)
+		if cfgData, err := readComtradeFile(ctx, stor, datasetID, "cfg"); err != nil {
+			warnings = append(warnings, "读取配置文件失败, 未生成数据集清单: "+err.Error())
+		} else {
+			manifest := buildManifest(fh.File["cfg"][0].Filename, fh.File["dat"][0].Filename, cfgData)
+			comname, warnings = manifest.COMName, manifest.Warnings
+			if err := saveManifest(ctx, stor, datasetID, manifest); err != nil {
+				warnings = append(warnings, "保存数据集清单失败, 导出时无法使用文件名中的 COMNAME 字段: "+err.Error())
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"datasetId": datasetID, "name": datasetID, "comname": comname, "warnings": warnings})
 	})
 
 	// 数据集列表
@@ -252,8 +319,7 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 
 		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
 		if err != nil {
-			code, msg, details := toFriendlyParseError(err)
-			writeError(c, http.StatusInternalServerError, code, msg, details)
+			writeParseError(c, err)
 			return
 		}
 
@@ -266,43 +332,21 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 		}
 
 		// 解析通道参数
-		analogChsStr := c.Query("A")
-		analogChannels := make([]int, 0)
-		if analogChsStr != "" {
-			chs := strings.SplitSeq(analogChsStr, ",")
-			for chID := range chs {
-				chID = strings.TrimSpace(chID)
-				if chID == "" {
-					continue
-				}
-				chNum, err := strconv.Atoi(chID)
-				if err != nil {
-					continue
-				}
-				analogChannels = append(analogChannels, chNum)
-			}
-			sort.Ints(analogChannels)
+		analogChannels := parseChannelList(c.Query("A"))
+		digitalChannels := parseChannelList(c.Query("D"))
+
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
 		}
 
-		digitalChsStr := c.Query("D")
-		digitalChannels := make([]int, 0)
-		if digitalChsStr != "" {
-			chs := strings.SplitSeq(digitalChsStr, ",")
-			for chID := range chs {
-				chID = strings.TrimSpace(chID)
-				if chID == "" {
-					continue
-				}
-				chNum, err := strconv.Atoi(chID)
-				if err != nil {
-					continue
-				}
-				digitalChannels = append(digitalChannels, chNum)
-			}
-			sort.Ints(digitalChannels)
+		// 派生量: 功率、表达式派生通道、Clarke/Park 变换
+		derived, ok := collectDerivedSeries(c, stor, id, meta, dat, opts)
+		if !ok {
+			return
 		}
 
-		if len(analogChannels) == 0 && len(digitalChannels) == 0 {
+		if len(analogChannels) == 0 && len(digitalChannels) == 0 && len(derived) == 0 {
 			writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "请通过查询参数A和D指定所需的模拟和数字通道, 例如?A=1,2,3&D=1,2"})
 			return
 		}
@@ -370,61 +414,34 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 		series := make([]map[string]any, 0, len(analogChannels)+len(digitalChannels))
 
 		// 模拟量
-		for index, chData := range dat.AnalogChannels {
-			if len(analogChannels) == 0 {
-				break
-			}
-
-			found := false
-			for _, chNum := range analogChannels {
-				if chData.ChannelNumber == chNum {
-					found = true
-					analogChannels = removeInt(analogChannels, chNum)
-					break
-				}
-			}
-			if !found {
+		for _, chNum := range analogChannels {
+			ch, ok := meta.AnalogChannelByNumber(chNum)
+			if !ok {
 				continue
 			}
-
-			chNum := chData.ChannelNumber
-
-			sampleLen := max(len(chData.RawData), len(chData.RawDataFloat))
-			y := make([]float64, sampleLen)
-
-			var multiplier, offset float64 = 1.0, 0.0
-			if index < len(meta.AnalogChannels) {
-				ch := meta.AnalogChannels[index]
-				multiplier = ch.Multiplier
-				offset = ch.Offset
-			}
-
-			if len(chData.RawDataFloat) == sampleLen {
-				for i, d := range chData.RawDataFloat {
-					y[i] = float64(d)*multiplier + offset
-				}
-			} else {
-				for i, d := range chData.RawData {
-					y[i] = float64(d)*multiplier + offset
-				}
+			y, unit, err := comtrade.AnalogValues(meta, dat, chNum, opts)
+			if err != nil {
+				continue
 			}
 
 			var rangeY []float64
 			for _, idx := range timeIndices {
-				rangeY = append(rangeY, y[idx])
+				if idx < len(y) {
+					rangeY = append(rangeY, y[idx])
+				}
 			}
 
-			returnTimes := timeIndices
+			returnTimes := timeIndices[:len(rangeY)]
 			returnY := rangeY
-			if needDownsample && len(timeIndices) > 0 {
-				returnTimes, returnY = comtrade.DownsampleLTTB(timestamps, timeIndices, rangeY, targetPoints)
+			if needDownsample && len(rangeY) > 0 {
+				returnTimes, returnY = comtrade.DownsampleLTTB(timestamps, returnTimes, rangeY, targetPoints)
 			}
 
 			series = append(series, map[string]any{
 				"channel": chNum,
 				"type":    "analog",
-				"name":    meta.AnalogChannels[index].ChannelName,
-				"unit":    meta.AnalogChannels[index].Unit,
+				"name":    ch.ChannelName,
+				"unit":    unit,
 				"times":   returnTimes,
 				"y":       returnY,
 			})
@@ -475,12 +492,41 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 			})
 		}
 
+		// 派生量: 表达式可能产生 NaN/±Inf(如除数过零、log 负数), JSON 无法表示, 这些点连同时间一起丢弃
+		for _, d := range derived {
+			var rangeY []float64
+			var rangeTimes []int
+			for _, idx := range timeIndices {
+				if idx < len(d.Y) && !math.IsNaN(d.Y[idx]) && !math.IsInf(d.Y[idx], 0) {
+					rangeY = append(rangeY, d.Y[idx])
+					rangeTimes = append(rangeTimes, idx)
+				}
+			}
+
+			returnTimes := rangeTimes
+			returnY := rangeY
+			if needDownsample && len(rangeY) > 0 {
+				returnTimes, returnY = comtrade.DownsampleLTTB(timestamps, returnTimes, rangeY, targetPoints)
+			}
+
+			series = append(series, map[string]any{
+				"channel": d.ID,
+				"type":    "derived",
+				"name":    d.Name,
+				"unit":    d.Unit,
+				"times":   returnTimes,
+				"y":       returnY,
+			})
+		}
+
 		response := gin.H{
 			"series": series,
 			"times":  timestamps,
 			"window": map[string]int{"start": startTimeIndex, "end": endTimeIndex},
 		}
 
+		response["values"] = opts.Side
+		response["skew"] = opts.CompensateSkew
 		response["downsample"] = map[string]any{
 			"method":       downsampleMethod,
 			"targetPoints": targetPoints,
@@ -496,8 +542,7 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 
 		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
 		if err != nil {
-			code, msg, details := toFriendlyParseError(err)
-			writeError(c, http.StatusInternalServerError, code, msg, details)
+			writeParseError(c, err)
 			return
 		}
 
@@ -649,6 +694,18 @@ func registerComtradeRoutes(r *gin.Engine, stor storage.Storage) {
 
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 	})
+
+	registerAnalysisRoutes(r, stor, cache)
+	registerDerivedRoutes(r, stor, cache)
+	registerProtectionRoutes(r, stor, cache)
+	registerCompareRoutes(r, stor, cache)
+	registerDetectionRoutes(r, stor, cache)
+	registerExportRoutes(r, stor, cache)
+	registerRenderRoutes(r, stor, cache)
+	registerReportRoutes(r, stor, cache)
+	registerConvertRoutes(r, stor, cache)
+	registerImportRoutes(r, stor)
+	registerManifestRoutes(r, stor)
 }
 
 func removeInt(source []int, target int) []int {
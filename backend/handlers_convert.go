@@ -0,0 +1,162 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// convertedFiles 转换后的 CFG 与 DAT 文件内容
+type convertedFiles struct {
+	cfg, dat []byte
+	report   *comtrade.ConversionReport
+}
+
+// convertDataset 将原始记录(不做重采样)转换为指定版本与数据文件格式并编码
+func convertDataset(meta *comtrade.Metadata, dat *comtrade.ChannelData, revision, fileType string) (*convertedFiles, error) {
+	outMeta, outDat, report, err := comtrade.Convert(meta, dat, revision, fileType)
+	if err != nil {
+		return nil, err
+	}
+	files, err := encodeDataset(outMeta, outDat)
+	if err != nil {
+		return nil, err
+	}
+	files.report = report
+	return files, nil
+}
+
+// encodeDataset 按记录自身的版本与数据文件格式写出 CFG 与 DAT
+func encodeDataset(meta *comtrade.Metadata, dat *comtrade.ChannelData) (*convertedFiles, error) {
+	var cfg, datBuf bytes.Buffer
+	if err := comtrade.WriteCFG(&cfg, meta); err != nil {
+		return nil, err
+	}
+	if err := comtrade.WriteDAT(&datBuf, meta, dat); err != nil {
+		return nil, err
+	}
+	return &convertedFiles{cfg: cfg.Bytes(), dat: datBuf.Bytes()}, nil
+}
+
+// saveConvertedDataset 将 CFG 与 DAT 保存为新的数据集 <id>/<name>.cfg|.dat, 返回数据集 ID
+func saveConvertedDataset(ctx context.Context, stor storage.Storage, name string, files *convertedFiles) (string, error) {
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := writeComtradeFile(ctx, stor, path.Join(id, name+".cfg"), files.cfg); err != nil {
+		return "", err
+	}
+	if err := writeComtradeFile(ctx, stor, path.Join(id, name+".dat"), files.dat); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// conversionTarget 解析目标版本与数据文件格式, 非法时写入错误响应并返回 false
+func conversionTarget(c *gin.Context, revision, fileType string) (string, string, bool) {
+	fileType = strings.ToLower(strings.TrimSpace(fileType))
+	if err := comtrade.CheckConversion(revision, fileType); err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_CONVERSION", "不支持的版本或数据文件格式", gin.H{
+			"detail": err.Error(),
+			"hint":   "1991与1999版支持ascii|binary, 2013版另支持binary32|float32",
+		})
+		return "", "", false
+	}
+	return revision, fileType, true
+}
+
+// registerConvertRoutes 注册 COMTRADE 版本与数据文件格式转换接口
+func registerConvertRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	// 转换为新的数据集, 原数据集不变; dryRun 时只返回转换报告
+	r.POST("/api/datasets/:id/convert", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		var req struct {
+			Revision     string `json:"revision"`
+			DataFileType string `json:"dataFileType"`
+			DryRun       bool   `json:"dryRun"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "BAD_JSON", "JSON格式错误", gin.H{"detail": err.Error()})
+			return
+		}
+		revision, fileType, ok := conversionTarget(c, req.Revision, req.DataFileType)
+		if !ok {
+			return
+		}
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, nil)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+		files, err := convertDataset(meta, dat, revision, fileType)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "CONVERSION_FAILED", "转换失败", gin.H{"detail": err.Error()})
+			return
+		}
+		if req.DryRun {
+			c.JSON(http.StatusOK, gin.H{"report": files.report})
+			return
+		}
+
+		base := datasetBaseName(ctx, stor, id)
+		newID, err := saveConvertedDataset(ctx, stor, base, files)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, "DATASET_SAVE_FAILED", "保存数据集失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"datasetId": newID, "name": base, "report": files.report})
+	})
+
+	// 下载转换后的 CFG 与 DAT(zip)
+	r.GET("/api/datasets/:id/export/comtrade", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		revision, fileType, ok := conversionTarget(c, c.DefaultQuery("revision", "1999"), c.DefaultQuery("dataFileType", "binary"))
+		if !ok {
+			return
+		}
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, nil)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+		files, err := convertDataset(meta, dat, revision, fileType)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "CONVERSION_FAILED", "转换失败", gin.H{"detail": err.Error()})
+			return
+		}
+
+		base := exportBaseName(c, stor, id)
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, data := range map[string][]byte{base + ".cfg": files.cfg, base + ".dat": files.dat} {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now(), Flags: 0x800})
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				writeError(c, http.StatusInternalServerError, "EXPORT_FAILED", "导出失败", gin.H{"detail": err.Error()})
+				return
+			}
+		}
+		if err := zw.Close(); err != nil {
+			writeError(c, http.StatusInternalServerError, "EXPORT_FAILED", "导出失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.Header("X-Conversion-Lossless", strconv.FormatBool(files.report.Lossless))
+		setAttachment(c, base+".zip", "application/zip")
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+	})
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// derivedSeries 由模拟量通道计算得到的派生序列, 与原始通道等长, 可复用窗口截取与下采样逻辑
+type derivedSeries struct {
+	ID   string
+	Name string
+	Unit string
+	Y    []float64
+}
+
+// parsePowerGroups 解析功率分组参数
+// 分组之间以 ";" 分隔, 组内以 "," 分隔 "电压通道:电流通道" 配对, 每组为 1 对(单相)或 3 对(三相)
+// 例如 "1:4,2:5,3:6;7:8" 表示一个三相组和一个单相组
+func parsePowerGroups(s string) ([]comtrade.PowerGroup, error) {
+	groups := make([]comtrade.PowerGroup, 0)
+	for groupStr := range strings.SplitSeq(s, ";") {
+		groupStr = strings.TrimSpace(groupStr)
+		if groupStr == "" {
+			continue
+		}
+		var g comtrade.PowerGroup
+		for pair := range strings.SplitSeq(groupStr, ",") {
+			vStr, iStr, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid voltage:current pair %q", pair)
+			}
+			v, err := strconv.Atoi(strings.TrimSpace(vStr))
+			if err != nil {
+				return nil, fmt.Errorf("invalid voltage channel %q", vStr)
+			}
+			i, err := strconv.Atoi(strings.TrimSpace(iStr))
+			if err != nil {
+				return nil, fmt.Errorf("invalid current channel %q", iStr)
+			}
+			g.Voltages = append(g.Voltages, v)
+			g.Currents = append(g.Currents, i)
+		}
+		if len(g.Voltages) != 1 && len(g.Voltages) != 3 {
+			return nil, fmt.Errorf("power group %q must have 1 or 3 voltage:current pairs", groupStr)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// scaleToUnit 将以 from 为单位的采样值就地换算为以 to 为单位(仅十进制前缀不同)
+func scaleToUnit(y []float64, from, to string) []float64 {
+	if factor := comtrade.UnitBaseFactor(from) / comtrade.UnitBaseFactor(to); factor != 1 {
+		for j := range y {
+			y[j] *= factor
+		}
+	}
+	return y
+}
+
+// powerDerivedSeries 计算一个功率分组的瞬时与基波功率派生序列
+func powerDerivedSeries(meta *comtrade.Metadata, dat *comtrade.ChannelData, index int, g comtrade.PowerGroup, opts comtrade.AnalogOptions) ([]derivedSeries, error) {
+	voltages := make([][]float64, len(g.Voltages))
+	currents := make([][]float64, len(g.Currents))
+	names := make([]string, len(g.Voltages))
+	var voltageUnit, currentUnit string
+	for k := range g.Voltages {
+		vCh, ok := meta.AnalogChannelByNumber(g.Voltages[k])
+		if !ok {
+			return nil, fmt.Errorf("analog channel %d not found", g.Voltages[k])
+		}
+		iCh, ok := meta.AnalogChannelByNumber(g.Currents[k])
+		if !ok {
+			return nil, fmt.Errorf("analog channel %d not found", g.Currents[k])
+		}
+		u, vUnit, err := comtrade.AnalogValues(meta, dat, g.Voltages[k], opts)
+		if err != nil {
+			return nil, err
+		}
+		i, iUnit, err := comtrade.AnalogValues(meta, dat, g.Currents[k], opts)
+		if err != nil {
+			return nil, err
+		}
+		// 各相单位前缀可能不同(如 kV 与 V), 统一换算到第一相的单位后再求和
+		if k == 0 {
+			voltageUnit, currentUnit = vUnit, iUnit
+		}
+		voltages[k] = scaleToUnit(u, vUnit, voltageUnit)
+		currents[k] = scaleToUnit(i, iUnit, currentUnit)
+		names[k] = vCh.ChannelName + "·" + iCh.ChannelName
+	}
+
+	fs := meta.SampleRateAt(0)
+	ps, err := comtrade.ComputePower(voltages, currents, fs, meta.NominalFrequency())
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, scale := comtrade.PowerUnitPrefix(voltageUnit, currentUnit)
+	if scale != 1 {
+		for _, y := range [][]float64{ps.Instantaneous, ps.InstantaneousQ, ps.P, ps.Q, ps.S} {
+			for j := range y {
+				y[j] *= scale
+			}
+		}
+	}
+	label := strings.Join(names, ",")
+	id := fmt.Sprintf("power%d", index+1)
+	out := []derivedSeries{
+		{ID: id + ".p", Name: "p(" + label + ")", Unit: prefix + "W", Y: ps.Instantaneous},
+	}
+	if ps.InstantaneousQ != nil {
+		out = append(out, derivedSeries{ID: id + ".q", Name: "q(" + label + ")", Unit: prefix + "var", Y: ps.InstantaneousQ})
+	}
+	out = append(out,
+		derivedSeries{ID: id + ".P", Name: "P(" + label + ")", Unit: prefix + "W", Y: ps.P},
+		derivedSeries{ID: id + ".Q", Name: "Q(" + label + ")", Unit: prefix + "var", Y: ps.Q},
+		derivedSeries{ID: id + ".S", Name: "S(" + label + ")", Unit: prefix + "VA", Y: ps.S},
+		derivedSeries{ID: id + ".PF", Name: "PF(" + label + ")", Unit: "", Y: ps.PF},
+	)
+	return out, nil
+}
+
+// derivedChannel 用户定义的表达式派生通道, 按数据集保存在 derived.json
+type derivedChannel struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Unit       string `json:"unit"`
+}
+
+// loadDerivedChannels 读取数据集的派生通道定义, 文件不存在时返回空列表
+func loadDerivedChannels(ctx context.Context, stor storage.Storage, id string) []derivedChannel {
+	out := make([]derivedChannel, 0)
+	if data, err := readComtradeFile(ctx, stor, id, "derived.json"); err == nil {
+		_ = json.Unmarshal(data, &out)
+	}
+	return out
+}
+
+// saveDerivedChannels 写入数据集的派生通道定义
+func saveDerivedChannels(ctx context.Context, stor storage.Storage, id string, channels []derivedChannel) error {
+	b, err := json.MarshalIndent(channels, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeComtradeFile(ctx, stor, filepath.Join(id, "derived.json"), b)
+}
+
+// expressionDerivedSeries 对派生通道表达式求值
+func expressionDerivedSeries(meta *comtrade.Metadata, dat *comtrade.ChannelData, ch derivedChannel, opts comtrade.AnalogOptions) (derivedSeries, error) {
+	expr, err := comtrade.ParseExpression(ch.Expression)
+	if err != nil {
+		return derivedSeries{}, err
+	}
+	y, err := expr.Evaluate(meta, dat, opts)
+	if err != nil {
+		return derivedSeries{}, err
+	}
+	return derivedSeries{ID: ch.ID, Name: ch.Name, Unit: ch.Unit, Y: y}, nil
+}
+
+// registerDerivedRoutes 注册派生通道定义的增删查接口
+func registerDerivedRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	r.GET("/api/datasets/:id/derived", func(c *gin.Context) {
+		c.JSON(http.StatusOK, loadDerivedChannels(c.Request.Context(), stor, c.Param("id")))
+	})
+
+	// 新建或按名称覆盖派生通道, 保存前在数据集上试算一次以校验通道引用
+	r.POST("/api/datasets/:id/derived", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		var req derivedChannel
+		if err := c.BindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "BAD_JSON", "JSON格式错误", gin.H{"detail": err.Error()})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || strings.TrimSpace(req.Expression) == "" {
+			writeError(c, http.StatusBadRequest, "INVALID_DERIVED_CHANNEL", "派生通道名称与表达式不能为空", gin.H{"hint": "例如 {\"name\":\"3I0\",\"expression\":\"Ia+Ib+Ic\",\"unit\":\"A\"}"})
+			return
+		}
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+		if _, err := expressionDerivedSeries(meta, dat, req, comtrade.AnalogOptions{}); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_EXPRESSION", "表达式无效", gin.H{"detail": err.Error(), "expression": req.Expression})
+			return
+		}
+
+		channels := loadDerivedChannels(ctx, stor, id)
+		replaced := false
+		for i := range channels {
+			if channels[i].Name == req.Name {
+				req.ID = channels[i].ID
+				channels[i] = req
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			req.ID = "expr" + strconv.FormatInt(time.Now().UnixNano(), 10)
+			channels = append(channels, req)
+		}
+
+		if err := saveDerivedChannels(ctx, stor, id, channels); err != nil {
+			writeError(c, http.StatusInternalServerError, "DERIVED_WRITE_ERROR", "写入派生通道失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, req)
+	})
+
+	r.DELETE("/api/datasets/:id/derived/:derivedId", func(c *gin.Context) {
+		id := c.Param("id")
+		derivedID := c.Param("derivedId")
+		ctx := c.Request.Context()
+
+		channels := loadDerivedChannels(ctx, stor, id)
+		kept := make([]derivedChannel, 0, len(channels))
+		for _, ch := range channels {
+			if ch.ID != derivedID {
+				kept = append(kept, ch)
+			}
+		}
+		if len(kept) == len(channels) {
+			writeError(c, http.StatusNotFound, "DERIVED_NOT_FOUND", "未找到派生通道", gin.H{"id": derivedID})
+			return
+		}
+
+		if err := saveDerivedChannels(ctx, stor, id, kept); err != nil {
+			writeError(c, http.StatusInternalServerError, "DERIVED_WRITE_ERROR", "写入派生通道失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+}
+
+// parseThreePhaseGroups 解析以 ";" 分隔的三相通道组, 每组为按 A,B,C 顺序的 3 个通道号
+func parseThreePhaseGroups(s string) ([][]int, error) {
+	groups := make([][]int, 0)
+	for groupStr := range strings.SplitSeq(s, ";") {
+		if strings.TrimSpace(groupStr) == "" {
+			continue
+		}
+		g := parseOrderedChannels(groupStr)
+		if len(g) != 3 {
+			return nil, fmt.Errorf("three-phase group %q must have 3 channels", groupStr)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// transformDerivedSeries 计算一个三相组的 Clarke(αβ0) 或 Park(dq0) 派生序列
+//   - angle: 电角度来源 tracked|fixed, fixed 时按频率 freq(Hz) 与初相角 phaseDeg(度)计算
+func transformDerivedSeries(meta *comtrade.Metadata, dat *comtrade.ChannelData, kind string, index int, group []int, opts comtrade.AnalogOptions, angle string, freq, phaseDeg float64) ([]derivedSeries, error) {
+	var phases [3][]float64
+	names := make([]string, 3)
+	var unit string
+	for k, chNum := range group {
+		ch, ok := meta.AnalogChannelByNumber(chNum)
+		if !ok {
+			return nil, fmt.Errorf("analog channel %d not found", chNum)
+		}
+		var err error
+		if phases[k], unit, err = comtrade.AnalogValues(meta, dat, chNum, opts); err != nil {
+			return nil, err
+		}
+		names[k] = ch.ChannelName
+	}
+	label := strings.Join(names, ",")
+	id := fmt.Sprintf("%s%d", kind, index+1)
+	ab := comtrade.ClarkeTransform(phases[0], phases[1], phases[2])
+	if kind == "clarke" {
+		return []derivedSeries{
+			{ID: id + ".alpha", Name: "α(" + label + ")", Unit: unit, Y: ab.Alpha},
+			{ID: id + ".beta", Name: "β(" + label + ")", Unit: unit, Y: ab.Beta},
+			{ID: id + ".zero", Name: "0(" + label + ")", Unit: unit, Y: ab.Zero},
+		}, nil
+	}
+
+	fs := meta.SampleRateAt(0)
+	var theta []float64
+	switch angle {
+	case comtrade.ParkAngleTracked:
+		var err error
+		if theta, err = comtrade.TrackedAngle(phases[0], phases[1], phases[2], fs, meta.NominalFrequency()); err != nil {
+			return nil, err
+		}
+	case comtrade.ParkAngleFixed:
+		theta = comtrade.FixedAngle(len(ab.Alpha), fs, freq, phaseDeg*math.Pi/180)
+	default:
+		return nil, fmt.Errorf("unknown park angle source %q", angle)
+	}
+	dq := comtrade.ParkTransform(ab, theta)
+	return []derivedSeries{
+		{ID: id + ".d", Name: "d(" + label + ")", Unit: unit, Y: dq.D},
+		{ID: id + ".q", Name: "q(" + label + ")", Unit: unit, Y: dq.Q},
+		{ID: id + ".zero", Name: "0(" + label + ")", Unit: unit, Y: dq.Zero},
+	}, nil
+}
+
+// collectDerivedSeries 按查询参数计算所有派生序列, 供波形与导出接口共用, 失败时写入错误响应并返回 false
+//   - power=1:4,2:5,3:6;7:8              功率
+//   - X=expr1,expr2                      表达式派生通道
+//   - clarke=1,2,3;4,5,6                 αβ0 变换
+//   - park=1,2,3&parkAngle=tracked|fixed dq0 变换, fixed 时可指定 parkFreq(Hz, 默认额定频率)与 parkPhase(度)
+func collectDerivedSeries(c *gin.Context, stor storage.Storage, id string, meta *comtrade.Metadata, dat *comtrade.ChannelData, opts comtrade.AnalogOptions) ([]derivedSeries, bool) {
+	derived := make([]derivedSeries, 0)
+
+	powerGroups, err := parsePowerGroups(c.Query("power"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_POWER_GROUPS", "功率通道分组参数无效", gin.H{"detail": err.Error(), "hint": "例如?power=1:4,2:5,3:6 表示三相电压1/2/3与电流4/5/6"})
+		return nil, false
+	}
+	for index, g := range powerGroups {
+		ps, err := powerDerivedSeries(meta, dat, index, g, opts)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "POWER_COMPUTE_FAILED", "功率计算失败", gin.H{"detail": err.Error()})
+			return nil, false
+		}
+		derived = append(derived, ps...)
+	}
+
+	if x := c.Query("X"); x != "" {
+		defined := loadDerivedChannels(c.Request.Context(), stor, id)
+		for derivedID := range strings.SplitSeq(x, ",") {
+			derivedID = strings.TrimSpace(derivedID)
+			if derivedID == "" {
+				continue
+			}
+			i := slices.IndexFunc(defined, func(ch derivedChannel) bool { return ch.ID == derivedID })
+			if i < 0 {
+				writeError(c, http.StatusNotFound, "DERIVED_NOT_FOUND", "未找到派生通道", gin.H{"id": derivedID})
+				return nil, false
+			}
+			d, err := expressionDerivedSeries(meta, dat, defined[i], opts)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, "EXPRESSION_EVAL_FAILED", "派生通道计算失败", gin.H{"detail": err.Error(), "name": defined[i].Name})
+				return nil, false
+			}
+			derived = append(derived, d)
+		}
+	}
+
+	angle := c.DefaultQuery("parkAngle", comtrade.ParkAngleTracked)
+	freq := meta.NominalFrequency()
+	phaseDeg := 0.0
+	for name, dst := range map[string]*float64{"parkFreq": &freq, "parkPhase": &phaseDeg} {
+		if s := c.Query(name); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, "INVALID_TRANSFORM_PARAMS", "坐标变换参数无效", gin.H{"param": name})
+				return nil, false
+			}
+			*dst = v
+		}
+	}
+	for _, kind := range []string{"clarke", "park"} {
+		groups, err := parseThreePhaseGroups(c.Query(kind))
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_THREE_PHASE_GROUP", "三相通道分组参数无效", gin.H{"detail": err.Error(), "hint": "例如?park=1,2,3;4,5,6 (按A,B,C顺序)"})
+			return nil, false
+		}
+		for index, g := range groups {
+			ts, err := transformDerivedSeries(meta, dat, kind, index, g, opts, angle, freq, phaseDeg)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, "TRANSFORM_COMPUTE_FAILED", "坐标变换计算失败", gin.H{"detail": err.Error()})
+				return nil, false
+			}
+			derived = append(derived, ts...)
+		}
+	}
+	return derived, true
+}
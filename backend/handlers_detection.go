@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerDetectionRoutes 注册长记录中扰动/异常自动检测相关接口
+func registerDetectionRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	// 扰动检测: 扫描整条记录, 返回按时间排序的候选扰动窗口
+	r.GET("/api/datasets/:id/disturbances", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		detectors := comtrade.DisturbanceDetectors
+		if d := c.Query("detectors"); d != "" {
+			detectors = make([]string, 0)
+			for name := range strings.SplitSeq(d, ",") {
+				name = strings.TrimSpace(name)
+				if !slices.Contains(comtrade.DisturbanceDetectors, name) {
+					writeError(c, http.StatusBadRequest, "INVALID_DETECTOR", "无效的检测器", gin.H{"detector": name, "expected": strings.Join(comtrade.DisturbanceDetectors, "|")})
+					return
+				}
+				detectors = append(detectors, name)
+			}
+		}
+
+		settings := comtrade.DefaultDetectorSettings
+		mergeMs := 0.0
+		for name, dst := range map[string]*float64{"rmsStep": &settings.RMSStep, "delta": &settings.Delta, "over": &settings.Over, "under": &settings.Under, "mergeMs": &mergeMs} {
+			if s := c.Query(name); s != "" {
+				v, err := strconv.ParseFloat(s, 64)
+				if err != nil || v < 0 {
+					writeError(c, http.StatusBadRequest, "INVALID_DETECTOR_SETTINGS", "检测参数无效", gin.H{"param": name})
+					return
+				}
+				*dst = v
+			}
+		}
+
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		// 未指定通道时扫描全部通道
+		analogChannels := parseChannelList(c.Query("A"))
+		digitalChannels := parseChannelList(c.Query("D"))
+		if len(analogChannels) == 0 && len(digitalChannels) == 0 {
+			for _, ch := range meta.AnalogChannels {
+				analogChannels = append(analogChannels, ch.ChannelNumber)
+			}
+			for _, ch := range meta.DigitalChannels {
+				digitalChannels = append(digitalChannels, ch.ChannelNumber)
+			}
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		if len(timestamps) == 0 {
+			writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+			return
+		}
+		fs := meta.SampleRateAt(0)
+		f := meta.NominalFrequency()
+		cycle := max(1, int(math.Round(fs/f)))
+		if mergeMs > 0 {
+			settings.MergeGap = int(math.Round(mergeMs * fs / 1000))
+		}
+
+		windows := make([]comtrade.DisturbanceWindow, 0)
+		names := make(map[string]string)
+		for _, chNum := range analogChannels {
+			ch, ok := meta.AnalogChannelByNumber(chNum)
+			if !ok {
+				continue
+			}
+			y, _, err := comtrade.AnalogValues(meta, dat, chNum, opts)
+			if err != nil {
+				continue
+			}
+			names["A"+strconv.Itoa(chNum)] = ch.ChannelName
+			windows = append(windows, comtrade.DetectAnalogDisturbances(y, chNum, fs, f, detectors, settings)...)
+		}
+		if slices.Contains(detectors, comtrade.DetectorDigital) {
+			for _, chNum := range digitalChannels {
+				ch, ok := meta.DigitalChannelByNumber(chNum)
+				if !ok {
+					continue
+				}
+				y, err := dat.GetDigitalData(chNum)
+				if err != nil {
+					continue
+				}
+				names["D"+strconv.Itoa(chNum)] = ch.ChannelName
+				windows = append(windows, comtrade.DetectDigitalDisturbances(y, chNum)...)
+			}
+		}
+
+		// 不同通道间的窗口按 MergeGap(缺省一个周波)合并为事件
+		gap := settings.MergeGap
+		if gap <= 0 {
+			gap = cycle
+		}
+		last := len(timestamps) - 1
+		events := make([]map[string]any, 0)
+		for _, ev := range comtrade.MergeDisturbances(windows, gap) {
+			channels := make([]map[string]any, 0, len(ev.Windows))
+			for _, w := range ev.Windows {
+				kind := "A"
+				if w.Detector == comtrade.DetectorDigital {
+					kind = "D"
+				}
+				channels = append(channels, map[string]any{
+					"channel":  w.Channel,
+					"kind":     kind,
+					"name":     names[kind+strconv.Itoa(w.Channel)],
+					"detector": w.Detector,
+					"type":     w.Type,
+					"start":    w.Start,
+					"end":      w.End,
+					"severity": w.Severity,
+				})
+			}
+			events = append(events, map[string]any{
+				"start":     ev.Start,
+				"end":       ev.End,
+				"peak":      ev.Peak,
+				"startTime": timestamps[min(ev.Start, last)],
+				"endTime":   timestamps[min(ev.End, last)],
+				"peakTime":  timestamps[min(ev.Peak, last)],
+				"severity":  ev.Severity,
+				"detectors": ev.Detectors,
+				"windows":   channels,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"detectors": detectors,
+			"settings": gin.H{
+				"rmsStep":  settings.RMSStep,
+				"delta":    settings.Delta,
+				"over":     settings.Over,
+				"under":    settings.Under,
+				"mergeGap": gap,
+			},
+			"trigger": meta.TriggerIndex(timestamps),
+			"events":  events,
+		})
+	})
+
+	// CT 饱和检测: 结果以 source=ctsaturation 的自动标注保存
+	r.POST("/api/datasets/:id/ctsaturation", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		// 未指定通道时检测全部电流通道
+		currentChs := parseChannelList(c.Query("I"))
+		if len(currentChs) == 0 {
+			for _, ch := range meta.AnalogChannels {
+				if ch.IsCurrent() {
+					currentChs = append(currentChs, ch.ChannelNumber)
+				}
+			}
+		}
+		if len(currentChs) == 0 {
+			writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "记录中没有单位为A的通道, 请通过查询参数I指定电流通道, 例如?I=4,5,6"})
+			return
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		if len(timestamps) == 0 {
+			writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+			return
+		}
+		last := len(timestamps) - 1
+
+		results := make([]map[string]any, 0, len(currentChs))
+		anns := make([]map[string]any, 0)
+		for _, chNum := range currentChs {
+			ch, ok := meta.AnalogChannelByNumber(chNum)
+			if !ok {
+				writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+				return
+			}
+			y, _, err := comtrade.AnalogValues(meta, dat, chNum, opts)
+			if err != nil {
+				writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+				return
+			}
+
+			intervals := make([]map[string]any, 0)
+			for _, iv := range comtrade.DetectCTSaturation(y, meta.SampleRateAt(0), meta.NominalFrequency()) {
+				startMs := float64(timestamps[min(iv.Start, last)])
+				endMs := float64(timestamps[min(iv.End, last)])
+				intervals = append(intervals, map[string]any{
+					"start":      iv.Start,
+					"end":        iv.End,
+					"startTime":  startMs,
+					"endTime":    endMs,
+					"confidence": iv.Confidence,
+					"indicators": iv.Indicators,
+				})
+				anns = append(anns, map[string]any{
+					"type":       "range",
+					"start":      startMs,
+					"end":        endMs,
+					"channel":    chNum,
+					"confidence": iv.Confidence,
+					"indicators": iv.Indicators,
+					"note":       fmt.Sprintf("疑似CT饱和: %s, 置信度 %.0f%%", ch.ChannelName, iv.Confidence*100),
+				})
+			}
+			results = append(results, map[string]any{
+				"channel":   chNum,
+				"name":      ch.ChannelName,
+				"intervals": intervals,
+			})
+		}
+
+		ids, err := saveAutoAnnotations(ctx, stor, id, "ctsaturation", anns)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, "ANNOTATIONS_WRITE_ERROR", "写入标注失败", gin.H{"detail": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"channels":      results,
+			"annotationIds": ids,
+		})
+	})
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/export"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportTimeLayout 绝对时间列的格式(微秒精度)
+const exportTimeLayout = "2006-01-02T15:04:05.000000"
+
+// exportTable 待导出的通道数据: 各列与原始记录等长, 由 rows 指定导出的采样序号
+type exportTable struct {
+	meta    *comtrade.Metadata
+	opts    comtrade.AnalogOptions
+	step    int
+	times   []float32
+	columns []export.Column
+	values  [][]float64
+	rows    []int
+}
+
+// datasetBaseName 返回数据集 CFG 文件名(不含扩展名), 用作导出文件名, 找不到时返回数据集 ID
+func datasetBaseName(ctx context.Context, stor storage.Storage, id string) string {
+	entries, err := stor.ListFiles(ctx, id)
+	if err == nil {
+		for _, entry := range entries {
+			if strings.EqualFold(path.Ext(entry), ".cfg") {
+				return strings.TrimSuffix(path.Base(entry), path.Ext(entry))
+			}
+		}
+	}
+	return id
+}
+
+// setAttachment 设置下载文件名, 非 ASCII 文件名按 RFC 6266 编码
+func setAttachment(c *gin.Context, filename string, contentType string) {
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+}
+
+// newExportTable 创建不含任何列的导出表
+func newExportTable(meta *comtrade.Metadata, dat *comtrade.ChannelData, opts comtrade.AnalogOptions) *exportTable {
+	return &exportTable{
+		meta:  meta,
+		opts:  opts,
+		step:  1,
+		times: comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps)),
+	}
+}
+
+// addAnalog 按数值侧与时间偏移补偿选项换算模拟量通道并追加为一列
+func (t *exportTable) addAnalog(dat *comtrade.ChannelData, chNum int) error {
+	ch, ok := t.meta.AnalogChannelByNumber(chNum)
+	if !ok {
+		return fmt.Errorf("analog channel %d not found", chNum)
+	}
+	y, unit, err := comtrade.AnalogValues(t.meta, dat, chNum, t.opts)
+	if err != nil {
+		return err
+	}
+	t.columns = append(t.columns, export.Column{Name: ch.ChannelName, Unit: unit, Phase: ch.Phase, Kind: "analog", Number: chNum})
+	t.values = append(t.values, y)
+	return nil
+}
+
+// addDigital 追加开关量通道(取值 0/1)
+func (t *exportTable) addDigital(dat *comtrade.ChannelData, chNum int) error {
+	ch, ok := t.meta.DigitalChannelByNumber(chNum)
+	if !ok {
+		return fmt.Errorf("digital channel %d not found", chNum)
+	}
+	raw, err := dat.GetDigitalData(chNum)
+	if err != nil {
+		return err
+	}
+	y := make([]float64, len(raw))
+	for i, v := range raw {
+		y[i] = float64(v)
+	}
+	t.columns = append(t.columns, export.Column{Name: ch.ChannelName, Phase: ch.Phase, Kind: "digital", Number: chNum})
+	t.values = append(t.values, y)
+	return nil
+}
+
+// addDerived 追加派生序列
+func (t *exportTable) addDerived(d derivedSeries) {
+	t.columns = append(t.columns, export.Column{Name: d.Name, Unit: d.Unit, Kind: "derived"})
+	t.values = append(t.values, d.Y)
+}
+
+// selectRows 在 [start, end] 内每 step 个采样选取一行, end 不超过最短的列
+func (t *exportTable) selectRows(start, end, step int) {
+	for _, y := range t.values {
+		end = min(end, len(y)-1)
+	}
+	t.step = step
+	t.rows = make([]int, 0, max(0, (end-start)/step+1))
+	for i := start; i <= end; i += step {
+		t.rows = append(t.rows, i)
+	}
+}
+
+// collectExportTable 按 A/D/派生量参数收集导出列, 并按 startTime/endTime 与 step 确定导出的行,
+// 失败时写入错误响应并返回 false
+func collectExportTable(c *gin.Context, stor storage.Storage, id string, meta *comtrade.Metadata, dat *comtrade.ChannelData) (*exportTable, bool) {
+	if len(dat.Timestamps) == 0 {
+		writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+		return nil, false
+	}
+	opts, ok := parseAnalogOptions(c)
+	if !ok {
+		return nil, false
+	}
+	derived, ok := collectDerivedSeries(c, stor, id, meta, dat, opts)
+	if !ok {
+		return nil, false
+	}
+
+	table := newExportTable(meta, dat, opts)
+	for _, chNum := range parseOrderedChannels(c.Query("A")) {
+		if err := table.addAnalog(dat, chNum); err != nil {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+			return nil, false
+		}
+	}
+	for _, chNum := range parseOrderedChannels(c.Query("D")) {
+		if err := table.addDigital(dat, chNum); err != nil {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum, "type": "digital"})
+			return nil, false
+		}
+	}
+	for _, d := range derived {
+		table.addDerived(d)
+	}
+	if len(table.columns) == 0 {
+		writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "请通过查询参数A和D指定所需的模拟和数字通道, 例如?A=1,2,3&D=1,2"})
+		return nil, false
+	}
+
+	step := 1
+	if s := c.Query("step"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 {
+			writeError(c, http.StatusBadRequest, "INVALID_EXPORT_STEP", "抽取间隔无效", gin.H{"step": s, "hint": "step为正整数, 例如?step=10表示每10个采样导出一行"})
+			return nil, false
+		}
+		step = v
+	}
+	start, end := parseIndexWindow(c, len(table.times))
+	table.selectRows(start, end, step)
+	return table, true
+}
+
+// writeExportRows 将导出表逐行写入写出器; absolute 为 true 时时间列为 StartTime 加相对时间的绝对时间
+func writeExportRows(w export.RowWriter, table *exportTable, absolute bool) error {
+	timeHeader := "Time (ms)"
+	if absolute {
+		timeHeader = "Time"
+	}
+	if err := w.WriteHeader(timeHeader, table.columns); err != nil {
+		return err
+	}
+	row := export.Row{Values: make([]float64, len(table.columns))}
+	for _, i := range table.rows {
+		ms := float64(table.times[i])
+		if absolute {
+			row.Label = table.meta.StartTime.Add(time.Duration(ms * float64(time.Millisecond))).Format(exportTimeLayout)
+		} else {
+			row.Time = ms
+		}
+		for k, y := range table.values {
+			row.Values[k] = y[i]
+		}
+		if err := w.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// matVariables 将导出表组织为 MAT 变量: record 为记录级元数据, channels 为每通道一个元素的结构体数组
+// 时间向量以秒为单位, 相对记录开始时刻
+func matVariables(table *exportTable) []export.MATVariable {
+	meta := table.meta
+	rates := make([]float64, 0, 2*len(meta.SampleRates))
+	for _, sr := range meta.SampleRates {
+		rates = append(rates, sr.SampRate, float64(sr.LastSampleNum))
+	}
+	window := []float64{math.NaN(), math.NaN()}
+	if n := len(table.rows); n > 0 {
+		window = []float64{float64(table.rows[0]), float64(table.rows[n-1])}
+	}
+	record := export.MATStruct([]string{
+		"station", "device", "revision", "frequency", "sampleRates", "startTime", "triggerTime", "triggerOffset",
+		"dataFileType", "timeMultiplier", "values", "window", "step",
+	}, []export.MATValue{
+		export.MATString(meta.Station),
+		export.MATString(meta.Relay),
+		export.MATString(meta.Version),
+		export.MATScalar(meta.NominalFrequency()),
+		export.MATMatrix(len(meta.SampleRates), 2, rates),
+		export.MATString(meta.StartTime.Format(exportTimeLayout)),
+		export.MATString(meta.EndTime.Format(exportTimeLayout)),
+		export.MATScalar(meta.EndTime.Sub(meta.StartTime).Seconds()),
+		export.MATString(meta.DataFileType),
+		export.MATScalar(meta.TimeMultiplier),
+		export.MATString(table.opts.Side),
+		export.MATMatrix(1, 2, window),
+		export.MATScalar(float64(table.step)),
+	})
+
+	sampleRate := 0.0
+	if len(table.rows) > 0 {
+		sampleRate = meta.SampleRateAt(table.rows[0]) / float64(table.step)
+	}
+	timeVector := export.MATVector(len(table.rows), func(i int) float64 { return float64(table.times[table.rows[i]]) / 1000 })
+	elements := make([][]export.MATValue, len(table.columns))
+	for k, col := range table.columns {
+		y := table.values[k]
+		elements[k] = []export.MATValue{
+			export.MATString(col.Name),
+			export.MATString(col.Unit),
+			export.MATString(col.Phase),
+			export.MATString(col.Kind),
+			export.MATScalar(float64(col.Number)),
+			export.MATVector(len(table.rows), func(i int) float64 { return y[table.rows[i]] }),
+			timeVector,
+			export.MATScalar(sampleRate),
+		}
+	}
+	channels := export.MATStruct([]string{"name", "unit", "phase", "type", "number", "data", "time", "sampleRate"}, elements...)
+
+	return []export.MATVariable{{Name: "record", Value: record}, {Name: "channels", Value: channels}}
+}
+
+const (
+	parquetLayoutLong = "long"
+	parquetLayoutWide = "wide"
+)
+
+// writeParquetTable 以长表或宽表写出 Parquet, timestamp 列为 CFG 开始时刻加相对时间(微秒, 当地时间)
+//   - long: dataset, station, relay, channel, unit, timestamp, value; 每个通道每个采样一行, 按通道、时间排列
+//   - wide: dataset, station, relay, timestamp 及每通道一列(列名含单位, 重名时追加序号)
+func writeParquetTable(w io.Writer, table *exportTable, dataset, layout, compression string) error {
+	meta := table.meta
+	start := meta.StartTime.UnixMicro()
+	micros := func(i int) int64 { return start + int64(math.Round(float64(table.times[i])*1000)) }
+	opts := export.ParquetOptions{
+		Compression: compression,
+		Metadata: [][2]string{
+			{"dataset", dataset},
+			{"station", meta.Station},
+			{"relay", meta.Relay},
+			{"revision", meta.Version},
+			{"frequency", strconv.FormatFloat(meta.NominalFrequency(), 'g', -1, 64)},
+			{"startTime", meta.StartTime.Format(exportTimeLayout)},
+			{"triggerTime", meta.EndTime.Format(exportTimeLayout)},
+			{"values", table.opts.Side},
+			{"layout", layout},
+		},
+	}
+	fields := []export.ParquetField{
+		{Name: "dataset", Kind: export.ParquetString},
+		{Name: "station", Kind: export.ParquetString},
+		{Name: "relay", Kind: export.ParquetString},
+	}
+	setRecord := func(pw *export.ParquetWriter) {
+		pw.SetString(0, dataset)
+		pw.SetString(1, meta.Station)
+		pw.SetString(2, meta.Relay)
+	}
+
+	if layout == parquetLayoutWide {
+		fields = append(fields, export.ParquetField{Name: "timestamp", Kind: export.ParquetTimestamp})
+		seen := make(map[string]int)
+		for _, col := range table.columns {
+			name := col.Header()
+			if seen[name]++; seen[name] > 1 {
+				name = fmt.Sprintf("%s_%d", name, seen[name])
+			}
+			fields = append(fields, export.ParquetField{Name: name, Kind: export.ParquetDouble})
+		}
+		pw, err := export.NewParquetWriter(w, fields, opts)
+		if err != nil {
+			return err
+		}
+		for _, i := range table.rows {
+			setRecord(pw)
+			pw.SetTimestamp(3, micros(i))
+			for k, y := range table.values {
+				pw.SetDouble(4+k, y[i])
+			}
+			if err := pw.EndRow(); err != nil {
+				return err
+			}
+		}
+		return pw.Close()
+	}
+
+	fields = append(fields,
+		export.ParquetField{Name: "channel", Kind: export.ParquetString},
+		export.ParquetField{Name: "unit", Kind: export.ParquetString},
+		export.ParquetField{Name: "timestamp", Kind: export.ParquetTimestamp},
+		export.ParquetField{Name: "value", Kind: export.ParquetDouble},
+	)
+	pw, err := export.NewParquetWriter(w, fields, opts)
+	if err != nil {
+		return err
+	}
+	for k, col := range table.columns {
+		y := table.values[k]
+		for _, i := range table.rows {
+			setRecord(pw)
+			pw.SetString(3, col.Name)
+			pw.SetString(4, col.Unit)
+			pw.SetTimestamp(5, micros(i))
+			pw.SetDouble(6, y[i])
+			if err := pw.EndRow(); err != nil {
+				return err
+			}
+		}
+	}
+	return pw.Close()
+}
+
+// registerExportRoutes 注册数据导出接口, 导出内容按行流式写出, 不在内存中缓存整个文件
+func registerExportRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	// 导出请求的公共部分: 解析记录、收集列、确定时间格式
+	prepare := func(c *gin.Context) (*exportTable, bool, bool) {
+		id := c.Param("id")
+		meta, dat, err := parseComtrade(cache, stor, id, c.Request.Context(), c)
+		if err != nil {
+			writeParseError(c, err)
+			return nil, false, false
+		}
+		timeMode := c.DefaultQuery("time", "relative")
+		if timeMode != "relative" && timeMode != "absolute" {
+			writeError(c, http.StatusBadRequest, "INVALID_TIME_MODE", "无效的时间格式参数", gin.H{"expected": "relative|absolute"})
+			return nil, false, false
+		}
+		table, ok := collectExportTable(c, stor, id, meta, dat)
+		return table, timeMode == "absolute", ok
+	}
+
+	r.GET("/api/datasets/:id/export/csv", func(c *gin.Context) {
+		var delimiter rune
+		if d := c.Query("delimiter"); d != "" {
+			if d == "tab" {
+				d = "\t"
+			}
+			ch, size := utf8.DecodeRuneInString(d)
+			if size != len(d) || ch == '"' || ch == '\r' || ch == '\n' {
+				writeError(c, http.StatusBadRequest, "INVALID_DELIMITER", "无效的分隔符", gin.H{"delimiter": d, "hint": "单个字符, 例如?delimiter=; 或 ?delimiter=tab"})
+				return
+			}
+			delimiter = ch
+		}
+		table, absolute, ok := prepare(c)
+		if !ok {
+			return
+		}
+
+		setAttachment(c, exportBaseName(c, stor, c.Param("id"))+".csv", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := export.NewCSVWriter(c.Writer, delimiter, c.DefaultQuery("bom", "true") != "false")
+		if err := writeExportRows(w, table, absolute); err != nil {
+			// 响应头已发出, 只能中断连接
+			c.Error(err)
+			c.Abort()
+		}
+	})
+
+	r.GET("/api/datasets/:id/export/xlsx", func(c *gin.Context) {
+		table, absolute, ok := prepare(c)
+		if !ok {
+			return
+		}
+		if len(table.rows)+1 > export.XLSXMaxRows {
+			writeError(c, http.StatusBadRequest, "EXPORT_TOO_MANY_ROWS", "导出行数超过Excel工作表上限", gin.H{"rows": len(table.rows), "max": export.XLSXMaxRows - 1, "hint": "请缩小startTime/endTime窗口或通过step参数抽取, 也可改用CSV导出"})
+			return
+		}
+
+		name := datasetBaseName(c.Request.Context(), stor, c.Param("id"))
+		setAttachment(c, exportBaseName(c, stor, c.Param("id"))+".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Status(http.StatusOK)
+		w, err := export.NewXLSXWriter(c.Writer, name)
+		if err == nil {
+			err = writeExportRows(w, table, absolute)
+		}
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	})
+
+	r.GET("/api/datasets/:id/export/mat", func(c *gin.Context) {
+		table, _, ok := prepare(c)
+		if !ok {
+			return
+		}
+		vars := matVariables(table)
+		for _, v := range vars {
+			if v.Bytes() > export.MATMaxBytes {
+				writeError(c, http.StatusBadRequest, "EXPORT_TOO_LARGE", "导出数据超过MAT v5文件单个变量4GB的上限", gin.H{"variable": v.Name, "bytes": v.Bytes(), "hint": "请减少通道、缩小startTime/endTime窗口或通过step参数抽取"})
+				return
+			}
+		}
+
+		setAttachment(c, exportBaseName(c, stor, c.Param("id"))+".mat", "application/x-matlab-data")
+		c.Header("Content-Length", strconv.FormatInt(export.MATFileSize(vars), 10))
+		c.Status(http.StatusOK)
+		if err := export.WriteMAT(c.Writer, vars); err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	})
+
+	r.GET("/api/datasets/:id/export/parquet", func(c *gin.Context) {
+		layout := c.DefaultQuery("layout", parquetLayoutLong)
+		if layout != parquetLayoutLong && layout != parquetLayoutWide {
+			writeError(c, http.StatusBadRequest, "INVALID_EXPORT_LAYOUT", "无效的表格布局参数", gin.H{"expected": "long|wide"})
+			return
+		}
+		compression := c.DefaultQuery("compression", export.ParquetCompressionGzip)
+		if compression != export.ParquetCompressionGzip && compression != export.ParquetCompressionNone {
+			writeError(c, http.StatusBadRequest, "INVALID_EXPORT_COMPRESSION", "无效的压缩方式", gin.H{"expected": "gzip|none"})
+			return
+		}
+		table, _, ok := prepare(c)
+		if !ok {
+			return
+		}
+
+		name := exportBaseName(c, stor, c.Param("id"))
+		setAttachment(c, name+".parquet", "application/vnd.apache.parquet")
+		c.Status(http.StatusOK)
+		if err := writeParquetTable(c.Writer, table, c.Param("id"), layout, compression); err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	})
+}
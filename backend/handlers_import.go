@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/importer"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seriesImportRequest 时间序列导入参数: 记录名、目标数据文件格式(2013 版, 默认 float32)与通道映射
+type seriesImportRequest struct {
+	importer.SeriesOptions
+	Name         string `json:"name"`
+	DataFileType string `json:"dataFileType"`
+}
+
+// csvImportRequest CSV 导入参数, 以 JSON 字符串放在表单字段 options 中
+type csvImportRequest struct {
+	seriesImportRequest
+	importer.CSVOptions
+}
+
+// importRecordName 返回可作为文件名的记录名, 为空时使用 fallback
+func importRecordName(name, fallback string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return fallback
+	}
+	return name
+}
+
+// storeImportedSeries 由时间序列生成记录并保存为新的数据集, 失败时写入错误响应
+func storeImportedSeries(c *gin.Context, stor storage.Storage, req seriesImportRequest, ts *importer.TimeSeries) {
+	if req.DataFileType == "" {
+		req.DataFileType = "float32"
+	}
+	_, fileType, ok := conversionTarget(c, "2013", req.DataFileType)
+	if !ok {
+		return
+	}
+	name := importRecordName(req.Name, "import")
+	opts := req.SeriesOptions
+	if opts.Station == "" {
+		opts.Station = name
+	}
+	if opts.StartTime.IsZero() {
+		opts.StartTime = time.Now().Truncate(time.Microsecond)
+	}
+
+	meta, dat, err := importer.Build(ts, opts)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "INVALID_SERIES", "时间序列无法转换为录波记录", gin.H{
+			"detail": err.Error(),
+			"hint":   "请检查通道映射中的列名、采样率或时间列",
+		})
+		return
+	}
+	files, err := convertDataset(meta, dat, "2013", fileType)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "CONVERSION_FAILED", "转换失败", gin.H{"detail": err.Error()})
+		return
+	}
+	id, err := saveConvertedDataset(c.Request.Context(), stor, name, files)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "DATASET_SAVE_FAILED", "保存数据集失败", gin.H{"detail": err.Error()})
+		return
+	}
+
+	sampleRate := 0.0
+	if meta.RatesNum > 0 {
+		sampleRate = meta.SampleRates[0].SampRate
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"datasetId":       id,
+		"name":            name,
+		"samples":         len(dat.Timestamps),
+		"sampleRate":      sampleRate,
+		"analogChannels":  len(meta.AnalogChannels),
+		"digitalChannels": len(meta.DigitalChannels),
+		"report":          files.report,
+	})
+}
+
+// readFormFile 读取 multipart 表单中指定字段的第一个文件
+func readFormFile(c *gin.Context, field string) ([]byte, string, error) {
+	header := c.Request.MultipartForm.File[field][0]
+	src, err := header.Open()
+	if err != nil {
+		return nil, header.Filename, err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	return data, header.Filename, err
+}
+
+// registerImportRoutes 注册 CSV、通用时间序列与 SV 抓包导入接口, 导入结果与上传的 COMTRADE 记录一样作为普通数据集使用
+func registerImportRoutes(r *gin.Engine, stor storage.Storage) {
+	// CSV 文件(字段 file)与导入参数(字段 options, JSON)
+	r.POST("/api/datasets/import-csv", func(c *gin.Context) {
+		if err := c.Request.ParseMultipartForm(256 << 20); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_FORM", "无效的表单数据", gin.H{"hint": "请通过multipart/form-data提交CSV文件"})
+			return
+		}
+		fh := c.Request.MultipartForm
+		if !hasFileField(fh, "file") {
+			writeError(c, http.StatusBadRequest, "CSV_MISSING", "CSV文件缺失", gin.H{"hint": "请在file字段中选择CSV文件"})
+			return
+		}
+
+		var req csvImportRequest
+		if raw := c.Request.FormValue("options"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req); err != nil {
+				writeError(c, http.StatusBadRequest, "INVALID_IMPORT_OPTIONS", "导入参数格式错误", gin.H{"detail": err.Error()})
+				return
+			}
+		}
+
+		data, filename, err := readFormFile(c, "file")
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "CSV_READ_FAILED", "读取CSV文件失败", gin.H{"detail": err.Error()})
+			return
+		}
+
+		ts, err := importer.ReadCSV(data, req.CSVOptions)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "CSV_PARSE_FAILED", "CSV文件解析失败", gin.H{"detail": err.Error(), "file": filename})
+			return
+		}
+		if req.Name == "" {
+			req.Name = strings.TrimSuffix(filename, path.Ext(filename))
+		}
+		storeImportedSeries(c, stor, req.seriesImportRequest, ts)
+	})
+
+	// JSON 时间序列: 导入参数与 time、columns 在同一请求体中
+	r.POST("/api/datasets/import-series", func(c *gin.Context) {
+		var req struct {
+			seriesImportRequest
+			importer.TimeSeries
+		}
+		if err := c.BindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "BAD_JSON", "JSON格式错误", gin.H{"detail": err.Error()})
+			return
+		}
+		for i := range req.Columns {
+			if req.Columns[i].Number == 0 {
+				req.Columns[i].Number = i + 1
+			}
+		}
+		storeImportedSeries(c, stor, req.seriesImportRequest, &req.TimeSeries)
+	})
+
+	// IEC 61850-9-2LE 采样值抓包(pcap/pcapng, 字段 file), 每个 svID 生成一个数据集
+	r.POST("/api/datasets/import-sv", func(c *gin.Context) {
+		if err := c.Request.ParseMultipartForm(256 << 20); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_FORM", "无效的表单数据", gin.H{"hint": "请通过multipart/form-data提交抓包文件"})
+			return
+		}
+		if !hasFileField(c.Request.MultipartForm, "file") {
+			writeError(c, http.StatusBadRequest, "CAPTURE_MISSING", "抓包文件缺失", gin.H{"hint": "请在file字段中选择.pcap或.pcapng文件"})
+			return
+		}
+		opts := importer.SVOptions{SvID: c.Request.FormValue("svId")}
+		if raw := c.Request.FormValue("frequency"); raw != "" {
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil || f <= 0 {
+				writeError(c, http.StatusBadRequest, "INVALID_IMPORT_OPTIONS", "导入参数格式错误", gin.H{"frequency": raw, "hint": "额定频率应为正数, 如50或60"})
+				return
+			}
+			opts.Frequency = f
+		}
+
+		data, filename, err := readFormFile(c, "file")
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "CAPTURE_READ_FAILED", "读取抓包文件失败", gin.H{"detail": err.Error()})
+			return
+		}
+		capture, err := importer.DecodeSVCapture(data)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "CAPTURE_PARSE_FAILED", "抓包文件解析失败", gin.H{"detail": err.Error(), "file": filename})
+			return
+		}
+		if len(capture.Streams) == 0 {
+			writeError(c, http.StatusBadRequest, "NO_SV_STREAM", "抓包文件中没有IEC 61850-9-2采样值报文", gin.H{
+				"frames": capture.Frames, "svFrames": capture.SVFrames, "malformed": capture.Malformed,
+			})
+			return
+		}
+		streams := capture.Streams
+		if opts.SvID != "" {
+			s, ok := capture.FindStream(opts.SvID)
+			if !ok {
+				writeError(c, http.StatusNotFound, "SV_STREAM_NOT_FOUND", "未找到指定的svID", gin.H{"svId": opts.SvID, "available": capture.SVStreamIDs()})
+				return
+			}
+			streams = []*importer.SVStream{s}
+		}
+
+		ctx := c.Request.Context()
+		base := strings.TrimSuffix(filename, path.Ext(filename))
+		results := make([]gin.H, 0, len(streams))
+		for _, s := range streams {
+			meta, dat, report, err := importer.BuildSV(s, opts)
+			if err != nil {
+				results = append(results, gin.H{"svId": s.SvID, "error": err.Error()})
+				continue
+			}
+			files, err := encodeDataset(meta, dat)
+			if err != nil {
+				results = append(results, gin.H{"svId": s.SvID, "error": err.Error()})
+				continue
+			}
+			name := importRecordName(base+"_"+s.SvID, "sv")
+			id, err := saveConvertedDataset(ctx, stor, name, files)
+			if err != nil {
+				results = append(results, gin.H{"svId": s.SvID, "error": err.Error()})
+				continue
+			}
+			results = append(results, gin.H{"svId": s.SvID, "datasetId": id, "name": name, "report": report})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"frames":    capture.Frames,
+			"svFrames":  capture.SVFrames,
+			"malformed": capture.Malformed,
+			"streams":   results,
+		})
+	})
+
+	// 压缩包(zip/tar.gz, 字段 file): 按基本名配对 cfg/dat/hdr/inf, 每组生成一个数据集
+	r.POST("/api/datasets/import-archive", func(c *gin.Context) {
+		if err := c.Request.ParseMultipartForm(256 << 20); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_FORM", "无效的表单数据", gin.H{"hint": "请通过multipart/form-data提交压缩包"})
+			return
+		}
+		if !hasFileField(c.Request.MultipartForm, "file") {
+			writeError(c, http.StatusBadRequest, "ARCHIVE_MISSING", "压缩包缺失", gin.H{"hint": "请在file字段中选择.zip或.tar.gz文件"})
+			return
+		}
+		data, filename, err := readFormFile(c, "file")
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "ARCHIVE_READ_FAILED", "读取压缩包失败", gin.H{"detail": err.Error()})
+			return
+		}
+		archive, err := importer.ReadArchive(data)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "ARCHIVE_PARSE_FAILED", "压缩包解析失败", gin.H{"detail": err.Error(), "file": filename})
+			return
+		}
+		if len(archive.Records) == 0 {
+			writeError(c, http.StatusBadRequest, "NO_COMTRADE_RECORDS", "压缩包中没有COMTRADE文件", gin.H{"ignored": archive.Ignored, "hint": "压缩包中应包含同名的.cfg与.dat文件"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		results := make([]gin.H, 0, len(archive.Records))
+		imported := 0
+		var lastID int64
+		for _, rec := range archive.Records {
+			result := gin.H{"name": rec.Name, "path": path.Join(rec.Dir, rec.Name)}
+			results = append(results, result)
+			fail := func(code, msg string, details gin.H) {
+				result["error"] = gin.H{"code": code, "message": msg, "details": details}
+			}
+
+			cfg, dat := rec.Files["cfg"], rec.Files["dat"]
+			switch {
+			case len(rec.Duplicates) > 0:
+				fail("DUPLICATE_FILES", "存在仅大小写不同的同名文件", gin.H{"files": rec.Duplicates})
+				continue
+			case cfg == nil:
+				fail("CFG_MISSING", ".cfg文件缺失", gin.H{"hint": "记录缺少同名的配置文件(.cfg)"})
+				continue
+			case dat == nil:
+				fail("DAT_MISSING", ".dat文件缺失", gin.H{"hint": "记录缺少同名的数据文件(.dat)"})
+				continue
+			}
+			if _, _, err := comtrade.ParseComtradeFromBytes(cfg.Data, dat.Data); err != nil {
+				fail(toFriendlyParseError(err))
+				continue
+			}
+
+			// 同一请求内连续生成的 ID 保证递增
+			n := time.Now().UnixNano()
+			if n <= lastID {
+				n = lastID + 1
+			}
+			lastID = n
+			id := strconv.FormatInt(n, 10)
+			manifest := buildManifest(cfg.Name, dat.Name, cfg.Data)
+			manifest.Archive = filename
+			var saveErr error
+			for _, ext := range []string{"cfg", "dat", "hdr", "inf"} {
+				if f := rec.Files[ext]; f != nil && saveErr == nil {
+					saveErr = writeComtradeFile(ctx, stor, path.Join(id, path.Base(f.Name)), f.Data)
+					if ext == "hdr" || ext == "inf" {
+						manifest.Files = append(manifest.Files, f.Name)
+					}
+				}
+			}
+			if saveErr == nil {
+				saveErr = saveManifest(ctx, stor, id, manifest)
+			}
+			if saveErr != nil {
+				// 清除已写入的部分文件, 避免留下不完整的数据集
+				details := gin.H{"detail": saveErr.Error()}
+				if err := removeDataset(ctx, stor, nil, id); err != nil {
+					details["cleanup"] = err.Error()
+				}
+				fail("DATASET_SAVE_FAILED", "保存数据集失败", details)
+				continue
+			}
+			imported++
+			result["datasetId"] = id
+			result["files"] = manifest.Files
+			result["comname"] = manifest.COMName
+			result["warnings"] = manifest.Warnings
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"archive":  filename,
+			"records":  len(results),
+			"imported": imported,
+			"failed":   len(results) - imported,
+			"ignored":  archive.Ignored,
+			"results":  results,
+		})
+	})
+}
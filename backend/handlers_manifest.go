@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// datasetManifest 数据集清单, 上传时生成并保存在 manifest.json
+//   - Files: 上传的原始文件名, 从压缩包导入时为包内路径
+//   - Archive: 从压缩包导入时的压缩包文件名
+//   - COMName: CFG 文件名符合 IEEE C37.232 命名规则时解析出的字段
+//   - Warnings: 文件名字段与 CFG 内容不一致等提示
+type datasetManifest struct {
+	Files      []string          `json:"files"`
+	Archive    string            `json:"archive,omitempty"`
+	UploadedAt time.Time         `json:"uploadedAt"`
+	COMName    *comtrade.COMName `json:"comname,omitempty"`
+	Warnings   []string          `json:"warnings"`
+}
+
+// buildManifest 按上传的 CFG 文件名与内容生成清单, 文件名不符合 COMNAME 规则时不填 COMName
+func buildManifest(cfgName, datName string, cfgData []byte) *datasetManifest {
+	m := &datasetManifest{
+		Files:      []string{cfgName, datName},
+		UploadedAt: time.Now(),
+		Warnings:   make([]string, 0),
+	}
+	name, err := comtrade.ParseCOMName(cfgName)
+	if err != nil {
+		return m
+	}
+	m.COMName = name
+	meta, err := comtrade.ParseComtradeCFGFromBytes(cfgData)
+	if err != nil {
+		m.Warnings = append(m.Warnings, "CFG 解析失败, 未能核对文件名中的站名、设备名与时间: "+err.Error())
+		return m
+	}
+	m.Warnings = append(m.Warnings, name.Mismatches(meta)...)
+	return m
+}
+
+// loadManifest 读取数据集清单, 不存在或无法解析时返回 nil
+func loadManifest(ctx context.Context, stor storage.Storage, id string) *datasetManifest {
+	data, err := readComtradeFile(ctx, stor, id, "manifest.json")
+	if err != nil {
+		return nil
+	}
+	var m datasetManifest
+	if json.Unmarshal(data, &m) != nil {
+		return nil
+	}
+	return &m
+}
+
+func saveManifest(ctx context.Context, stor storage.Storage, id string, m *datasetManifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeComtradeFile(ctx, stor, filepath.Join(id, "manifest.json"), b)
+}
+
+// exportBaseName 返回导出文件名(不含扩展名)
+// 查询参数 naming=comname 时按 C37.232 规则命名: 优先使用清单中解析出的字段,
+// 否则由 CFG 的站名、录波器编号与开始时刻生成; company 参数覆盖公司名, 缺省为 "-"
+func exportBaseName(c *gin.Context, stor storage.Storage, id string) string {
+	ctx := c.Request.Context()
+	if !strings.EqualFold(c.Query("naming"), "comname") {
+		return datasetBaseName(ctx, stor, id)
+	}
+	var name *comtrade.COMName
+	if m := loadManifest(ctx, stor, id); m != nil && m.COMName != nil {
+		name = m.COMName
+	} else if cfg, err := readComtradeFile(ctx, stor, id, "cfg"); err == nil {
+		if meta, err := comtrade.ParseComtradeCFGFromBytes(cfg); err == nil {
+			name = comtrade.COMNameFromMetadata(meta, "")
+		}
+	}
+	if name == nil {
+		return datasetBaseName(ctx, stor, id)
+	}
+	if company := c.Query("company"); company != "" {
+		copied := *name
+		copied.Company = company
+		name = &copied
+	}
+	return name.FileName()
+}
+
+// registerManifestRoutes 注册数据集清单接口
+func registerManifestRoutes(r *gin.Engine, stor storage.Storage) {
+	r.GET("/api/datasets/:id/manifest", func(c *gin.Context) {
+		id := c.Param("id")
+		m := loadManifest(c.Request.Context(), stor, id)
+		if m == nil {
+			writeError(c, http.StatusNotFound, "MANIFEST_NOT_FOUND", "未找到数据集清单", gin.H{"id": id, "hint": "清单在上传或导入压缩包时生成, 转换或由其他数据导入的数据集没有清单"})
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	})
+}
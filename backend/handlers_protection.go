@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+)
+
+// differentialGroupRequest 差动计算的一侧电流组配置
+type differentialGroupRequest struct {
+	Currents           []int   `json:"currents"`
+	CTRatio            float64 `json:"ctRatio"`
+	Base               float64 `json:"base"`
+	Clock              int     `json:"clock"`
+	RemoveZeroSequence bool    `json:"removeZeroSequence"`
+}
+
+// differentialRequest 差动计算请求体
+type differentialRequest struct {
+	Groups         []differentialGroupRequest   `json:"groups"`
+	Restraint      string                       `json:"restraint"`
+	Characteristic *comtrade.BiasCharacteristic `json:"characteristic"`
+}
+
+// characteristicCurve 返回用于绘制比率制动特性的折线点(制动电流从 0 到 irMax)
+func characteristicCurve(bc comtrade.BiasCharacteristic, irMax float64) []map[string]float64 {
+	knees := []float64{0, bc.Pickup / math.Max(bc.Slope1, 1e-9), bc.Breakpoint, irMax}
+	out := make([]map[string]float64, 0, len(knees))
+	last := -1.0
+	for _, ir := range knees {
+		ir = math.Min(ir, irMax)
+		if ir <= last {
+			continue
+		}
+		out = append(out, map[string]float64{"ir": ir, "id": bc.Threshold(ir)})
+		last = ir
+	}
+	return out
+}
+
+// registerProtectionRoutes 注册保护动作特性校核相关接口
+func registerProtectionRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	// 差动/制动电流与比率制动特性
+	r.POST("/api/datasets/:id/differential", gzip.Gzip(gzip.BestSpeed), func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		var req differentialRequest
+		if err := c.BindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "BAD_JSON", "JSON格式错误", gin.H{"detail": err.Error()})
+			return
+		}
+		if len(req.Groups) < 2 {
+			writeError(c, http.StatusBadRequest, "INVALID_DIFFERENTIAL_GROUPS", "至少需要两组三相电流", gin.H{"hint": "例如 {\"groups\":[{\"currents\":[1,2,3],\"ctRatio\":240,\"base\":315,\"clock\":0},{\"currents\":[4,5,6],\"ctRatio\":800,\"base\":1732,\"clock\":11}]}"})
+			return
+		}
+		if req.Restraint == "" {
+			req.Restraint = comtrade.RestraintSum
+		}
+		bc := comtrade.DefaultBiasCharacteristic
+		if req.Characteristic != nil {
+			bc = *req.Characteristic
+		}
+		if err := bc.Validate(); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_CHARACTERISTIC", "差动特性参数无效", gin.H{"detail": err.Error()})
+			return
+		}
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+
+		opts, ok := parseAnalogOptions(c)
+		if !ok {
+			return
+		}
+
+		// base 须各侧统一: 部分给出时标幺值与安培相加没有意义
+		perUnit := req.Groups[0].Base > 0
+		for gi, g := range req.Groups {
+			if (g.Base > 0) != perUnit {
+				writeError(c, http.StatusBadRequest, "INVALID_DIFFERENTIAL_BASE", "各侧额定电流须全部给出或全部省略", gin.H{"group": gi + 1})
+				return
+			}
+			// ctRatio 作用于记录值, 与 values=primary|secondary 的 CFG 变比换算叠加会重复换算
+			if g.CTRatio > 0 && opts.Side != comtrade.ValueSideRaw {
+				writeError(c, http.StatusBadRequest, "CT_RATIO_CONFLICT", "ctRatio只能与values=raw同时使用", gin.H{"group": gi + 1, "values": opts.Side, "hint": "已按CFG变比换算到一次/二次侧时请省略ctRatio"})
+				return
+			}
+		}
+
+		groups := make([]comtrade.DifferentialGroup, len(req.Groups))
+		unit := ""
+		for gi, g := range req.Groups {
+			if len(g.Currents) != 3 {
+				writeError(c, http.StatusBadRequest, "INVALID_THREE_PHASE_GROUP", "每组需要指定三相电流通道", gin.H{"group": gi + 1, "hint": "按A,B,C顺序, 例如\"currents\":[4,5,6]"})
+				return
+			}
+			groups[gi] = comtrade.DifferentialGroup{
+				CTRatio:            g.CTRatio,
+				Base:               g.Base,
+				Clock:              g.Clock,
+				RemoveZeroSequence: g.RemoveZeroSequence,
+			}
+			// 有名值时各通道单位须一致; 标幺值时各侧 base 与本侧通道同单位, 只要求组内一致
+			groupUnit := ""
+			for k, chNum := range g.Currents {
+				var chUnit string
+				if groups[gi].Currents[k], chUnit, err = comtrade.AnalogValues(meta, dat, chNum, opts); err != nil {
+					writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+					return
+				}
+				if k == 0 {
+					groupUnit = chUnit
+				}
+				if gi == 0 && k == 0 {
+					unit = chUnit
+				}
+				expected := unit
+				if perUnit {
+					expected = groupUnit
+				}
+				if chUnit != expected {
+					writeError(c, http.StatusBadRequest, "DIFFERENTIAL_UNIT_MISMATCH", "差动电流通道单位不一致", gin.H{"group": gi + 1, "channel": chNum, "unit": chUnit, "expected": expected})
+					return
+				}
+			}
+		}
+
+		result, err := comtrade.ComputeDifferential(groups, meta.SampleRateAt(0), meta.NominalFrequency(), req.Restraint)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "DIFFERENTIAL_COMPUTE_FAILED", "差动电流计算失败", gin.H{"detail": err.Error()})
+			return
+		}
+
+		targetPoints := 2000
+		if tp := c.Query("targetPoints"); tp != "" {
+			if v, err := strconv.Atoi(tp); err == nil && v > 0 {
+				targetPoints = v
+			}
+		}
+
+		timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		n := min(len(timestamps), len(result.Id[0]))
+		start, end := parseIndexWindow(c, n)
+		indices := evenIndices(start, end, targetPoints)
+
+		// 动作判定使用全分辨率数据, 绘图序列按 indices 抽取
+		operated := false
+		irMax := bc.Breakpoint * 1.5
+		phases := make([]map[string]any, 0, 3)
+		for k, phase := range []string{"A", "B", "C"} {
+			firstOperate := -1
+			maxID, maxIR := 0.0, 0.0
+			for i := start; i <= end; i++ {
+				maxID = math.Max(maxID, result.Id[k][i])
+				maxIR = math.Max(maxIR, result.Ir[k][i])
+				if firstOperate < 0 && bc.Operates(result.Id[k][i], result.Ir[k][i]) {
+					firstOperate = i
+				}
+			}
+			irMax = math.Max(irMax, maxIR*1.1)
+
+			id := make([]float64, len(indices))
+			ir := make([]float64, len(indices))
+			operate := make([]bool, len(indices))
+			for i, idx := range indices {
+				id[i], ir[i] = result.Id[k][idx], result.Ir[k][idx]
+				operate[i] = bc.Operates(id[i], ir[i])
+			}
+
+			item := map[string]any{
+				"phase":        phase,
+				"id":           id,
+				"ir":           ir,
+				"operate":      operate,
+				"maxId":        maxID,
+				"maxIr":        maxIR,
+				"firstOperate": firstOperate,
+			}
+			if firstOperate >= 0 {
+				operated = true
+				item["firstOperateTime"] = timestamps[firstOperate]
+			}
+			phases = append(phases, item)
+		}
+
+		if perUnit {
+			unit = "pu"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"unit":           unit,
+			"restraint":      req.Restraint,
+			"characteristic": bc,
+			"curve":          characteristicCurve(bc, irMax),
+			"operated":       operated,
+			"phases":         phases,
+			"indices":        indices,
+			"times":          indicesToTimes(timestamps, indices),
+			"values":         opts.Side,
+			"skew":           opts.CompensateSkew,
+			"window":         map[string]int{"start": start, "end": end},
+		})
+	})
+}
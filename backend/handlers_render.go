@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/render"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	renderDefaultWidth = 1200
+	renderMinSize      = 200
+	renderMaxSize      = 4000
+)
+
+// parseRenderSize 解析图像宽度或高度参数, 缺省时返回 def, 超出范围时写入错误响应并返回 false
+func parseRenderSize(c *gin.Context, name string, def int) (int, bool) {
+	s := c.Query(name)
+	if s == "" {
+		return def, true
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < renderMinSize || v > renderMaxSize {
+		writeError(c, http.StatusBadRequest, "INVALID_IMAGE_SIZE", "图像尺寸无效", gin.H{name: s, "min": renderMinSize, "max": renderMaxSize})
+		return 0, false
+	}
+	return v, true
+}
+
+// renderIndices 返回 [start, end] 内的全部采样序号
+func renderIndices(start, end int) []int {
+	out := make([]int, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+// analogRenderSeries 截取窗口内的数据并以 LTTB 降采样到 targetPoints 点;
+// 派生量中的 NaN/±Inf(如除数过零)无法绘制, 与 waveforms 接口一致连同时间一起丢弃
+func analogRenderSeries(times []float32, indices []int, y []float64, targetPoints int) ([]float64, []float64) {
+	rangeY := make([]float64, 0, len(indices))
+	rangeIdx := make([]int, 0, len(indices))
+	for _, idx := range indices {
+		if idx >= len(y) {
+			break
+		}
+		if math.IsNaN(y[idx]) || math.IsInf(y[idx], 0) {
+			continue
+		}
+		rangeY = append(rangeY, y[idx])
+		rangeIdx = append(rangeIdx, idx)
+	}
+	outT, outY := comtrade.DownsampleLTTB(times, rangeIdx, rangeY, targetPoints)
+	x := make([]float64, len(outT))
+	for i, idx := range outT {
+		x[i] = float64(times[idx])
+	}
+	return x, outY
+}
+
+// digitalRenderSeries 截取窗口内的开关量数据, 仅保留变位点
+func digitalRenderSeries(times []float32, indices []int, raw []int8) ([]float64, []float64) {
+	rangeY := make([]int8, 0, len(indices))
+	for _, idx := range indices {
+		if idx >= len(raw) {
+			break
+		}
+		rangeY = append(rangeY, raw[idx])
+	}
+	outT, outY := comtrade.DownsampleDigital(indices[:len(rangeY)], rangeY)
+	x := make([]float64, len(outT))
+	y := make([]float64, len(outY))
+	for i, idx := range outT {
+		x[i] = float64(times[idx])
+		y[i] = float64(outY[i])
+	}
+	return x, y
+}
+
+// renderMarkers 返回触发时刻与数据集标注的时间标记
+func renderMarkers(ctx context.Context, stor storage.Storage, id string, meta *comtrade.Metadata, withAnnotations bool) []render.Marker {
+	trigger := float64(meta.EndTime.Sub(meta.StartTime).Microseconds()) / 1000
+	markers := []render.Marker{{Start: trigger, End: trigger, Label: "Trigger", Trigger: true}}
+	if !withAnnotations {
+		return markers
+	}
+
+	var anns []map[string]any
+	if data, err := readComtradeFile(ctx, stor, id, "annotations.json"); err == nil {
+		_ = json.Unmarshal(data, &anns)
+	}
+	for _, a := range anns {
+		note, _ := a["note"].(string)
+		switch a["type"] {
+		case "range":
+			start, ok1 := a["start"].(float64)
+			end, ok2 := a["end"].(float64)
+			if ok1 && ok2 {
+				markers = append(markers, render.Marker{Start: start, End: end, Label: note})
+			}
+		case "point":
+			if t, ok := a["t"].(float64); ok {
+				markers = append(markers, render.Marker{Start: t, End: t, Label: note})
+			}
+		}
+	}
+	return markers
+}
+
+// buildRenderChart 按查询参数收集通道并构造波形图, 失败时写入错误响应并返回 false
+//   - A=1,2,3&D=1,2 及派生量参数与 /waveform 相同
+//   - startTime/endTime 采样序号窗口, 默认整个记录
+//   - width/height 图像尺寸(像素), height 缺省时按面板数量自动确定
+//   - annotations=false 不绘制标注, title 覆盖默认标题
+func buildRenderChart(c *gin.Context, stor storage.Storage, id string, meta *comtrade.Metadata, dat *comtrade.ChannelData) (*render.Chart, bool) {
+	n := len(dat.Timestamps)
+	if n == 0 {
+		writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+		return nil, false
+	}
+	opts, ok := parseAnalogOptions(c)
+	if !ok {
+		return nil, false
+	}
+	width, ok := parseRenderSize(c, "width", renderDefaultWidth)
+	if !ok {
+		return nil, false
+	}
+	derived, ok := collectDerivedSeries(c, stor, id, meta, dat, opts)
+	if !ok {
+		return nil, false
+	}
+
+	times := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, n)
+	start, end := parseIndexWindow(c, n)
+	indices := renderIndices(start, end)
+	// 每个像素列保留约两个点, 足以表现波形包络
+	targetPoints := 2 * width
+
+	series := make([]render.Series, 0)
+	for _, chNum := range parseOrderedChannels(c.Query("A")) {
+		ch, ok := meta.AnalogChannelByNumber(chNum)
+		if !ok {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+			return nil, false
+		}
+		y, unit, err := comtrade.AnalogValues(meta, dat, chNum, opts)
+		if err != nil {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum})
+			return nil, false
+		}
+		x, ys := analogRenderSeries(times, indices, y, targetPoints)
+		series = append(series, render.Series{Label: ch.ChannelName, Short: fmt.Sprintf("A%d", chNum), Unit: unit, Phase: ch.Phase, X: x, Y: ys})
+	}
+	for _, chNum := range parseOrderedChannels(c.Query("D")) {
+		ch, ok := meta.DigitalChannelByNumber(chNum)
+		if !ok {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum, "type": "digital"})
+			return nil, false
+		}
+		raw, err := dat.GetDigitalData(chNum)
+		if err != nil {
+			writeError(c, http.StatusNotFound, "CHANNEL_NOT_FOUND", "未找到通道", gin.H{"channel": chNum, "type": "digital"})
+			return nil, false
+		}
+		x, ys := digitalRenderSeries(times, indices, raw)
+		series = append(series, render.Series{Label: ch.ChannelName, Short: fmt.Sprintf("D%d", chNum), Phase: ch.Phase, Digital: true, X: x, Y: ys})
+	}
+	for k, d := range derived {
+		x, ys := analogRenderSeries(times, indices, d.Y, targetPoints)
+		series = append(series, render.Series{Label: d.Name, Short: fmt.Sprintf("X%d", k+1), Unit: d.Unit, X: x, Y: ys})
+	}
+	if len(series) == 0 {
+		writeError(c, http.StatusBadRequest, "NO_CHANNELS_SPECIFIED", "no channel specified", gin.H{"hint": "请通过查询参数A和D指定所需的模拟和数字通道, 例如?A=1,2,3&D=1,2"})
+		return nil, false
+	}
+
+	height, ok := parseRenderSize(c, "height", min(renderMaxSize, max(renderMinSize, render.DefaultHeight(series))))
+	if !ok {
+		return nil, false
+	}
+
+	startText := meta.StartTime.Format("2006-01-02 15:04:05.000000")
+	title := c.Query("title")
+	if title == "" {
+		parts := make([]string, 0, 3)
+		for _, s := range []string{meta.Station, meta.Relay, startText} {
+			if s = strings.TrimSpace(s); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		title = strings.Join(parts, "  ")
+	}
+
+	return &render.Chart{
+		Title:      title,
+		TitleShort: startText,
+		Width:      width,
+		Height:     height,
+		XMin:       float64(times[start]),
+		XMax:       float64(times[end]),
+		Series:     series,
+		Markers:    renderMarkers(c.Request.Context(), stor, id, meta, c.DefaultQuery("annotations", "true") != "false"),
+	}, true
+}
+
+// registerRenderRoutes 注册服务端波形图接口, 用于报告与无浏览器环境
+func registerRenderRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	prepare := func(c *gin.Context) (*render.Chart, bool) {
+		id := c.Param("id")
+		meta, dat, err := parseComtrade(cache, stor, id, c.Request.Context(), c)
+		if err != nil {
+			writeParseError(c, err)
+			return nil, false
+		}
+		return buildRenderChart(c, stor, id, meta, dat)
+	}
+
+	r.GET("/api/datasets/:id/render.png", func(c *gin.Context) {
+		chart, ok := prepare(c)
+		if !ok {
+			return
+		}
+		canvas := render.NewRaster(chart.Width, chart.Height)
+		chart.Draw(canvas)
+		var buf bytes.Buffer
+		if err := canvas.EncodePNG(&buf); err != nil {
+			writeError(c, http.StatusInternalServerError, "RENDER_FAILED", "图像生成失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", buf.Bytes())
+	})
+
+	r.GET("/api/datasets/:id/render.svg", func(c *gin.Context) {
+		chart, ok := prepare(c)
+		if !ok {
+			return
+		}
+		canvas := render.NewSVG(chart.Width, chart.Height)
+		chart.Draw(canvas)
+		var buf bytes.Buffer
+		if _, err := canvas.WriteTo(&buf); err != nil {
+			writeError(c, http.StatusInternalServerError, "RENDER_FAILED", "图像生成失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", buf.Bytes())
+	})
+}
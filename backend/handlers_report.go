@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/render"
+	"comtradeviewer/report"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reportTemplateDir 报告模板的存储目录, 以下划线开头, 不会被列为数据集
+const reportTemplateDir = "_reports"
+
+// reportSections 报告可包含的章节(默认按此顺序全部包含)
+var reportSections = []string{"metadata", "plots", "events", "statistics", "annotations", "notes"}
+
+const (
+	reportChartWidth  = 900  // 波形图排版宽度(像素), 按版心宽度缩放
+	reportChartHeight = 1150 // 单幅波形图的最大排版高度, 缩放后不超过一页
+	reportMaxEvents   = 1000
+)
+
+// reportPlot 报告中的一幅波形图
+type reportPlot struct {
+	Title    string   `json:"title"`
+	Channels []string `json:"channels"`
+}
+
+// reportTemplate 按站名保存的报告模板, 通道引用 "A1"/"D3" 按编号, 其余按通道名称
+//   - Sections:    章节及顺序, 取值见 reportSections, 为空时包含全部章节
+//   - Plots:       波形图, 为空时绘制前 8 个模拟量及有变位的开关量
+//   - Statistics:  统计表的模拟量通道, 为空时取波形图中的全部模拟量
+//   - PreTrigger/PostTrigger: 报告窗口为触发前后的毫秒数, 均为 0 时为整个记录
+//   - Values:      数值侧, 默认 primary
+type reportTemplate struct {
+	Station     string       `json:"station"`
+	Title       string       `json:"title"`
+	Sections    []string     `json:"sections"`
+	Plots       []reportPlot `json:"plots"`
+	Statistics  []string     `json:"statistics"`
+	PreTrigger  float64      `json:"preTrigger"`
+	PostTrigger float64      `json:"postTrigger"`
+	Values      string       `json:"values"`
+	Notes       string       `json:"notes"`
+}
+
+// loadReportTemplates 读取全部报告模板, 文件不存在时返回空列表
+func loadReportTemplates(ctx context.Context, stor storage.Storage) []reportTemplate {
+	out := make([]reportTemplate, 0)
+	if data, err := readComtradeFile(ctx, stor, reportTemplateDir, "templates.json"); err == nil {
+		_ = json.Unmarshal(data, &out)
+	}
+	return out
+}
+
+// saveReportTemplates 写入全部报告模板
+func saveReportTemplates(ctx context.Context, stor storage.Storage, templates []reportTemplate) error {
+	b, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeComtradeFile(ctx, stor, path.Join(reportTemplateDir, "templates.json"), b)
+}
+
+// findReportTemplate 按站名查找模板(忽略首尾空白)
+func findReportTemplate(templates []reportTemplate, station string) (reportTemplate, bool) {
+	station = strings.TrimSpace(station)
+	for _, t := range templates {
+		if t.Station == station {
+			return t, true
+		}
+	}
+	return reportTemplate{}, false
+}
+
+// validateReportTemplate 校验模板字段, 返回第一个错误
+func validateReportTemplate(t reportTemplate) error {
+	if t.Station == "" {
+		return fmt.Errorf("station is required")
+	}
+	for _, s := range t.Sections {
+		if !slices.Contains(reportSections, s) {
+			return fmt.Errorf("unknown section %q", s)
+		}
+	}
+	for i, p := range t.Plots {
+		if len(p.Channels) == 0 {
+			return fmt.Errorf("plot %d has no channels", i+1)
+		}
+	}
+	if t.PreTrigger < 0 || t.PostTrigger < 0 {
+		return fmt.Errorf("preTrigger and postTrigger must not be negative")
+	}
+	if !comtrade.IsValidValueSide(t.Values) {
+		return fmt.Errorf("invalid values %q", t.Values)
+	}
+	return nil
+}
+
+var channelRefPattern = regexp.MustCompile(`^([AaDd])(\d+)$`)
+
+// resolveReportChannel 解析通道引用, 返回是否为开关量与通道编号
+func resolveReportChannel(meta *comtrade.Metadata, ref string) (bool, int, bool) {
+	ref = strings.TrimSpace(ref)
+	if m := channelRefPattern.FindStringSubmatch(ref); m != nil {
+		num, _ := strconv.Atoi(m[2])
+		if strings.EqualFold(m[1], "A") {
+			if _, ok := meta.AnalogChannelByNumber(num); ok {
+				return false, num, true
+			}
+		} else if _, ok := meta.DigitalChannelByNumber(num); ok {
+			return true, num, true
+		}
+	}
+	for _, ch := range meta.AnalogChannels {
+		if strings.TrimSpace(ch.ChannelName) == ref {
+			return false, ch.ChannelNumber, true
+		}
+	}
+	for _, ch := range meta.DigitalChannels {
+		if strings.TrimSpace(ch.ChannelName) == ref {
+			return true, ch.ChannelNumber, true
+		}
+	}
+	return false, 0, false
+}
+
+// defaultReportPlots 未配置波形图时: 前 8 个模拟量一幅, 有变位的前 16 个开关量一幅
+func defaultReportPlots(meta *comtrade.Metadata, events []comtrade.DigitalEvent) []reportPlot {
+	plots := make([]reportPlot, 0, 2)
+	analog := make([]string, 0, 8)
+	for _, ch := range meta.AnalogChannels[:min(8, len(meta.AnalogChannels))] {
+		analog = append(analog, fmt.Sprintf("A%d", ch.ChannelNumber))
+	}
+	if len(analog) > 0 {
+		plots = append(plots, reportPlot{Title: "模拟量", Channels: analog})
+	}
+	digital := make([]string, 0, 16)
+	for _, ev := range events {
+		ref := fmt.Sprintf("D%d", ev.Channel)
+		if len(digital) < 16 && !slices.Contains(digital, ref) {
+			digital = append(digital, ref)
+		}
+	}
+	if len(digital) > 0 {
+		plots = append(plots, reportPlot{Title: "开关量", Channels: digital})
+	}
+	return plots
+}
+
+// reportBuilder 报告生成过程中共用的数据
+type reportBuilder struct {
+	ctx        context.Context
+	stor       storage.Storage
+	id         string
+	meta       *comtrade.Metadata
+	dat        *comtrade.ChannelData
+	tmpl       reportTemplate
+	opts       comtrade.AnalogOptions
+	times      []float32
+	trigger    float64                 // 触发时刻, 相对记录开始的毫秒数
+	start, end int                     // 报告窗口的采样序号
+	events     []comtrade.DigitalEvent // 报告窗口内的开关量变位
+	plotted    []int                   // 波形图中出现的模拟量通道
+	missing    []string
+	doc        *report.Document
+}
+
+// formatMs 格式化相对触发时刻的毫秒数
+func formatMs(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', 3, 64)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 5, 64)
+}
+
+// window 按模板的触发前后时长确定报告窗口
+func (b *reportBuilder) window() {
+	n := len(b.times)
+	b.start, b.end = 0, n-1
+	if b.tmpl.PreTrigger == 0 && b.tmpl.PostTrigger == 0 {
+		return
+	}
+	from := float32(b.trigger - b.tmpl.PreTrigger)
+	to := float32(b.trigger + b.tmpl.PostTrigger)
+	b.start = min(n-1, sort.Search(n, func(i int) bool { return b.times[i] >= from }))
+	b.end = max(b.start, sort.Search(n, func(i int) bool { return b.times[i] > to })-1)
+}
+
+// windowEvents 只保留报告窗口内的开关量变位, 与波形图的时间范围一致
+func (b *reportBuilder) windowEvents() {
+	kept := b.events[:0]
+	for _, ev := range b.events {
+		if ev.Index >= b.start && ev.Index <= b.end {
+			kept = append(kept, ev)
+		}
+	}
+	b.events = kept
+}
+
+func (b *reportBuilder) metadata() {
+	meta := b.meta
+	b.doc.Heading("记录信息", 80)
+
+	rates := make([]string, 0, len(meta.SampleRates))
+	first := 1
+	for _, sr := range meta.SampleRates {
+		if sr.SampRate > 0 {
+			rates = append(rates, fmt.Sprintf("%g Hz (采样 %d~%d)", sr.SampRate, first, sr.LastSampleNum))
+		} else {
+			rates = append(rates, fmt.Sprintf("按时间戳 (采样 %d~%d)", first, sr.LastSampleNum))
+		}
+		first = sr.LastSampleNum + 1
+	}
+	window := "整个记录"
+	if b.tmpl.PreTrigger != 0 || b.tmpl.PostTrigger != 0 {
+		window = fmt.Sprintf("触发前 %g ms 至触发后 %g ms", b.tmpl.PreTrigger, b.tmpl.PostTrigger)
+	}
+	sides := map[string]string{comtrade.ValueSidePrimary: "一次值", comtrade.ValueSideSecondary: "二次值", comtrade.ValueSideRaw: "记录值"}
+
+	b.doc.KeyValues([][2]string{
+		{"站名", meta.Station},
+		{"录波设备", meta.Relay},
+		{"标准版本", meta.Version},
+		{"记录开始时刻", meta.StartTime.Format("2006-01-02 15:04:05.000000")},
+		{"触发时刻", meta.EndTime.Format("2006-01-02 15:04:05.000000")},
+		{"触发前时长", formatMs(b.trigger) + " ms"},
+		{"记录时长", formatMs(float64(b.times[len(b.times)-1])) + " ms"},
+		{"额定频率", fmt.Sprintf("%g Hz", meta.NominalFrequency())},
+		{"采样率", strings.Join(rates, "; ")},
+		{"通道数", fmt.Sprintf("模拟量 %d, 开关量 %d", len(meta.AnalogChannels), len(meta.DigitalChannels))},
+		{"数据格式", meta.DataFileType},
+		{"报告窗口", window},
+		{"数值", sides[b.opts.Side]},
+	})
+}
+
+func (b *reportBuilder) plots() {
+	plots := b.tmpl.Plots
+	if len(plots) == 0 {
+		plots = defaultReportPlots(b.meta, b.events)
+	}
+	b.doc.Heading("波形", 200)
+	if len(plots) == 0 {
+		b.doc.Note("记录中没有可绘制的通道")
+		return
+	}
+
+	indices := renderIndices(b.start, b.end)
+	markers := renderMarkers(b.ctx, b.stor, b.id, b.meta, true)
+	for _, p := range plots {
+		series := make([]render.Series, 0, len(p.Channels))
+		for _, ref := range p.Channels {
+			digital, num, ok := resolveReportChannel(b.meta, ref)
+			if !ok {
+				b.missing = append(b.missing, ref)
+				continue
+			}
+			if digital {
+				ch, _ := b.meta.DigitalChannelByNumber(num)
+				raw, err := b.dat.GetDigitalData(num)
+				if err != nil {
+					continue
+				}
+				x, y := digitalRenderSeries(b.times, indices, raw)
+				series = append(series, render.Series{Label: ch.ChannelName, Phase: ch.Phase, Digital: true, X: x, Y: y})
+				continue
+			}
+			ch, _ := b.meta.AnalogChannelByNumber(num)
+			y, unit, err := comtrade.AnalogValues(b.meta, b.dat, num, b.opts)
+			if err != nil {
+				continue
+			}
+			x, ys := analogRenderSeries(b.times, indices, y, 2*reportChartWidth)
+			series = append(series, render.Series{Label: ch.ChannelName, Unit: unit, Phase: ch.Phase, X: x, Y: ys})
+			if !slices.Contains(b.plotted, num) {
+				b.plotted = append(b.plotted, num)
+			}
+		}
+		if len(series) == 0 {
+			continue
+		}
+		b.doc.Chart(&render.Chart{
+			Title:   p.Title,
+			Width:   reportChartWidth,
+			Height:  min(reportChartHeight, render.DefaultHeight(series)),
+			XMin:    float64(b.times[b.start]),
+			XMax:    float64(b.times[b.end]),
+			Series:  series,
+			Markers: markers,
+		})
+	}
+	if len(b.missing) > 0 {
+		b.doc.Note("模板中的以下通道在本记录中不存在, 已忽略: " + strings.Join(b.missing, ", "))
+	}
+	b.doc.Note("时间轴为相对记录开始的毫秒数, 红色虚线为触发时刻")
+}
+
+func (b *reportBuilder) eventTable() {
+	b.doc.Heading("开关量变位(SOE)", 60)
+
+	initial := make([]string, 0)
+	for _, ch := range b.meta.DigitalChannels {
+		y, err := b.dat.GetDigitalData(ch.ChannelNumber)
+		if err == nil && b.start < len(y) && int(y[b.start]) != ch.Y {
+			initial = append(initial, fmt.Sprintf("D%d %s = %d", ch.ChannelNumber, ch.ChannelName, y[b.start]))
+		}
+	}
+	if len(initial) > 0 {
+		b.doc.Paragraph("报告窗口开始时已偏离正常状态: " + strings.Join(initial, "; "))
+	}
+	if len(b.events) == 0 {
+		b.doc.Note("报告窗口内没有开关量变位")
+		return
+	}
+
+	rows := make([][]string, 0, min(len(b.events), reportMaxEvents))
+	for i, ev := range b.events[:min(len(b.events), reportMaxEvents)] {
+		state := "正常"
+		if ev.Abnormal {
+			state = "偏离正常"
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			formatMs(ev.TriggerTime),
+			ev.Timestamp.Format("15:04:05.000000"),
+			fmt.Sprintf("D%d %s", ev.Channel, ev.Name),
+			fmt.Sprintf("%d → %d", ev.OldState, ev.NewState),
+			state,
+		})
+	}
+	b.doc.Table([]string{"序号", "相对触发(ms)", "时刻", "通道", "变位", "状态"}, []float64{0.07, 0.14, 0.17, 0.38, 0.1, 0.14}, rows)
+	if len(b.events) > reportMaxEvents {
+		b.doc.Note(fmt.Sprintf("共 %d 次变位, 仅列出前 %d 次", len(b.events), reportMaxEvents))
+	}
+}
+
+func (b *reportBuilder) statistics() {
+	channels := make([]int, 0)
+	for _, ref := range b.tmpl.Statistics {
+		if digital, num, ok := resolveReportChannel(b.meta, ref); ok && !digital {
+			channels = append(channels, num)
+		}
+	}
+	if len(b.tmpl.Statistics) == 0 {
+		// 默认为波形图中的模拟量, 未包含波形章节时取前 8 个模拟量
+		channels = b.plotted
+		if len(channels) == 0 {
+			for _, ch := range b.meta.AnalogChannels[:min(8, len(b.meta.AnalogChannels))] {
+				channels = append(channels, ch.ChannelNumber)
+			}
+		}
+	}
+
+	b.doc.Heading("统计量", 60)
+	rows := make([][]string, 0, len(channels))
+	for _, num := range channels {
+		ch, _ := b.meta.AnalogChannelByNumber(num)
+		y, unit, err := comtrade.AnalogValues(b.meta, b.dat, num, b.opts)
+		if err != nil {
+			continue
+		}
+		st := comtrade.AnalogStatistics(y, b.times, b.start, b.end, b.meta.SampleRateAt(b.start), b.meta.NominalFrequency())
+		rows = append(rows, []string{
+			fmt.Sprintf("A%d %s", num, ch.ChannelName),
+			unit,
+			formatValue(st.Max),
+			formatMs(float64(st.MaxTime) - b.trigger),
+			formatValue(st.Min),
+			formatMs(float64(st.MinTime) - b.trigger),
+			formatValue(st.RMS),
+			formatValue(st.DCOffset),
+			formatValue(st.PeakToPeak),
+		})
+	}
+	if len(rows) == 0 {
+		b.doc.Note("没有模拟量通道")
+		return
+	}
+	b.doc.Table([]string{"通道", "单位", "最大值", "时刻(ms)", "最小值", "时刻(ms)", "有效值", "直流分量", "峰峰值"},
+		[]float64{0.22, 0.07, 0.1, 0.1, 0.1, 0.1, 0.1, 0.11, 0.1}, rows)
+	b.doc.Note("统计窗口与报告窗口一致, 时刻为相对触发的毫秒数")
+}
+
+func (b *reportBuilder) annotations() {
+	b.doc.Heading("标注", 40)
+	var anns []map[string]any
+	if data, err := readComtradeFile(b.ctx, b.stor, b.id, "annotations.json"); err == nil {
+		_ = json.Unmarshal(data, &anns)
+	}
+
+	rows := make([][]string, 0, len(anns))
+	for _, a := range anns {
+		var kind, at string
+		switch a["type"] {
+		case "range":
+			start, _ := a["start"].(float64)
+			end, _ := a["end"].(float64)
+			kind, at = "区间", formatMs(start-b.trigger)+" ~ "+formatMs(end-b.trigger)
+		case "point":
+			t, _ := a["t"].(float64)
+			kind, at = "时刻", formatMs(t-b.trigger)
+		default:
+			continue
+		}
+		channel := ""
+		if v, ok := a["channel"].(float64); ok {
+			if ch, ok := b.meta.AnalogChannelByNumber(int(v)); ok {
+				channel = fmt.Sprintf("A%d %s", ch.ChannelNumber, ch.ChannelName)
+			}
+		}
+		source := "人工"
+		if auto, _ := a["auto"].(bool); auto {
+			source, _ = a["source"].(string)
+		}
+		note, _ := a["note"].(string)
+		rows = append(rows, []string{kind, at, channel, note, source})
+	}
+	if len(rows) == 0 {
+		b.doc.Note("没有标注")
+		return
+	}
+	b.doc.Table([]string{"类型", "相对触发(ms)", "通道", "说明", "来源"}, []float64{0.08, 0.2, 0.18, 0.4, 0.14}, rows)
+}
+
+// build 按模板生成报告文档
+func (b *reportBuilder) build() {
+	title := b.tmpl.Title
+	if title == "" {
+		title = "故障录波报告"
+	}
+	b.doc = report.NewDocument(title)
+	subtitle := strings.TrimSpace(strings.Join([]string{b.meta.Station, b.meta.Relay, b.meta.EndTime.Format("2006-01-02 15:04:05.000")}, "  "))
+	b.doc.Title(title, subtitle)
+
+	sections := b.tmpl.Sections
+	if len(sections) == 0 {
+		sections = reportSections
+	}
+	for _, s := range sections {
+		switch s {
+		case "metadata":
+			b.metadata()
+		case "plots":
+			b.plots()
+		case "events":
+			b.eventTable()
+		case "statistics":
+			b.statistics()
+		case "annotations":
+			b.annotations()
+		case "notes":
+			if b.tmpl.Notes != "" {
+				b.doc.Heading("备注", 20)
+				b.doc.Paragraph(b.tmpl.Notes)
+			}
+		}
+	}
+	b.doc.Footer(fmt.Sprintf("%s  %s", title, b.meta.Station))
+}
+
+// registerReportRoutes 注册报告模板管理与 PDF 报告生成接口
+func registerReportRoutes(r *gin.Engine, stor storage.Storage, cache *comtrade.DatasetCache) {
+	r.GET("/api/report-templates", func(c *gin.Context) {
+		c.JSON(http.StatusOK, loadReportTemplates(c.Request.Context(), stor))
+	})
+
+	// 新建或按站名覆盖模板
+	r.POST("/api/report-templates", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var req reportTemplate
+		if err := c.BindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "BAD_JSON", "JSON格式错误", gin.H{"detail": err.Error()})
+			return
+		}
+		req.Station = strings.TrimSpace(req.Station)
+		if err := validateReportTemplate(req); err != nil {
+			writeError(c, http.StatusBadRequest, "INVALID_REPORT_TEMPLATE", "报告模板无效", gin.H{"detail": err.Error(), "sections": reportSections})
+			return
+		}
+
+		templates := loadReportTemplates(ctx, stor)
+		replaced := false
+		for i := range templates {
+			if templates[i].Station == req.Station {
+				templates[i] = req
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			templates = append(templates, req)
+		}
+		if err := saveReportTemplates(ctx, stor, templates); err != nil {
+			writeError(c, http.StatusInternalServerError, "REPORT_TEMPLATE_WRITE_ERROR", "写入报告模板失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, req)
+	})
+
+	r.DELETE("/api/report-templates/:station", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		station := strings.TrimSpace(c.Param("station"))
+		templates := loadReportTemplates(ctx, stor)
+		kept := make([]reportTemplate, 0, len(templates))
+		for _, t := range templates {
+			if t.Station != station {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == len(templates) {
+			writeError(c, http.StatusNotFound, "REPORT_TEMPLATE_NOT_FOUND", "未找到报告模板", gin.H{"station": station})
+			return
+		}
+		if err := saveReportTemplates(ctx, stor, kept); err != nil {
+			writeError(c, http.StatusInternalServerError, "REPORT_TEMPLATE_WRITE_ERROR", "写入报告模板失败", gin.H{"detail": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	// 生成 PDF 报告: 默认使用与记录站名相同的模板, 没有时使用默认内容
+	r.GET("/api/datasets/:id/report.pdf", func(c *gin.Context) {
+		id := c.Param("id")
+		ctx := c.Request.Context()
+
+		meta, dat, err := parseComtrade(cache, stor, id, ctx, c)
+		if err != nil {
+			writeParseError(c, err)
+			return
+		}
+		if len(dat.Timestamps) == 0 {
+			writeError(c, http.StatusInternalServerError, "NO_DATA", "未找到通道数据", gin.H{"id": id})
+			return
+		}
+
+		templates := loadReportTemplates(ctx, stor)
+		tmpl, _ := findReportTemplate(templates, meta.Station)
+		if name := c.Query("template"); name != "" {
+			var found bool
+			if tmpl, found = findReportTemplate(templates, name); !found {
+				writeError(c, http.StatusNotFound, "REPORT_TEMPLATE_NOT_FOUND", "未找到报告模板", gin.H{"station": name})
+				return
+			}
+		}
+		side := tmpl.Values
+		if side == "" {
+			side = comtrade.ValueSidePrimary
+		}
+		opts, ok := parseAnalogOptionsWithDefault(c, side)
+		if !ok {
+			return
+		}
+
+		times := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+		b := &reportBuilder{
+			ctx:     ctx,
+			stor:    stor,
+			id:      id,
+			meta:    meta,
+			dat:     dat,
+			tmpl:    tmpl,
+			opts:    opts,
+			times:   times,
+			trigger: float64(meta.EndTime.Sub(meta.StartTime).Microseconds()) / 1000,
+			events:  comtrade.DigitalEvents(meta, dat, nil, times),
+		}
+		b.window()
+		b.windowEvents()
+		b.build()
+
+		var buf bytes.Buffer
+		if _, err := b.doc.PDF().WriteTo(&buf); err != nil {
+			writeError(c, http.StatusInternalServerError, "REPORT_FAILED", "报告生成失败", gin.H{"detail": err.Error()})
+			return
+		}
+		setAttachment(c, exportBaseName(c, stor, id)+".pdf", "application/pdf")
+		c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+	})
+}
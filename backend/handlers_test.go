@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCFG 两个模拟量: A1 码值为 i, A2 码值为 i-20(在第 21 个采样过零); 1000Hz, 40 个采样
+const testCFG = "站A,REC1,1999\n2,2A,0D\n1,Ia,A,,A,1,0,0,-32767,32767,1,1,S\n2,Ib,B,,A,1,0,0,-32767,32767,1,1,S\n50\n1\n1000,40\n01/01/2024,00:00:00.000000\n01/01/2024,00:00:00.010000\nASCII\n1\n"
+
+// newTestServer 创建使用临时目录存储的服务, 预置数据集 ds1
+func newTestServer(t *testing.T) (*gin.Engine, storage.Storage) {
+	t.Helper()
+	dir := t.TempDir()
+	stor, err := storage.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dat strings.Builder
+	for i := range 40 {
+		fmt.Fprintf(&dat, "%d,%d,%d,%d\n", i+1, i*1000, i, i-20)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "ds1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "ds1", "rec.cfg"), []byte(testCFG), 0o644)
+	os.WriteFile(filepath.Join(dir, "ds1", "rec.dat"), []byte(dat.String()), 0o644)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerComtradeRoutes(r, stor)
+	return r, stor
+}
+
+func doRequest(r *gin.Engine, method, url, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// defineNonFiniteDerived 在 ds1 上定义 A1/A2 与 log(A2) 两个会产生 NaN/±Inf 的派生量, 返回其 ID
+func defineNonFiniteDerived(t *testing.T, r *gin.Engine) []string {
+	t.Helper()
+	for name, expr := range map[string]string{"ratio": "A1/A2", "ln": "log(A2)"} {
+		w := doRequest(r, "POST", "/api/datasets/ds1/derived", fmt.Sprintf(`{"name":%q,"expression":%q}`, name, expr))
+		if w.Code != http.StatusOK {
+			t.Fatalf("define %s: %d %s", name, w.Code, w.Body.String())
+		}
+	}
+	var defined []struct{ ID, Name string }
+	json.Unmarshal(doRequest(r, "GET", "/api/datasets/ds1/derived", "").Body.Bytes(), &defined)
+	ids := make([]string, 0, len(defined))
+	for _, d := range defined {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+func TestWaveformsDerivedNonFinite(t *testing.T) {
+	r, _ := newTestServer(t)
+	ids := defineNonFiniteDerived(t, r)
+
+	if w := doRequest(r, "GET", "/api/datasets/ds1/waveforms?X="+ids[0]+",nope", ""); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "DERIVED_NOT_FOUND") {
+		t.Errorf("unknown derived id: %d %s", w.Code, w.Body.String())
+	}
+
+	w := doRequest(r, "GET", "/api/datasets/ds1/waveforms?downsample=none&X="+strings.Join(ids, ","), "")
+	var resp struct {
+		Series []struct {
+			Name  string
+			Times []int
+			Y     []float64
+		}
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		t.Fatalf("waveforms: %d %q", w.Code, w.Body.String())
+	}
+	if len(resp.Series) != 2 {
+		t.Fatalf("series: %+v", resp.Series)
+	}
+	for _, s := range resp.Series {
+		switch s.Name {
+		case "ratio":
+			// A2 在第 20 个点为 0, 该点被丢弃, 其余点保留
+			if len(s.Y) != 39 || slices.Contains(s.Times, 20) || s.Times[20] != 21 || s.Y[20] != 21 {
+				t.Errorf("ratio: times=%v y=%v", s.Times, s.Y)
+			}
+		case "ln":
+			// A2 <= 0 的点(0..20)被丢弃
+			if len(s.Y) != 19 || s.Times[0] != 21 {
+				t.Errorf("ln: times=%v y=%v", s.Times, s.Y)
+			}
+		}
+	}
+}
+
+func TestInvalidResampleParams(t *testing.T) {
+	r, _ := newTestServer(t)
+	for _, query := range []string{"rate=abc", "rate=0", "rate=-100", "rate=NaN", "rate=Inf", "rate=1e9", "rate=4000&resample=foo"} {
+		w := doRequest(r, "GET", "/api/datasets/ds1/waveforms?A=1&"+query, "")
+		var resp struct{ Error struct{ Code string } }
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp.Error.Code != "INVALID_RESAMPLE" {
+			t.Errorf("%s: %d %s", query, w.Code, w.Body.String())
+		}
+	}
+	if w := doRequest(r, "GET", "/api/datasets/ds1/waveforms?A=1&rate=2000&resample=sinc", ""); w.Code != http.StatusOK {
+		t.Errorf("valid resample: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDifferentialRequestValidation(t *testing.T) {
+	r, stor := newTestServer(t)
+	// ds2: Ia 单位为 kA, Ib 为 A
+	ctx := context.Background()
+	dat, _ := readComtradeFile(ctx, stor, "ds1", "dat")
+	writeComtradeFile(ctx, stor, "ds2/rec.cfg", []byte(strings.Replace(testCFG, ",,A,", ",,kA,", 1)))
+	writeComtradeFile(ctx, stor, "ds2/rec.dat", dat)
+
+	post := func(id, query, body string) (int, string, string) {
+		w := doRequest(r, "POST", "/api/datasets/"+id+"/differential"+query, body)
+		var resp struct {
+			Unit  string
+			Error struct{ Code string }
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Error.Code, resp.Unit
+	}
+	for _, tc := range []struct {
+		name, id, query, body, code, unit string
+	}{
+		{"raw with ctRatio", "ds1", "", `{"groups":[{"currents":[1,2,1],"ctRatio":400},{"currents":[2,1,2],"ctRatio":800}]}`, "", "A"},
+		{"per unit", "ds1", "", `{"groups":[{"currents":[1,2,1],"base":200},{"currents":[2,1,2],"base":100}]}`, "", "pu"},
+		{"primary with ctRatio", "ds1", "?values=primary", `{"groups":[{"currents":[1,2,1],"ctRatio":400},{"currents":[2,1,2]}]}`, "CT_RATIO_CONFLICT", ""},
+		{"mixed base", "ds1", "", `{"groups":[{"currents":[1,2,1],"base":200},{"currents":[2,1,2]}]}`, "INVALID_DIFFERENTIAL_BASE", ""},
+		{"unit from channels", "ds2", "", `{"groups":[{"currents":[1,1,1]},{"currents":[1,1,1]}]}`, "", "kA"},
+		{"mixed units", "ds2", "", `{"groups":[{"currents":[1,1,1]},{"currents":[2,2,2]}]}`, "DIFFERENTIAL_UNIT_MISMATCH", ""},
+		{"mixed units per unit", "ds2", "", `{"groups":[{"currents":[1,1,1],"base":0.2},{"currents":[2,2,2],"base":100}]}`, "", "pu"},
+	} {
+		status, code, unit := post(tc.id, tc.query, tc.body)
+		if tc.code != "" && (status != http.StatusBadRequest || code != tc.code) {
+			t.Errorf("%s: want 400 %s, got %d %s", tc.name, tc.code, status, code)
+		}
+		if tc.code == "" && (status != http.StatusOK || unit != tc.unit) {
+			t.Errorf("%s: want 200 unit %s, got %d %s %s", tc.name, tc.unit, status, code, unit)
+		}
+	}
+}
+
+// failingStorage 保存文件名以 suffix 结尾的文件时返回错误
+type failingStorage struct {
+	storage.Storage
+	suffix string
+}
+
+func (s failingStorage) SaveFile(ctx context.Context, path string, data io.Reader) error {
+	if strings.HasSuffix(path, s.suffix) {
+		return errors.New("disk full")
+	}
+	return s.Storage.SaveFile(ctx, path, data)
+}
+
+func TestUploadPartialFailure(t *testing.T) {
+	upload := func(r *gin.Engine) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for field, content := range map[string]string{"cfg": testCFG, "dat": "1,0,1,2\n2,1000,3,4\n"} {
+			fw, _ := mw.CreateFormFile(field, "rec."+field)
+			fw.Write([]byte(content))
+		}
+		mw.Close()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/datasets/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		r.ServeHTTP(w, req)
+		return w
+	}
+	newServer := func(suffix string) (*gin.Engine, storage.Storage) {
+		local, err := storage.NewLocalStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		registerComtradeRoutes(r, failingStorage{local, suffix})
+		return r, local
+	}
+
+	// 清单保存失败: 数据集仍然可用, 以警告提示
+	r, _ := newServer("manifest.json")
+	w := upload(r)
+	var resp struct {
+		DatasetID string
+		Warnings  []string
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "disk full") {
+		t.Fatalf("manifest failure: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, "GET", "/api/datasets/"+resp.DatasetID+"/metadata", ""); w.Code != http.StatusOK {
+		t.Errorf("dataset should be usable: %d %s", w.Code, w.Body.String())
+	}
+
+	// DAT 保存失败: 已保存的 CFG 被删除
+	r, local := newServer(".dat")
+	if w := upload(r); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "DAT_SAVE_FAILED") {
+		t.Fatalf("dat failure: %d %s", w.Code, w.Body.String())
+	}
+	if files, _ := local.ListFiles(context.Background(), ""); len(files) != 0 {
+		t.Errorf("partial dataset left behind: %v", files)
+	}
+}
+
+func TestRenderDerivedNonFinite(t *testing.T) {
+	r, _ := newTestServer(t)
+	ids := strings.Join(defineNonFiniteDerived(t, r), ",")
+
+	w := doRequest(r, "GET", "/api/datasets/ds1/render.svg?A=2&X="+ids, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("svg: %d %s", w.Code, w.Body.String())
+	}
+	svg := w.Body.String()
+	if strings.Contains(svg, "NaN") || strings.Contains(svg, "Inf") || strings.Count(svg, "<polyline") != 3 {
+		t.Errorf("svg should contain 3 finite polylines:\n%s", svg)
+	}
+	if w := doRequest(r, "GET", "/api/datasets/ds1/render.png?A=2&X="+ids, ""); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("png: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestReportEventsWindow(t *testing.T) {
+	b := &reportBuilder{start: 10, end: 20}
+	for _, idx := range []int{5, 10, 15, 20, 25} {
+		b.events = append(b.events, comtrade.DigitalEvent{Index: idx})
+	}
+	b.windowEvents()
+	got := make([]int, 0, len(b.events))
+	for _, ev := range b.events {
+		got = append(got, ev.Index)
+	}
+	if !slices.Equal(got, []int{10, 15, 20}) {
+		t.Errorf("events in window: %v", got)
+	}
+}
+
+func TestCompareMaxLagLimit(t *testing.T) {
+	r, _ := newTestServer(t)
+	body := `{"items":[{"datasetId":"ds1","A":[1],"correlationChannel":1},{"datasetId":"ds1","A":[1],"correlationChannel":1}],"maxLagMs":%g}`
+	if w := doRequest(r, "POST", "/api/compare", fmt.Sprintf(body, 1e6)); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_MAX_LAG") {
+		t.Errorf("large maxLagMs: %d %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, "POST", "/api/compare", fmt.Sprintf(body, 2000.0)); w.Code != http.StatusOK {
+		t.Errorf("maxLagMs at limit: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPowerMixedUnitPrefixes(t *testing.T) {
+	r, stor := newTestServer(t)
+	// Ub 以 V 记录(系数 1000), 与以 kV 记录的 Ua、Uc 数值相同
+	cfg := "站A,REC1,1999\n4,4A,0D\n" +
+		"1,Ua,A,,kV,1,0,0,-32767,32767,1,1,P\n2,Ub,B,,V,1000,0,0,-32767,32767,1,1,P\n" +
+		"3,Uc,C,,kV,1,0,0,-32767,32767,1,1,P\n4,Ia,A,,A,1,0,0,-32767,32767,1,1,P\n" +
+		"50\n1\n1000,40\n01/01/2024,00:00:00.000000\n01/01/2024,00:00:00.010000\nASCII\n1\n"
+	var dat strings.Builder
+	for i := range 40 {
+		fmt.Fprintf(&dat, "%d,%d,%d,%d,%d,%d\n", i+1, i*1000, i%7, i%7, i%7, i%5)
+	}
+	ctx := context.Background()
+	writeComtradeFile(ctx, stor, "ds2/rec.cfg", []byte(cfg))
+	writeComtradeFile(ctx, stor, "ds2/rec.dat", []byte(dat.String()))
+
+	w := doRequest(r, "GET", "/api/datasets/ds2/waveforms?A=4&downsample=none&power=1:4,2:4,3:4%3B1:4,3:4,1:4", "")
+	var resp struct {
+		Series []struct {
+			Channel any
+			Unit    string
+			Y       []float64
+		}
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		t.Fatalf("waveforms: %d %s", w.Code, w.Body.String())
+	}
+	byID := make(map[string][]float64)
+	for _, s := range resp.Series {
+		id := fmt.Sprint(s.Channel)
+		if strings.HasSuffix(id, ".p") && s.Unit != "kW" {
+			t.Errorf("%s unit %q, want kW", id, s.Unit)
+		}
+		byID[id] = s.Y
+	}
+	mixed, same := byID["power1.p"], byID["power2.p"]
+	if len(mixed) != 40 || len(same) != 40 {
+		t.Fatalf("series: %+v", resp.Series)
+	}
+	for j := range mixed {
+		if math.Abs(mixed[j]-same[j]) > 1e-9 {
+			t.Fatalf("sample %d: mixed-prefix group %v, same-unit group %v", j, mixed[j], same[j])
+		}
+	}
+}
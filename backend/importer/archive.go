@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 压缩包解压后的大小上限, 防止压缩炸弹: 上传表单上限为 256MB, 总量按其 2 倍限制, 单个文件不超过表单上限
+const (
+	MaxArchiveBytes      = 512 << 20
+	MaxArchiveEntryBytes = 256 << 20
+)
+
+// archiveExts 按基本名配对的 COMTRADE 文件扩展名(小写)
+var archiveExts = map[string]bool{"cfg": true, "dat": true, "hdr": true, "inf": true}
+
+// ArchiveFile 压缩包中的一个文件
+type ArchiveFile struct {
+	Name string
+	Data []byte
+}
+
+// ArchiveRecord 压缩包中按目录与基本名(不区分大小写)配对的一组 COMTRADE 文件
+//   - Name: 记录名, 取自 CFG 文件名(缺少 CFG 时取自其他文件), 不含扩展名
+//   - Dir: 压缩包内的目录, 根目录为空
+//   - Files: 键为小写扩展名 cfg/dat/hdr/inf
+//   - Duplicates: 仅大小写不同的同名文件, 只保留第一个
+type ArchiveRecord struct {
+	Name       string
+	Dir        string
+	Files      map[string]*ArchiveFile
+	Duplicates []string
+}
+
+// Archive 压缩包内容: 配对后的记录与未识别的文件路径
+type Archive struct {
+	Records []*ArchiveRecord
+	Ignored []string
+}
+
+// ReadArchive 读取 zip、tar.gz 或 tar 压缩包, 按格式标识判断类型;
+// zip 中未标记 UTF-8 且不是合法 UTF-8 的文件名按 GBK 解码, 目录与 macOS 附属文件被跳过
+func ReadArchive(data []byte) (*Archive, error) {
+	var files []*ArchiveFile
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		files, err = readZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			files, err = readTar(zr)
+		}
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		files, err = readTar(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("not a zip or tar.gz archive")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{Ignored: make([]string, 0)}
+	groups := make(map[string]*ArchiveRecord)
+	for _, f := range files {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(f.Name), "."))
+		if !archiveExts[ext] {
+			archive.Ignored = append(archive.Ignored, f.Name)
+			continue
+		}
+		dir, base := path.Split(f.Name)
+		dir = strings.TrimSuffix(dir, "/")
+		name := strings.TrimSuffix(base, path.Ext(base))
+		key := strings.ToLower(path.Join(dir, name))
+		rec := groups[key]
+		if rec == nil {
+			rec = &ArchiveRecord{Name: name, Dir: dir, Files: make(map[string]*ArchiveFile)}
+			groups[key] = rec
+			archive.Records = append(archive.Records, rec)
+		}
+		if rec.Files[ext] != nil {
+			rec.Duplicates = append(rec.Duplicates, f.Name)
+			continue
+		}
+		rec.Files[ext] = f
+		if ext == "cfg" {
+			rec.Name = name
+		}
+	}
+	sort.Slice(archive.Records, func(i, j int) bool {
+		a, b := archive.Records[i], archive.Records[j]
+		return strings.ToLower(path.Join(a.Dir, a.Name)) < strings.ToLower(path.Join(b.Dir, b.Name))
+	})
+	return archive, nil
+}
+
+// skipArchiveEntry 判断是否跳过 macOS 打包时附带的资源文件
+func skipArchiveEntry(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._")
+}
+
+func readZip(data []byte) ([]*ArchiveFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	// 中央目录记录了各文件的解压大小, 先整体检查, 过大的压缩包不读取任何内容
+	var declared int64
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if err := checkEntrySize(zf.Name, int64(zf.UncompressedSize64), declared); err != nil {
+			return nil, err
+		}
+		declared += int64(zf.UncompressedSize64)
+	}
+	var files []*ArchiveFile
+	var total int64
+	for _, zf := range zr.File {
+		name := zf.Name
+		if zf.NonUTF8 && !utf8.ValidString(name) {
+			if decoded, err := simplifiedchinese.GBK.NewDecoder().String(name); err == nil {
+				name = decoded
+			}
+		}
+		name = cleanArchivePath(name)
+		if zf.FileInfo().IsDir() || name == "" || skipArchiveEntry(name) {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", name, err)
+		}
+		b, err := readLimited(rc, &total)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		files = append(files, &ArchiveFile{Name: name, Data: b})
+	}
+	return files, nil
+}
+
+func readTar(r io.Reader) ([]*ArchiveFile, error) {
+	tr := tar.NewReader(r)
+	var files []*ArchiveFile
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+		name := cleanArchivePath(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || name == "" || skipArchiveEntry(name) {
+			continue
+		}
+		if err := checkEntrySize(name, hdr.Size, total); err != nil {
+			return nil, err
+		}
+		b, err := readLimited(tr, &total)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		files = append(files, &ArchiveFile{Name: name, Data: b})
+	}
+}
+
+// checkEntrySize 按文件头声明的大小检查是否超限, 在读取内容之前拒绝过大的压缩包
+func checkEntrySize(name string, size, total int64) error {
+	if size > MaxArchiveEntryBytes {
+		return fmt.Errorf("%s exceeds %d bytes when extracted", name, int64(MaxArchiveEntryBytes))
+	}
+	if total+size > MaxArchiveBytes {
+		return fmt.Errorf("archive exceeds %d bytes when extracted", int64(MaxArchiveBytes))
+	}
+	return nil
+}
+
+// readLimited 读取一个文件并累计总字节数; 文件头声明的大小可能不实, 实际读取量同样受
+// MaxArchiveEntryBytes 与 MaxArchiveBytes 限制
+func readLimited(r io.Reader, total *int64) ([]byte, error) {
+	limit := min(int64(MaxArchiveEntryBytes), MaxArchiveBytes-*total)
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > int64(MaxArchiveEntryBytes) {
+		return nil, fmt.Errorf("entry exceeds %d bytes when extracted", int64(MaxArchiveEntryBytes))
+	}
+	*total += int64(len(b))
+	if *total > MaxArchiveBytes {
+		return nil, fmt.Errorf("archive exceeds %d bytes when extracted", int64(MaxArchiveBytes))
+	}
+	return b, nil
+}
+
+// cleanArchivePath 统一路径分隔符并去掉开头的 "/" 与 "./"
+func cleanArchivePath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	return strings.TrimPrefix(name, "/")
+}
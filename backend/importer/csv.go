@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// CSVOptions CSV 文件的读取参数
+//   - Delimiter: ","|";"|"\t"|"space", 为空时按首行识别; 分号分隔时允许逗号作小数点
+//   - SkipRows: 表头之前需要跳过的行数(如仿真软件输出的说明行)
+//   - TimeColumn: 时间列的列名或列号, 为空时识别名为 time/t/时间/domain(PSCAD) 的列
+//   - TimeUnit: 时间列单位 s|ms|us, 默认 s
+type CSVOptions struct {
+	Delimiter  string `json:"delimiter"`
+	NoHeader   bool   `json:"noHeader"`
+	SkipRows   int    `json:"skipRows"`
+	TimeColumn string `json:"timeColumn"`
+	TimeUnit   string `json:"timeUnit"`
+}
+
+// timeUnitSeconds 时间单位换算为秒的系数
+var timeUnitSeconds = map[string]float64{"": 1, "s": 1, "ms": 1e-3, "us": 1e-6}
+
+// ReadCSV 读取 CSV 文件为时间序列; 文件可为 UTF-8(可带 BOM)或 GBK 编码
+func ReadCSV(data []byte, opts CSVOptions) (*TimeSeries, error) {
+	unit, ok := timeUnitSeconds[strings.ToLower(opts.TimeUnit)]
+	if !ok {
+		return nil, fmt.Errorf("invalid time unit %q, expected s|ms|us", opts.TimeUnit)
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	if !utf8.Valid(data) {
+		decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("decode GBK: %w", err)
+		}
+		data = decoded
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if opts.SkipRows < 0 || opts.SkipRows >= len(lines) {
+		return nil, fmt.Errorf("skipRows %d out of range", opts.SkipRows)
+	}
+	text := strings.Join(lines[opts.SkipRows:], "")
+
+	delimiter := opts.Delimiter
+	if delimiter == "" {
+		delimiter = detectDelimiter(text)
+	}
+	rows, err := splitRows(text, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	width := len(rows[0])
+	header := make([]string, width)
+	first := opts.SkipRows + 1
+	if opts.NoHeader {
+		for i := range header {
+			header[i] = "C" + strconv.Itoa(i+1)
+		}
+	} else {
+		for i, cell := range rows[0] {
+			header[i] = strings.TrimSpace(cell)
+		}
+		rows = rows[1:]
+		first++
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no data rows")
+	}
+
+	timeIdx, err := timeColumnIndex(header, opts.TimeColumn)
+	if err != nil {
+		return nil, err
+	}
+	ts := &TimeSeries{Columns: make([]SeriesColumn, 0, width)}
+	for i, name := range header {
+		if i != timeIdx {
+			ts.Columns = append(ts.Columns, SeriesColumn{Name: name, Number: i + 1, Values: make([]float64, 0, len(rows))})
+		}
+	}
+	if timeIdx >= 0 {
+		ts.Time = make([]float64, 0, len(rows))
+	}
+
+	for r, row := range rows {
+		if len(row) < width {
+			return nil, fmt.Errorf("row %d has %d columns, expected %d", first+r, len(row), width)
+		}
+		for _, extra := range row[width:] {
+			if strings.TrimSpace(extra) != "" {
+				return nil, fmt.Errorf("row %d has %d columns, expected %d", first+r, len(row), width)
+			}
+		}
+		k := 0
+		for i, cell := range row[:width] {
+			v, err := parseCell(cell, delimiter)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %d: %w", first+r, i+1, err)
+			}
+			if i == timeIdx {
+				ts.Time = append(ts.Time, v*unit)
+				continue
+			}
+			ts.Columns[k].Values = append(ts.Columns[k].Values, v)
+			k++
+		}
+	}
+	return ts, nil
+}
+
+// detectDelimiter 按首个非空行识别分隔符: 制表符优先, 其次分号与逗号中较多者, 都没有时按空白分隔
+func detectDelimiter(text string) string {
+	line := ""
+	for l := range strings.Lines(text) {
+		if strings.TrimSpace(l) != "" {
+			line = l
+			break
+		}
+	}
+	switch {
+	case strings.Contains(line, "\t"):
+		return "\t"
+	case strings.Count(line, ";") > strings.Count(line, ","):
+		return ";"
+	case strings.Contains(line, ","):
+		return ","
+	}
+	return "space"
+}
+
+// splitRows 按分隔符拆分各行, 跳过空行
+func splitRows(text, delimiter string) ([][]string, error) {
+	if delimiter == "space" {
+		rows := make([][]string, 0)
+		for line := range strings.Lines(text) {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				rows = append(rows, fields)
+			}
+		}
+		return rows, nil
+	}
+	comma, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || comma == '"' || comma == '\r' || comma == '\n' || comma == utf8.RuneError {
+		return nil, fmt.Errorf("invalid delimiter %q", delimiter)
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	rows := make([][]string, 0)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// timeColumnIndex 返回时间列下标, 未指定且没有可识别的列名时返回 -1
+func timeColumnIndex(header []string, ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		for i, h := range header {
+			name, _ := splitHeaderUnit(h)
+			switch strings.ToLower(name) {
+			case "time", "t", "时间", "domain":
+				return i, nil
+			}
+		}
+		return -1, nil
+	}
+	for i, h := range header {
+		if strings.EqualFold(h, ref) {
+			return i, nil
+		}
+	}
+	if num, err := strconv.Atoi(ref); err == nil && num >= 1 && num <= len(header) {
+		return num - 1, nil
+	}
+	return -1, fmt.Errorf("time column %q not found", ref)
+}
+
+// parseCell 解析数值单元格, 分号分隔时允许逗号作小数点
+func parseCell(cell, delimiter string) (float64, error) {
+	s := strings.TrimSpace(cell)
+	if delimiter == ";" {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid number %q", cell)
+	}
+	return v, nil
+}
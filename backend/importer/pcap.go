@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// 链路层类型, 仅支持以太网
+const linkTypeEthernet = 1
+
+// pcap 与 pcapng 的文件标识
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+	pcapngSHB      = 0x0a0d0d0a
+	pcapngBOM      = 0x1a2b3c4d
+)
+
+// pcapng 块类型
+const (
+	pcapngIDB = 0x00000001
+	pcapngPB  = 0x00000002
+	pcapngEPB = 0x00000006
+)
+
+// captureFrame 抓包文件中的一帧
+type captureFrame struct {
+	Time     time.Time
+	LinkType uint32
+	Data     []byte
+}
+
+// readCapture 依次读取 pcap 或 pcapng 文件中的帧, fn 返回错误时停止
+func readCapture(data []byte, fn func(captureFrame) error) error {
+	if len(data) < 4 {
+		return fmt.Errorf("capture file too short")
+	}
+	if binary.LittleEndian.Uint32(data) == pcapngSHB {
+		return readPcapng(data, fn)
+	}
+	return readPcap(data, fn)
+}
+
+// readPcap 读取经典 pcap 格式(微秒或纳秒时间戳, 大小端均可)
+func readPcap(data []byte, fn func(captureFrame) error) error {
+	if len(data) < 24 {
+		return fmt.Errorf("pcap header too short")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	magic := order.Uint32(data)
+	if magic != pcapMagicMicro && magic != pcapMagicNano {
+		order = binary.BigEndian
+		magic = order.Uint32(data)
+	}
+	var unit uint64
+	switch magic {
+	case pcapMagicMicro:
+		unit = 1e6
+	case pcapMagicNano:
+		unit = 1e9
+	default:
+		return fmt.Errorf("not a pcap or pcapng file")
+	}
+	link := order.Uint32(data[20:]) & 0x0fffffff
+
+	for off := 24; off < len(data); {
+		if off+16 > len(data) {
+			return fmt.Errorf("truncated pcap record header at offset %d", off)
+		}
+		sec := uint64(order.Uint32(data[off:]))
+		frac := uint64(order.Uint32(data[off+4:]))
+		capLen := int(order.Uint32(data[off+8:]))
+		off += 16
+		if capLen < 0 || off+capLen > len(data) {
+			return fmt.Errorf("truncated pcap record at offset %d", off)
+		}
+		frame := captureFrame{Time: captureTime(sec*unit+frac, unit), LinkType: link, Data: data[off : off+capLen]}
+		if err := fn(frame); err != nil {
+			return err
+		}
+		off += capLen
+	}
+	return nil
+}
+
+// pcapngInterface pcapng 接口描述: 链路类型与时间戳单位(每秒计数)
+type pcapngInterface struct {
+	linkType uint32
+	unit     uint64
+}
+
+// readPcapng 读取 pcapng 格式, 支持多个段与接口, 按接口的 if_tsresol 换算时间戳
+func readPcapng(data []byte, fn func(captureFrame) error) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	var ifaces []pcapngInterface
+	for off := 0; off < len(data); {
+		if off+12 > len(data) {
+			return fmt.Errorf("truncated pcapng block at offset %d", off)
+		}
+		blockType := order.Uint32(data[off:])
+		if blockType == pcapngSHB {
+			// 段头块决定本段的字节序
+			switch {
+			case binary.LittleEndian.Uint32(data[off+8:]) == pcapngBOM:
+				order = binary.LittleEndian
+			case binary.BigEndian.Uint32(data[off+8:]) == pcapngBOM:
+				order = binary.BigEndian
+			default:
+				return fmt.Errorf("invalid pcapng byte-order magic at offset %d", off)
+			}
+			ifaces = ifaces[:0]
+		}
+		length := int(order.Uint32(data[off+4:]))
+		if length < 12 || length%4 != 0 || off+length > len(data) {
+			return fmt.Errorf("invalid pcapng block length %d at offset %d", length, off)
+		}
+		body := data[off+8 : off+length-4]
+		off += length
+
+		switch blockType {
+		case pcapngIDB:
+			if len(body) < 8 {
+				return fmt.Errorf("interface description block too short")
+			}
+			ifaces = append(ifaces, pcapngInterface{
+				linkType: uint32(order.Uint16(body)),
+				unit:     pcapngTimeUnit(body[8:], order),
+			})
+		case pcapngEPB, pcapngPB:
+			if len(body) < 20 {
+				return fmt.Errorf("packet block too short")
+			}
+			id := int(order.Uint32(body))
+			if blockType == pcapngPB {
+				id = int(order.Uint16(body))
+			}
+			if id >= len(ifaces) {
+				return fmt.Errorf("packet references unknown interface %d", id)
+			}
+			ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			capLen := int(order.Uint32(body[12:]))
+			if capLen < 0 || 20+capLen > len(body) {
+				return fmt.Errorf("truncated packet block")
+			}
+			frame := captureFrame{Time: captureTime(ts, ifaces[id].unit), LinkType: ifaces[id].linkType, Data: body[20 : 20+capLen]}
+			if err := fn(frame); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pcapngTimeUnit 从接口选项中读取 if_tsresol, 缺省为微秒
+func pcapngTimeUnit(opts []byte, order binary.ByteOrder) uint64 {
+	for len(opts) >= 4 {
+		code := order.Uint16(opts)
+		n := int(order.Uint16(opts[2:]))
+		if code == 0 || 4+n > len(opts) {
+			break
+		}
+		if code == 9 && n >= 1 {
+			res := opts[4]
+			if res&0x80 != 0 {
+				return 1 << (res & 0x7f)
+			}
+			unit := uint64(1)
+			for range res {
+				unit *= 10
+			}
+			return unit
+		}
+		opts = opts[4+(n+3)/4*4:]
+	}
+	return 1e6
+}
+
+// captureTime 将以 1/unit 秒计的时间戳换算为时间
+func captureTime(ts, unit uint64) time.Time {
+	if unit == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	sec, rem := ts/unit, ts%unit
+	hi, lo := bits.Mul64(rem, 1e9)
+	ns, _ := bits.Div64(hi, lo, unit)
+	return time.Unix(int64(sec), int64(ns)).UTC()
+}
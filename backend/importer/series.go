@@ -0,0 +1,300 @@
+// Package importer 将其他来源的采样数据(CSV、通用时间序列、SV 抓包等)构造成 COMTRADE 记录, 并读取打包的 COMTRADE 文件
+package importer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"comtradeviewer/comtrade"
+)
+
+// TimeSeries 按列存放的通用时间序列
+//   - Time: 各采样点的时间(秒), 为空时按 SeriesOptions.SampleRate 等间隔
+//   - Columns: 数据列, 长度均与采样点数相同
+type TimeSeries struct {
+	Time    []float64      `json:"time"`
+	Columns []SeriesColumn `json:"columns"`
+}
+
+// SeriesColumn 数据列; Number 为列在来源中的序号(从 1 开始), 用于按列号引用
+type SeriesColumn struct {
+	Name   string    `json:"name"`
+	Number int       `json:"number"`
+	Values []float64 `json:"values"`
+}
+
+// SeriesChannel 数据列到通道的映射
+//   - Column: 列名(不区分大小写)或从 1 开始的列号
+//   - Type: analog|digital, 默认 analog
+//   - Scale/Offset: 模拟量工程值 = 列值*Scale + Offset, Scale 为 0 时视为 1
+//   - Threshold: 开关量列值大于该值时为 1, 默认 0.5
+type SeriesChannel struct {
+	Column    string   `json:"column"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Unit      string   `json:"unit"`
+	Phase     string   `json:"phase"`
+	CCBM      string   `json:"ccbm"`
+	Scale     float64  `json:"scale"`
+	Offset    float64  `json:"offset"`
+	Primary   float64  `json:"primary"`
+	Secondary float64  `json:"secondary"`
+	PS        string   `json:"ps"`
+	Threshold *float64 `json:"threshold"`
+}
+
+// SeriesOptions 生成记录的参数
+//   - SampleRate: 采样率(Hz), 大于 0 时忽略时间列按等间隔生成时间戳
+//   - TriggerOffset: 触发时刻相对记录开始的毫秒数
+//   - Frequency: 额定频率, 默认 50Hz; Station、Relay 为空时为 "IMPORT"
+//   - Channels: 为空时全部数据列按模拟量导入; 通道名称与单位缺省时取自列名, 列名形如 "Ia (kA)" 时拆出单位
+type SeriesOptions struct {
+	Station       string          `json:"station"`
+	Relay         string          `json:"relay"`
+	Frequency     float64         `json:"frequency"`
+	SampleRate    float64         `json:"sampleRate"`
+	StartTime     time.Time       `json:"startTime"`
+	TriggerOffset float64         `json:"triggerOffset"`
+	Channels      []SeriesChannel `json:"channels"`
+}
+
+// 通道类型
+const (
+	ChannelAnalog  = "analog"
+	ChannelDigital = "digital"
+)
+
+// defaultDevice 未指定站名或录波器编号时使用的名称, CFG 中两者均为必填字段
+const defaultDevice = "IMPORT"
+
+// uniformTolerance 时间列相对等间隔的最大偏差(占采样间隔的比例), 超出时按变采样率记录处理
+const uniformTolerance = 0.01
+
+// Build 由时间序列生成 2013 版 float32 格式的记录, 列值作为码值以单精度浮点保存
+// 有时间列且间隔均匀时记录单一采样率, 否则采样率段为 0, 时间轴由时间戳给出
+func Build(ts *TimeSeries, opts SeriesOptions) (*comtrade.Metadata, *comtrade.ChannelData, error) {
+	if len(ts.Columns) == 0 {
+		return nil, nil, fmt.Errorf("no data columns")
+	}
+	n := len(ts.Columns[0].Values)
+	if n == 0 {
+		return nil, nil, fmt.Errorf("no samples")
+	}
+	for _, col := range ts.Columns {
+		if len(col.Values) != n {
+			return nil, nil, fmt.Errorf("column %q has %d samples, expected %d", col.Name, len(col.Values), n)
+		}
+	}
+
+	meta := &comtrade.Metadata{
+		Station:         opts.Station,
+		Relay:           opts.Relay,
+		Version:         "2013",
+		DataFileType:    "float32",
+		Frequency:       opts.Frequency,
+		AnalogChannels:  make([]comtrade.AnalogChannel, 0),
+		DigitalChannels: make([]comtrade.DigitalChannel, 0),
+		SampleRates:     make([]comtrade.SampleRate, 0),
+		StartTime:       opts.StartTime,
+		EndTime:         opts.StartTime.Add(time.Duration(opts.TriggerOffset * float64(time.Millisecond))),
+		TimeMultiplier:  1,
+	}
+	if meta.Frequency <= 0 {
+		meta.Frequency = 50
+	}
+	if meta.Station == "" {
+		meta.Station = defaultDevice
+	}
+	if meta.Relay == "" {
+		meta.Relay = defaultDevice
+	}
+	dat := &comtrade.ChannelData{
+		AnalogChannels:  make([]comtrade.AnalogChannelData, 0),
+		DigitalChannels: make([]comtrade.DigitalChannelData, 0),
+	}
+
+	seconds, err := sampleTimes(ts, opts.SampleRate, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rate, ok := uniformRate(seconds); ok {
+		meta.RatesNum = 1
+		meta.SampleRates = append(meta.SampleRates, comtrade.SampleRate{SampRate: rate, LastSampleNum: n})
+	}
+	span := (seconds[n-1] - seconds[0]) * 1e6
+	if span > math.MaxInt32 {
+		meta.TimeMultiplier = math.Ceil(span / math.MaxInt32)
+	}
+	dat.Timestamps = make([]int32, n)
+	for i, t := range seconds {
+		dat.Timestamps[i] = int32(math.Round((t - seconds[0]) * 1e6 / meta.TimeMultiplier))
+	}
+
+	channels := opts.Channels
+	columns := make([]*SeriesColumn, len(channels))
+	for i, ch := range channels {
+		if columns[i], err = findColumn(ts.Columns, ch.Column); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(channels) == 0 {
+		channels = make([]SeriesChannel, len(ts.Columns))
+		for i := range ts.Columns {
+			columns = append(columns, &ts.Columns[i])
+		}
+	}
+	for i, ch := range channels {
+		col := columns[i]
+		// 未指定名称或单位时取自列名
+		name, unit := splitHeaderUnit(col.Name)
+		if ch.Name != "" {
+			name = ch.Name
+		}
+		if ch.Unit == "" {
+			ch.Unit = unit
+		}
+		switch strings.ToLower(ch.Type) {
+		case "", ChannelAnalog:
+			addAnalog(meta, dat, ch, name, col.Values)
+		case ChannelDigital:
+			addDigital(meta, dat, ch, name, col.Values)
+		default:
+			return nil, nil, fmt.Errorf("channel %q: invalid type %q, expected analog|digital", name, ch.Type)
+		}
+	}
+	meta.AnalogChannelNum = len(meta.AnalogChannels)
+	meta.DigitalChannelNum = len(meta.DigitalChannels)
+	meta.TotalChannelNum = meta.AnalogChannelNum + meta.DigitalChannelNum
+	return meta, dat, nil
+}
+
+// sampleTimes 返回各采样点的时间(秒), 校验时间列严格递增
+func sampleTimes(ts *TimeSeries, rate float64, n int) ([]float64, error) {
+	if rate > 0 {
+		seconds := make([]float64, n)
+		for i := range seconds {
+			seconds[i] = float64(i) / rate
+		}
+		return seconds, nil
+	}
+	if len(ts.Time) == 0 {
+		return nil, fmt.Errorf("no time column, a sample rate is required")
+	}
+	if len(ts.Time) != n {
+		return nil, fmt.Errorf("time column has %d samples, expected %d", len(ts.Time), n)
+	}
+	for i := 1; i < n; i++ {
+		if !(ts.Time[i] > ts.Time[i-1]) {
+			return nil, fmt.Errorf("time is not strictly increasing at sample %d", i+1)
+		}
+	}
+	return ts.Time, nil
+}
+
+// uniformRate 判断采样时间是否等间隔, 是则返回采样率(保留三位小数)
+func uniformRate(seconds []float64) (float64, bool) {
+	n := len(seconds)
+	if n < 2 {
+		return 0, false
+	}
+	step := (seconds[n-1] - seconds[0]) / float64(n-1)
+	for i, t := range seconds {
+		if math.Abs(t-seconds[0]-float64(i)*step) > uniformTolerance*step {
+			return 0, false
+		}
+	}
+	return math.Round(1000/step) / 1000, true
+}
+
+// findColumn 按列名(不区分大小写)或列号查找数据列
+func findColumn(cols []SeriesColumn, ref string) (*SeriesColumn, error) {
+	ref = strings.TrimSpace(ref)
+	for i := range cols {
+		if strings.EqualFold(strings.TrimSpace(cols[i].Name), ref) {
+			return &cols[i], nil
+		}
+	}
+	if num, err := strconv.Atoi(ref); err == nil {
+		for i := range cols {
+			if cols[i].Number == num {
+				return &cols[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("column %q not found", ref)
+}
+
+// splitHeaderUnit 拆分 "Ia (kA)" 或 "Ia[kA]" 形式的列名与单位
+func splitHeaderUnit(header string) (string, string) {
+	header = strings.TrimSpace(header)
+	for _, pair := range [][2]string{{"(", ")"}, {"[", "]"}, {"（", "）"}} {
+		if !strings.HasSuffix(header, pair[1]) {
+			continue
+		}
+		if i := strings.LastIndex(header, pair[0]); i > 0 {
+			unit := strings.TrimSpace(header[i+len(pair[0]) : len(header)-len(pair[1])])
+			return strings.TrimSpace(header[:i]), unit
+		}
+	}
+	return header, ""
+}
+
+func addAnalog(meta *comtrade.Metadata, dat *comtrade.ChannelData, ch SeriesChannel, name string, values []float64) {
+	number := len(meta.AnalogChannels) + 1
+	codes := make([]float32, len(values))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		codes[i] = float32(v)
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	scale := ch.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	primary, secondary, ps := ch.Primary, ch.Secondary, strings.ToUpper(ch.PS)
+	if primary <= 0 || secondary <= 0 {
+		primary, secondary = 1, 1
+	}
+	if ps != "S" {
+		ps = "P"
+	}
+	meta.AnalogChannels = append(meta.AnalogChannels, comtrade.AnalogChannel{
+		ChannelNumber: number,
+		ChannelName:   name,
+		Phase:         ch.Phase,
+		CCBM:          ch.CCBM,
+		Unit:          ch.Unit,
+		Multiplier:    scale,
+		Offset:        ch.Offset,
+		MinValue:      math.Floor(lo),
+		MaxValue:      math.Ceil(hi),
+		Primary:       primary,
+		Secondary:     secondary,
+		PS:            ps,
+	})
+	dat.AnalogChannels = append(dat.AnalogChannels, comtrade.AnalogChannelData{ChannelNumber: number, RawDataFloat: codes})
+}
+
+func addDigital(meta *comtrade.Metadata, dat *comtrade.ChannelData, ch SeriesChannel, name string, values []float64) {
+	number := len(meta.DigitalChannels) + 1
+	threshold := 0.5
+	if ch.Threshold != nil {
+		threshold = *ch.Threshold
+	}
+	states := make([]int8, len(values))
+	for i, v := range values {
+		if v > threshold {
+			states[i] = 1
+		}
+	}
+	meta.DigitalChannels = append(meta.DigitalChannels, comtrade.DigitalChannel{
+		ChannelNumber: number,
+		ChannelName:   name,
+		Phase:         ch.Phase,
+		CCBM:          ch.CCBM,
+	})
+	dat.DigitalChannels = append(dat.DigitalChannels, comtrade.DigitalChannelData{ChannelNumber: number, RawData: states})
+}
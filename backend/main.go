@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 
+	"comtradeviewer/comtrade"
 	"comtradeviewer/config"
 	"comtradeviewer/storage"
 
@@ -35,6 +38,13 @@ func main() {
 
 	log.Printf("Storage initialized: type=%s", cfg.Storage.Type)
 
+	// 带子命令时执行命令行任务(如批量导出), 不启动 HTTP 服务
+	if len(os.Args) > 1 {
+		code := runCommand(stor, os.Args[1:])
+		stor.Close()
+		os.Exit(code)
+	}
+
 	// 登录接口无需鉴权，需在中间件前注册
 	jwtSecret := registerAuthRoutes(r)
 
@@ -95,3 +105,21 @@ func toFriendlyParseError(err error) (string, string, gin.H) {
 	}
 	return code, msg, details
 }
+
+// parseErrorResponse 返回数据集加载失败时的状态码与错误信息: 重采样参数无效为 400, 其余为 500
+func parseErrorResponse(err error) (int, string, string, gin.H) {
+	if errors.Is(err, comtrade.ErrInvalidResample) {
+		return http.StatusBadRequest, "INVALID_RESAMPLE", "重采样参数无效", gin.H{
+			"detail": err.Error(),
+			"hint":   fmt.Sprintf("rate须为正数(Hz), 重采样后每通道不超过%d个采样点且全部通道合计不超过%dMB, resample须为linear或sinc", comtrade.MaxResampleSamples, comtrade.MaxResampleBytes>>20),
+		}
+	}
+	code, msg, details := toFriendlyParseError(err)
+	return http.StatusInternalServerError, code, msg, details
+}
+
+// writeParseError 写入数据集加载失败的错误响应
+func writeParseError(c *gin.Context, err error) {
+	status, code, msg, details := parseErrorResponse(err)
+	writeError(c, status, code, msg, details)
+}
@@ -0,0 +1,69 @@
+// Package render 在服务端将波形绘制为 SVG 或 PNG 图像, 无需浏览器
+package render
+
+import "fmt"
+
+// Color RGBA 颜色, A 为不透明度(0~255)
+type Color struct {
+	R, G, B, A uint8
+}
+
+// hex 返回 #rrggbb 形式, 不含不透明度
+func (c Color) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Align 文字水平对齐方式
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Canvas 绘图后端; 坐标以像素为单位, 原点在左上角
+type Canvas interface {
+	// Line 绘制线段, dashed 为 true 时绘制虚线
+	Line(x1, y1, x2, y2 float64, c Color, width float64, dashed bool)
+	// Polyline 绘制折线
+	Polyline(x, y []float64, c Color, width float64)
+	// FillRect 填充矩形, 颜色可带透明度
+	FillRect(x, y, w, h float64, c Color)
+	// Text 绘制单行文字, y 为文字垂直中心
+	Text(x, y float64, s string, c Color, align Align)
+	// CanRender 判断文字能否完整显示(PNG 内置字体仅含 ASCII 字符)
+	CanRender(s string) bool
+	// Clip 限制后续线条的绘制范围, w 或 h <= 0 时取消限制
+	Clip(x, y, w, h float64)
+}
+
+var (
+	colorBackground = Color{255, 255, 255, 255}
+	colorFrame      = Color{96, 96, 96, 255}
+	colorGrid       = Color{225, 225, 225, 255}
+	colorText       = Color{40, 40, 40, 255}
+	colorTrigger    = Color{220, 0, 0, 255}
+	colorMarker     = Color{128, 0, 160, 255}
+	colorRange      = Color{128, 0, 160, 40}
+)
+
+// palette 非相别通道依次使用的颜色
+var palette = []Color{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+	{227, 119, 194, 255},
+	{23, 190, 207, 255},
+}
+
+// phaseColors 按国内惯例的相别颜色: A 黄、B 绿、C 红, N 黑
+var phaseColors = map[string]Color{
+	"A": {230, 170, 0, 255},
+	"B": {0, 150, 60, 255},
+	"C": {210, 30, 30, 255},
+	"N": {60, 60, 60, 255},
+}
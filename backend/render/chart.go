@@ -0,0 +1,371 @@
+package render
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Series 一条曲线, X 为时间(毫秒); Digital 为 true 时在开关量区按阶梯绘制
+//   - Label: 图例文字
+//   - Short: 画布无法显示 Label 时的替代文字(如 PNG 中的中文通道名以 "A1" 代替)
+type Series struct {
+	Label   string
+	Short   string
+	Unit    string
+	Phase   string
+	Digital bool
+	X       []float64
+	Y       []float64
+}
+
+// Marker 时间标记; End > Start 时为时间区间, 否则为时刻; Trigger 为 true 时按触发时刻样式绘制
+type Marker struct {
+	Start   float64
+	End     float64
+	Label   string
+	Trigger bool
+}
+
+// Chart 波形图: 模拟量按单位分面板(各自的纵轴), 开关量在底部面板中每通道一行, 共用时间轴
+type Chart struct {
+	Title      string
+	TitleShort string
+	Width      int
+	Height     int
+	XMin, XMax float64
+	Series     []Series
+	Markers    []Marker
+}
+
+const (
+	marginLeft   = 84
+	marginRight  = 16
+	marginTop    = 44
+	marginBottom = 36
+	panelGap     = 10
+	digitalLane  = 16
+	analogHeight = 180 // DefaultHeight 中每个模拟量面板的高度
+)
+
+// panel 一个绘图面板的纵向位置与包含的曲线
+type panel struct {
+	top, height float64
+	unit        string
+	series      []int
+	digital     bool
+	lo, hi      float64
+}
+
+// analogGroups 按单位分组模拟量曲线(保持首次出现的顺序), 并返回开关量曲线下标
+func analogGroups(series []Series) ([]panel, []int) {
+	groups := make([]panel, 0)
+	digital := make([]int, 0)
+	for i, s := range series {
+		if s.Digital {
+			digital = append(digital, i)
+			continue
+		}
+		found := false
+		for g := range groups {
+			if groups[g].unit == s.Unit {
+				groups[g].series = append(groups[g].series, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, panel{unit: s.Unit, series: []int{i}})
+		}
+	}
+	return groups, digital
+}
+
+// DefaultHeight 按模拟量面板数与开关量通道数估算合适的图像高度
+func DefaultHeight(series []Series) int {
+	groups, digital := analogGroups(series)
+	h := marginTop + marginBottom + len(groups)*analogHeight
+	if len(digital) > 0 {
+		h += len(digital)*digitalLane + 8
+	}
+	if n := len(groups) + min(1, len(digital)); n > 1 {
+		h += (n - 1) * panelGap
+	}
+	return h
+}
+
+// niceTicks 返回 [lo, hi] 内不超过 maxCount 个 1/2/5×10^k 间隔的刻度及显示所需的小数位数
+func niceTicks(lo, hi float64, maxCount int) ([]float64, int) {
+	maxCount = max(2, maxCount)
+	span := hi - lo
+	if !(span > 0) || math.IsInf(span, 0) {
+		return []float64{lo}, 0
+	}
+	raw := span / float64(maxCount)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+	decimals := max(0, int(-math.Floor(math.Log10(step)+1e-9)))
+	ticks := make([]float64, 0, maxCount+1)
+	for v := math.Ceil(lo/step-1e-9) * step; v <= hi+step*1e-9; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks, decimals
+}
+
+func formatTick(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Trim(s, "-0.") == "" {
+		return strings.TrimPrefix(s, "-")
+	}
+	return s
+}
+
+// displayText 返回画布可显示的文字: 优先 label, 其次 short, 否则去掉无法显示的字符
+func displayText(c Canvas, label, short string) string {
+	if c.CanRender(label) {
+		return label
+	}
+	if short != "" {
+		return short
+	}
+	var b strings.Builder
+	for _, r := range label {
+		if c.CanRender(string(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Trim(b.String(), " :,;-")
+}
+
+// estimateWidth 估算文字像素宽度(ASCII 约 6 像素, 其余字符约 11 像素)
+func estimateWidth(s string) float64 {
+	w := 0.0
+	for _, r := range s {
+		if r < 0x80 {
+			w += 6
+		} else {
+			w += 11
+		}
+	}
+	return w
+}
+
+// truncate 截断过长的文字
+func truncate(s string, maxWidth float64) string {
+	if estimateWidth(s) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 1 && estimateWidth(string(runes))+6 > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "~"
+}
+
+// seriesColor 返回曲线颜色: 相别为 A/B/C/N 时按相别着色, 否则依次取调色板
+func seriesColor(s Series, index int) Color {
+	if c, ok := phaseColors[strings.ToUpper(strings.TrimSpace(s.Phase))]; ok {
+		return c
+	}
+	return palette[index%len(palette)]
+}
+
+// Draw 在画布上绘制完整的波形图
+func (ch *Chart) Draw(c Canvas) {
+	groups, digital := analogGroups(ch.Series)
+	x0, x1 := float64(marginLeft), float64(ch.Width-marginRight)
+	top, bottom := float64(marginTop), float64(ch.Height-marginBottom)
+	xmin, xmax := ch.XMin, ch.XMax
+	if !(xmax > xmin) {
+		xmax = xmin + 1
+	}
+	xpx := func(x float64) float64 { return x0 + (x-xmin)/(xmax-xmin)*(x1-x0) }
+
+	// 1. 面板布局: 开关量面板按通道数定高, 其余高度由模拟量面板均分
+	panels := groups
+	digitalHeight := 0.0
+	if len(digital) > 0 {
+		digitalHeight = float64(len(digital)*digitalLane + 8)
+	}
+	gaps := float64(len(panels)+min(1, len(digital))-1) * panelGap
+	analog := 0.0
+	if len(panels) > 0 {
+		analog = math.Max(40, (bottom-top-digitalHeight-gaps)/float64(len(panels)))
+	}
+	y := top
+	for i := range panels {
+		p := &panels[i]
+		p.top, p.height = y, analog
+		y += analog + panelGap
+		p.lo, p.hi = math.Inf(1), math.Inf(-1)
+		for _, si := range p.series {
+			for _, v := range ch.Series[si].Y {
+				if !math.IsNaN(v) && !math.IsInf(v, 0) {
+					p.lo, p.hi = math.Min(p.lo, v), math.Max(p.hi, v)
+				}
+			}
+		}
+		if math.IsInf(p.lo, 0) {
+			p.lo, p.hi = -1, 1
+		}
+		if p.hi-p.lo < 1e-12*math.Max(1, math.Abs(p.hi)) {
+			p.lo, p.hi = p.lo-1, p.hi+1
+		}
+		pad := (p.hi - p.lo) * 0.05
+		p.lo, p.hi = p.lo-pad, p.hi+pad
+	}
+	if len(digital) > 0 {
+		panels = append(panels, panel{top: y, height: digitalHeight, series: digital, digital: true})
+	}
+	if len(panels) == 0 {
+		c.Text(float64(ch.Width)/2, float64(ch.Height)/2, "no data", colorText, AlignCenter)
+		return
+	}
+	last := panels[len(panels)-1]
+	plotBottom := last.top + last.height
+
+	// 2. 标题
+	c.Text(x0, 12, displayText(c, ch.Title, ch.TitleShort), colorText, AlignLeft)
+
+	// 3. 网格
+	xticks, xdec := niceTicks(xmin, xmax, int((x1-x0)/90))
+	for _, p := range panels {
+		for _, t := range xticks {
+			c.Line(xpx(t), p.top, xpx(t), p.top+p.height, colorGrid, 1, false)
+		}
+		if p.digital {
+			continue
+		}
+		yticks, _ := niceTicks(p.lo, p.hi, int(p.height/36))
+		for _, t := range yticks {
+			py := p.top + (p.hi-t)/(p.hi-p.lo)*p.height
+			c.Line(x0, py, x1, py, colorGrid, 1, false)
+		}
+	}
+
+	// 4. 标注与触发时刻(位于曲线下方)
+	for k, m := range ch.Markers {
+		if math.Max(m.Start, m.End) < xmin || m.Start > xmax {
+			continue
+		}
+		color := colorMarker
+		if m.Trigger {
+			color = colorTrigger
+		}
+		sx := xpx(math.Max(m.Start, xmin))
+		if m.End > m.Start {
+			ex := xpx(math.Min(m.End, xmax))
+			c.FillRect(sx, top, ex-sx, plotBottom-top, colorRange)
+			c.Line(ex, top, ex, plotBottom, color, 1, true)
+		}
+		c.Line(sx, top, sx, plotBottom, color, 1, true)
+		if label := displayText(c, m.Label, ""); label != "" {
+			// 相邻标注的文字上下交错, 减少重叠
+			ly := top - 8
+			if k%2 == 1 {
+				ly = top - 18
+			}
+			c.Text(sx+3, ly, truncate(label, 160), color, AlignLeft)
+		}
+	}
+
+	// 5. 曲线
+	for _, p := range panels {
+		c.Clip(x0, p.top, x1-x0, p.height)
+		for lane, si := range p.series {
+			s := ch.Series[si]
+			color := seriesColor(s, si)
+			px := make([]float64, len(s.X))
+			py := make([]float64, len(s.Y))
+			if !p.digital {
+				for i := range s.X {
+					px[i] = xpx(s.X[i])
+					py[i] = p.top + (p.hi-s.Y[i])/(p.hi-p.lo)*p.height
+				}
+				c.Polyline(px, py, color, 1.2)
+				continue
+			}
+			// 开关量阶梯: 状态 1 时填充并画在行的上沿
+			laneTop := p.top + 4 + float64(lane*digitalLane)
+			high, low := laneTop+3, laneTop+digitalLane-3
+			sx, sy := make([]float64, 0, 2*len(s.X)), make([]float64, 0, 2*len(s.X))
+			for i := range s.X {
+				level := low
+				if s.Y[i] != 0 {
+					level = high
+				}
+				x := xpx(s.X[i])
+				if i > 0 {
+					sx, sy = append(sx, x), append(sy, sy[len(sy)-1])
+				}
+				sx, sy = append(sx, x), append(sy, level)
+				if s.Y[i] != 0 {
+					next := x1
+					if i+1 < len(s.X) {
+						next = xpx(s.X[i+1])
+					}
+					c.FillRect(x, high, math.Min(next, x1)-x, low-high, Color{color.R, color.G, color.B, 60})
+				}
+			}
+			if n := len(sx); n > 0 {
+				sx, sy = append(sx, x1), append(sy, sy[n-1])
+			}
+			c.Polyline(sx, sy, color, 1.2)
+		}
+		c.Clip(0, 0, 0, 0)
+	}
+
+	// 6. 边框、刻度、图例与开关量行名
+	for _, p := range panels {
+		c.Line(x0, p.top, x1, p.top, colorFrame, 1, false)
+		c.Line(x0, p.top+p.height, x1, p.top+p.height, colorFrame, 1, false)
+		c.Line(x0, p.top, x0, p.top+p.height, colorFrame, 1, false)
+		c.Line(x1, p.top, x1, p.top+p.height, colorFrame, 1, false)
+
+		if p.digital {
+			for lane, si := range p.series {
+				s := ch.Series[si]
+				label := truncate(displayText(c, s.Label, s.Short), marginLeft-10)
+				c.Text(x0-5, p.top+4+float64(lane*digitalLane)+digitalLane/2, label, seriesColor(s, si), AlignRight)
+			}
+			continue
+		}
+
+		yticks, ydec := niceTicks(p.lo, p.hi, int(p.height/36))
+		for _, t := range yticks {
+			py := p.top + (p.hi-t)/(p.hi-p.lo)*p.height
+			c.Line(x0-4, py, x0, py, colorFrame, 1, false)
+			c.Text(x0-6, py, formatTick(t, ydec), colorText, AlignRight)
+		}
+		if p.unit != "" {
+			c.Text(6, p.top+6, displayText(c, p.unit, ""), colorText, AlignLeft)
+		}
+
+		// 图例: 面板左上角, 白色半透明底
+		lx, ly := x0+6, p.top+10
+		for _, si := range p.series {
+			s := ch.Series[si]
+			label := truncate(displayText(c, s.Label, s.Short), 180)
+			w := 18 + estimateWidth(label)
+			if lx+w > x1-6 && lx > x0+6 {
+				lx, ly = x0+6, ly+14
+			}
+			c.FillRect(lx-2, ly-7, w+4, 14, Color{255, 255, 255, 200})
+			c.Line(lx, ly, lx+12, ly, seriesColor(s, si), 2, false)
+			c.Text(lx+16, ly, label, colorText, AlignLeft)
+			lx += w + 10
+		}
+	}
+
+	for _, t := range xticks {
+		c.Line(xpx(t), plotBottom, xpx(t), plotBottom+4, colorFrame, 1, false)
+		c.Text(xpx(t), plotBottom+12, formatTick(t, xdec), colorText, AlignCenter)
+	}
+	c.Text(x1, plotBottom+27, "t (ms)", colorText, AlignRight)
+}
@@ -0,0 +1,198 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// RasterCanvas 基于 image.RGBA 的位图画布: 线条用 Wu 算法抗锯齿, 文字用内置 ASCII 点阵字体
+type RasterCanvas struct {
+	img  *image.RGBA
+	clip image.Rectangle
+}
+
+// NewRaster 创建指定像素尺寸的位图画布
+func NewRaster(width, height int) *RasterCanvas {
+	r := &RasterCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	r.clip = r.img.Bounds()
+	r.FillRect(0, 0, float64(width), float64(height), colorBackground)
+	return r
+}
+
+// blend 以 coverage(0~1)乘以颜色不透明度将颜色混合到像素上
+func (r *RasterCanvas) blend(x, y int, c Color, coverage float64) {
+	if !(image.Point{x, y}).In(r.clip) {
+		return
+	}
+	a := coverage * float64(c.A) / 255
+	if a <= 0 {
+		return
+	}
+	a = math.Min(a, 1)
+	i := r.img.PixOffset(x, y)
+	p := r.img.Pix[i : i+4 : i+4]
+	p[0] = uint8(float64(p[0])*(1-a) + float64(c.R)*a + 0.5)
+	p[1] = uint8(float64(p[1])*(1-a) + float64(c.G)*a + 0.5)
+	p[2] = uint8(float64(p[2])*(1-a) + float64(c.B)*a + 0.5)
+	p[3] = 255
+}
+
+// wuLine 绘制 1 像素宽的抗锯齿线段
+func (r *RasterCanvas) wuLine(x0, y0, x1, y1 float64, c Color) {
+	steep := math.Abs(y1-y0) > math.Abs(x1-x0)
+	if steep {
+		x0, y0, x1, y1 = y0, x0, y1, x1
+	}
+	if x0 > x1 {
+		x0, x1, y0, y1 = x1, x0, y1, y0
+	}
+	plot := func(x, y int, v float64) {
+		if steep {
+			r.blend(y, x, c, v)
+		} else {
+			r.blend(x, y, c, v)
+		}
+	}
+	dx := x1 - x0
+	gradient := 1.0
+	if dx > 0 {
+		gradient = (y1 - y0) / dx
+	}
+	xs, xe := int(math.Round(x0)), int(math.Round(x1))
+	// 限制在画布范围内, 避免极端坐标导致长时间循环
+	lo, hi := -1, max(r.img.Bounds().Dx(), r.img.Bounds().Dy())+1
+	if xs < lo {
+		xs = lo
+	}
+	if xe > hi {
+		xe = hi
+	}
+	for x := xs; x <= xe; x++ {
+		y := y0 + gradient*(float64(x)-x0)
+		fy := math.Floor(y)
+		frac := y - fy
+		plot(x, int(fy), 1-frac)
+		plot(x, int(fy)+1, frac)
+	}
+}
+
+// segment 绘制指定宽度的实线段, 宽线以多条平行的 1 像素线叠加
+func (r *RasterCanvas) segment(x1, y1, x2, y2 float64, c Color, width float64) {
+	if width <= 1.2 {
+		r.wuLine(x1, y1, x2, y2, c)
+		return
+	}
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	nx, ny := -(y2-y1)/length, (x2-x1)/length
+	n := int(math.Ceil(width))
+	for k := range n {
+		off := (float64(k) - float64(n-1)/2) * width / float64(n)
+		r.wuLine(x1+nx*off, y1+ny*off, x2+nx*off, y2+ny*off, c)
+	}
+}
+
+func (r *RasterCanvas) Line(x1, y1, x2, y2 float64, c Color, width float64, dashed bool) {
+	if !dashed {
+		r.segment(x1, y1, x2, y2, c, width)
+		return
+	}
+	length := math.Hypot(x2-x1, y2-y1)
+	const on, off = 4.0, 3.0
+	for d := 0.0; d < length; d += on + off {
+		e := math.Min(d+on, length)
+		r.segment(x1+(x2-x1)*d/length, y1+(y2-y1)*d/length, x1+(x2-x1)*e/length, y1+(y2-y1)*e/length, c, width)
+	}
+}
+
+func (r *RasterCanvas) Polyline(x, y []float64, c Color, width float64) {
+	if len(x) == 1 {
+		r.blend(int(x[0]), int(y[0]), c, 1)
+	}
+	for i := 1; i < len(x); i++ {
+		r.segment(x[i-1], y[i-1], x[i], y[i], c, width)
+	}
+}
+
+func (r *RasterCanvas) FillRect(x, y, w, h float64, c Color) {
+	rect := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h))).Intersect(r.img.Bounds())
+	if c.A == 255 {
+		for py := rect.Min.Y; py < rect.Max.Y; py++ {
+			for px := rect.Min.X; px < rect.Max.X; px++ {
+				r.img.SetRGBA(px, py, color.RGBA{c.R, c.G, c.B, 255})
+			}
+		}
+		return
+	}
+	saved := r.clip
+	r.clip = r.img.Bounds()
+	for py := rect.Min.Y; py < rect.Max.Y; py++ {
+		for px := rect.Min.X; px < rect.Max.X; px++ {
+			r.blend(px, py, c, 1)
+		}
+	}
+	r.clip = saved
+}
+
+// textWidth 返回文字的像素宽度
+func textWidth(s string) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return n*glyphWidth - 1
+}
+
+func (r *RasterCanvas) Text(x, y float64, s string, c Color, align Align) {
+	left := int(math.Round(x))
+	switch align {
+	case AlignCenter:
+		left -= textWidth(s) / 2
+	case AlignRight:
+		left -= textWidth(s)
+	}
+	top := int(math.Round(y - 3.5))
+	saved := r.clip
+	r.clip = r.img.Bounds()
+	for i, ch := range []rune(s) {
+		if ch < 0x20 || ch > 0x7e {
+			ch = '?'
+		}
+		glyph := font5x7[ch-0x20]
+		for col, bits := range glyph {
+			for row := range glyphHeight {
+				if bits&(1<<row) != 0 {
+					r.blend(left+i*glyphWidth+col, top+row, c, 1)
+				}
+			}
+		}
+	}
+	r.clip = saved
+}
+
+func (r *RasterCanvas) CanRender(s string) bool {
+	for _, ch := range s {
+		if ch < 0x20 || ch > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *RasterCanvas) Clip(x, y, w, h float64) {
+	if w <= 0 || h <= 0 {
+		r.clip = r.img.Bounds()
+		return
+	}
+	r.clip = image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x+w)), int(math.Ceil(y+h))).Intersect(r.img.Bounds())
+}
+
+// EncodePNG 以 PNG 格式写出图像
+func (r *RasterCanvas) EncodePNG(w io.Writer) error {
+	return png.Encode(w, r.img)
+}
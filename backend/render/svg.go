@@ -0,0 +1,107 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SVGCanvas 生成 SVG 文档, 文字由查看器渲染, 可显示中文通道名
+type SVGCanvas struct {
+	width, height int
+	body          bytes.Buffer
+	clips         int
+	clip          string
+}
+
+// NewSVG 创建指定像素尺寸的 SVG 画布
+func NewSVG(width, height int) *SVGCanvas {
+	s := &SVGCanvas{width: width, height: height}
+	s.FillRect(0, 0, float64(width), float64(height), colorBackground)
+	return s
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
+
+// stroke 返回描边属性, 半透明颜色附加 stroke-opacity
+func stroke(c Color, width float64) string {
+	s := fmt.Sprintf(`stroke="%s" stroke-width="%s"`, c.hex(), num(width))
+	if c.A < 255 {
+		s += fmt.Sprintf(` stroke-opacity="%.3f"`, float64(c.A)/255)
+	}
+	return s
+}
+
+func (s *SVGCanvas) Line(x1, y1, x2, y2 float64, c Color, width float64, dashed bool) {
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="4 3"`
+	}
+	fmt.Fprintf(&s.body, `<line x1="%s" y1="%s" x2="%s" y2="%s" %s%s%s/>`+"\n", num(x1), num(y1), num(x2), num(y2), stroke(c, width), dash, s.clip)
+}
+
+func (s *SVGCanvas) Polyline(x, y []float64, c Color, width float64) {
+	if len(x) == 0 {
+		return
+	}
+	s.body.WriteString(`<polyline fill="none" stroke-linejoin="round" points="`)
+	for i := range x {
+		if i > 0 {
+			s.body.WriteByte(' ')
+		}
+		s.body.WriteString(num(x[i]))
+		s.body.WriteByte(',')
+		s.body.WriteString(num(y[i]))
+	}
+	fmt.Fprintf(&s.body, `" %s%s/>`+"\n", stroke(c, width), s.clip)
+}
+
+func (s *SVGCanvas) FillRect(x, y, w, h float64, c Color) {
+	opacity := ""
+	if c.A < 255 {
+		opacity = fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/255)
+	}
+	fmt.Fprintf(&s.body, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"%s/>`+"\n", num(x), num(y), num(w), num(h), c.hex(), opacity)
+}
+
+func (s *SVGCanvas) Text(x, y float64, text string, c Color, align Align) {
+	anchor := "start"
+	switch align {
+	case AlignCenter:
+		anchor = "middle"
+	case AlignRight:
+		anchor = "end"
+	}
+	fmt.Fprintf(&s.body, `<text x="%s" y="%s" fill="%s" text-anchor="%s" dominant-baseline="central">`, num(x), num(y), c.hex(), anchor)
+	xml.EscapeText(&s.body, []byte(text))
+	s.body.WriteString("</text>\n")
+}
+
+func (s *SVGCanvas) CanRender(string) bool {
+	return true
+}
+
+func (s *SVGCanvas) Clip(x, y, w, h float64) {
+	if w <= 0 || h <= 0 {
+		s.clip = ""
+		return
+	}
+	s.clips++
+	fmt.Fprintf(&s.body, `<clipPath id="clip%d"><rect x="%s" y="%s" width="%s" height="%s"/></clipPath>`+"\n", s.clips, num(x), num(y), num(w), num(h))
+	s.clip = fmt.Sprintf(` clip-path="url(#clip%d)"`, s.clips)
+}
+
+// WriteTo 写出完整的 SVG 文档
+func (s *SVGCanvas) WriteTo(w io.Writer) (int64, error) {
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		s.width, s.height, s.width, s.height)
+	doc.Write(s.body.Bytes())
+	doc.WriteString("</svg>\n")
+	return doc.WriteTo(w)
+}
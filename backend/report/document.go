@@ -0,0 +1,296 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"comtradeviewer/render"
+)
+
+// 版面尺寸(点)
+const (
+	margin      = 42
+	bodySize    = 9
+	headingSize = 13
+	titleSize   = 18
+	lineGap     = 1.45 // 行高与字号之比
+	cellPad     = 3
+	chartFont   = 11 // render.Chart 按 11 像素字号排版
+)
+
+var (
+	colorText   = render.Color{R: 30, G: 30, B: 30, A: 255}
+	colorMuted  = render.Color{R: 110, G: 110, B: 110, A: 255}
+	colorRule   = render.Color{R: 170, G: 170, B: 170, A: 255}
+	colorHeader = render.Color{R: 232, G: 236, B: 242, A: 255}
+	colorAccent = render.Color{R: 30, G: 80, B: 150, A: 255}
+)
+
+// Document A4 纵向文档, 内容自上而下排布, 放不下时自动换页
+type Document struct {
+	pdf  *PDF
+	page *Page
+	y    float64
+}
+
+// NewDocument 创建文档并添加第一页
+func NewDocument(title string) *Document {
+	d := &Document{pdf: NewPDF(title)}
+	d.newPage()
+	return d
+}
+
+func (d *Document) newPage() {
+	d.page = d.pdf.AddPage(A4Width, A4Height)
+	d.y = margin
+}
+
+// ContentWidth 返回版心宽度
+func (d *Document) ContentWidth() float64 {
+	return A4Width - 2*margin
+}
+
+// fits 判断当前页剩余高度能否容纳 h
+func (d *Document) fits(h float64) bool {
+	return d.y+h <= A4Height-margin-12
+}
+
+// ensure 剩余高度不足 h 时换页
+func (d *Document) ensure(h float64) {
+	if !d.fits(h) && d.y > margin {
+		d.newPage()
+	}
+}
+
+// Space 增加纵向间距
+func (d *Document) Space(h float64) {
+	d.y += h
+}
+
+// Title 文档标题与副标题
+func (d *Document) Title(title, subtitle string) {
+	d.ensure(titleSize * 3)
+	d.page.Text(margin, d.y+titleSize, titleSize, title, colorText)
+	d.y += titleSize * lineGap
+	if subtitle != "" {
+		d.page.Text(margin, d.y+bodySize+2, bodySize+1, subtitle, colorMuted)
+		d.y += (bodySize + 1) * lineGap
+	}
+	d.y += 4
+	d.page.Line(margin, d.y, A4Width-margin, d.y, colorAccent, 1.2, false)
+	d.y += 10
+}
+
+// Heading 章节标题; 与其后至少 minBody 高度的内容保持在同一页
+func (d *Document) Heading(s string, minBody float64) {
+	d.ensure(headingSize*lineGap + 8 + minBody)
+	d.y += 4
+	d.page.Text(margin, d.y+headingSize, headingSize, s, colorAccent)
+	d.y += headingSize*lineGap + 4
+}
+
+// Paragraph 自动换行的正文段落
+func (d *Document) Paragraph(s string) {
+	d.paragraph(s, bodySize, colorText)
+}
+
+// Note 灰色小字说明
+func (d *Document) Note(s string) {
+	d.paragraph(s, bodySize-1, colorMuted)
+}
+
+func (d *Document) paragraph(s string, size float64, c render.Color) {
+	for _, para := range strings.Split(s, "\n") {
+		for _, line := range wrapText(para, d.ContentWidth(), size) {
+			d.ensure(size * lineGap)
+			d.page.Text(margin, d.y+size, size, line, c)
+			d.y += size * lineGap
+		}
+	}
+	d.y += 4
+}
+
+// wrapText 按宽度折行: 优先在空格处断开, 中文可在任意字符间断开
+func wrapText(s string, width, size float64) []string {
+	if s == "" {
+		return []string{""}
+	}
+	lines := make([]string, 0, 1)
+	runes := []rune(s)
+	for len(runes) > 0 {
+		n, w, lastSpace := 0, 0.0, -1
+		for n < len(runes) {
+			cw := TextWidth(string(runes[n]), size)
+			if w+cw > width && n > 0 {
+				break
+			}
+			if runes[n] == ' ' {
+				lastSpace = n
+			}
+			w += cw
+			n++
+		}
+		if n < len(runes) && lastSpace > 0 && runes[n] < 0x80 && runes[n-1] < 0x80 {
+			n = lastSpace + 1
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:n]), " "))
+		runes = runes[n:]
+	}
+	return lines
+}
+
+// KeyValues 两列的键值表
+func (d *Document) KeyValues(pairs [][2]string) {
+	rows := make([][]string, len(pairs))
+	for i, p := range pairs {
+		rows[i] = []string{p[0], p[1]}
+	}
+	d.table(nil, []float64{0.28, 0.72}, rows)
+}
+
+// Table 带表头的表格, widths 为各列占版心宽度的比例; 跨页时重复表头
+func (d *Document) Table(headers []string, widths []float64, rows [][]string) {
+	d.table(headers, widths, rows)
+}
+
+func (d *Document) table(headers []string, widths []float64, rows [][]string) {
+	total := 0.0
+	for _, w := range widths {
+		total += w
+	}
+	cols := make([]float64, len(widths))
+	for i, w := range widths {
+		cols[i] = w / total * d.ContentWidth()
+	}
+	lineH := bodySize * lineGap
+
+	layout := func(row []string) ([][]string, float64) {
+		cells := make([][]string, len(cols))
+		n := 1
+		for i := range cols {
+			text := ""
+			if i < len(row) {
+				text = row[i]
+			}
+			cells[i] = wrapText(text, cols[i]-2*cellPad, bodySize)
+			n = max(n, len(cells[i]))
+		}
+		return cells, float64(n)*lineH + 2*cellPad
+	}
+	draw := func(cells [][]string, h float64, header bool) {
+		if header {
+			d.page.FillRect(margin, d.y, d.ContentWidth(), h, colorHeader)
+		}
+		x := float64(margin)
+		for i, lines := range cells {
+			for k, line := range lines {
+				d.page.Text(x+cellPad, d.y+cellPad+float64(k)*lineH+bodySize, bodySize, line, colorText)
+			}
+			x += cols[i]
+		}
+		d.page.Line(margin, d.y+h, A4Width-margin, d.y+h, colorRule, 0.5, false)
+		d.y += h
+	}
+
+	var headerCells [][]string
+	var headerH float64
+	if headers != nil {
+		headerCells, headerH = layout(headers)
+	}
+	// begin 在当前位置开始表格(或跨页后的续表): 顶部横线与表头
+	begin := func() {
+		d.page.Line(margin, d.y, A4Width-margin, d.y, colorRule, 0.5, false)
+		if headers != nil {
+			draw(headerCells, headerH, true)
+		}
+	}
+
+	d.ensure(headerH + lineH + 2*cellPad)
+	begin()
+	for _, row := range rows {
+		cells, h := layout(row)
+		if !d.fits(h) {
+			d.newPage()
+			begin()
+		}
+		draw(cells, h, false)
+	}
+	d.y += 8
+}
+
+// Chart 将波形图按版心宽度缩放后绘制; chart 的 Width/Height 为排版用的像素尺寸
+func (d *Document) Chart(chart *render.Chart) {
+	scale := d.ContentWidth() / float64(chart.Width)
+	h := float64(chart.Height) * scale
+	d.ensure(h)
+	chart.Draw(&chartCanvas{page: d.page, ox: margin, oy: d.y, scale: scale})
+	d.y += h + 6
+}
+
+// chartCanvas 将 render.Chart 的像素坐标映射到页面上的一块区域
+type chartCanvas struct {
+	page   *Page
+	ox, oy float64
+	scale  float64
+}
+
+func (c *chartCanvas) x(v float64) float64 { return c.ox + v*c.scale }
+func (c *chartCanvas) y(v float64) float64 { return c.oy + v*c.scale }
+
+func (c *chartCanvas) Line(x1, y1, x2, y2 float64, col render.Color, width float64, dashed bool) {
+	c.page.Line(c.x(x1), c.y(y1), c.x(x2), c.y(y2), col, width*c.scale, dashed)
+}
+
+func (c *chartCanvas) Polyline(x, y []float64, col render.Color, width float64) {
+	px := make([]float64, len(x))
+	py := make([]float64, len(y))
+	for i := range x {
+		px[i], py[i] = c.x(x[i]), c.y(y[i])
+	}
+	c.page.Polyline(px, py, col, width*c.scale)
+}
+
+func (c *chartCanvas) FillRect(x, y, w, h float64, col render.Color) {
+	c.page.FillRect(c.x(x), c.y(y), w*c.scale, h*c.scale, col)
+}
+
+func (c *chartCanvas) Text(x, y float64, s string, col render.Color, align render.Align) {
+	size := chartFont * c.scale
+	left := c.x(x)
+	switch align {
+	case render.AlignCenter:
+		left -= TextWidth(s, size) / 2
+	case render.AlignRight:
+		left -= TextWidth(s, size)
+	}
+	c.page.Text(left, c.y(y)+size*0.35, size, s, col)
+}
+
+func (c *chartCanvas) CanRender(string) bool {
+	return true
+}
+
+func (c *chartCanvas) Clip(x, y, w, h float64) {
+	if w <= 0 || h <= 0 {
+		c.page.Clip(0, 0, 0, 0)
+		return
+	}
+	c.page.Clip(c.x(x), c.y(y), w*c.scale, h*c.scale)
+}
+
+// Footer 在每页底部写入左侧文字与页码
+func (d *Document) Footer(left string) {
+	pages := d.pdf.Pages()
+	for i, p := range pages {
+		p.Clip(0, 0, 0, 0)
+		p.Line(margin, A4Height-margin+2, A4Width-margin, A4Height-margin+2, colorRule, 0.5, false)
+		p.Text(margin, A4Height-margin+14, bodySize-1, left, colorMuted)
+		num := fmt.Sprintf("第 %d / %d 页", i+1, len(pages))
+		p.Text(A4Width-margin-TextWidth(num, bodySize-1), A4Height-margin+14, bodySize-1, num, colorMuted)
+	}
+}
+
+// PDF 返回底层 PDF 文档, 用于写出
+func (d *Document) PDF() *PDF {
+	return d.pdf
+}
@@ -0,0 +1,288 @@
+// Package report 生成 PDF 故障录波报告: 最小化的 PDF 写出器与按页自动分页的文档排版
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+
+	"comtradeviewer/render"
+)
+
+// PDF 最小化的 PDF 1.4 文档: 页面内容以 FlateDecode 压缩, 文字统一使用 Adobe-GB1 预置字体
+// STSong-Light(不嵌入字体文件, 由阅读器提供), 可直接显示中文
+type PDF struct {
+	Title  string
+	pages  []*Page
+	alphas map[uint8]bool
+}
+
+// Page 一页内容; 坐标以点(1/72 英寸)为单位, 原点在左上角, y 向下
+type Page struct {
+	Width, Height float64
+	doc           *PDF
+	content       bytes.Buffer
+	clipped       bool
+}
+
+// A4 纸张尺寸(点)
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// NewPDF 创建空文档
+func NewPDF(title string) *PDF {
+	return &PDF{Title: title, alphas: make(map[uint8]bool)}
+}
+
+// AddPage 追加一页
+func (d *PDF) AddPage(width, height float64) *Page {
+	p := &Page{Width: width, Height: height, doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages 返回已添加的页面
+func (d *PDF) Pages() []*Page {
+	return d.pages
+}
+
+// TextWidth 返回文字宽度: ASCII 字符为半角(0.5 em), 其余为全角(1 em)
+func TextWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		if r < 0x80 {
+			w += 0.5
+		} else {
+			w++
+		}
+	}
+	return w * size
+}
+
+func pdfNum(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// y 将左上角坐标系的 y 转换为 PDF 坐标
+func (p *Page) y(v float64) float64 {
+	return p.Height - v
+}
+
+// withAlpha 半透明颜色时在独立的图形状态中设置不透明度后执行 draw
+func (p *Page) withAlpha(c render.Color, draw func()) {
+	if c.A == 255 {
+		draw()
+		return
+	}
+	p.doc.alphas[c.A] = true
+	fmt.Fprintf(&p.content, "q /GS%d gs\n", c.A)
+	draw()
+	p.content.WriteString("Q\n")
+}
+
+func (p *Page) strokeStyle(c render.Color, width float64, dashed bool) {
+	fmt.Fprintf(&p.content, "%s %s %s RG %s w ", pdfNum(float64(c.R)/255), pdfNum(float64(c.G)/255), pdfNum(float64(c.B)/255), pdfNum(width))
+	if dashed {
+		fmt.Fprintf(&p.content, "[%s %s] 0 d\n", pdfNum(4*width), pdfNum(3*width))
+	} else {
+		p.content.WriteString("[] 0 d\n")
+	}
+}
+
+func (p *Page) fillStyle(c render.Color) {
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", pdfNum(float64(c.R)/255), pdfNum(float64(c.G)/255), pdfNum(float64(c.B)/255))
+}
+
+// Line 绘制线段
+func (p *Page) Line(x1, y1, x2, y2 float64, c render.Color, width float64, dashed bool) {
+	p.withAlpha(c, func() {
+		p.strokeStyle(c, width, dashed)
+		fmt.Fprintf(&p.content, "%s %s m %s %s l S\n", pdfNum(x1), pdfNum(p.y(y1)), pdfNum(x2), pdfNum(p.y(y2)))
+	})
+}
+
+// Polyline 绘制折线, NaN/Inf 点处断开并从下一个有效点开始新的子路径
+func (p *Page) Polyline(x, y []float64, c render.Color, width float64) {
+	if len(x) < 2 {
+		return
+	}
+	p.withAlpha(c, func() {
+		p.strokeStyle(c, width, false)
+		p.content.WriteString("1 j ")
+		pen := false
+		for i := range x {
+			if !finite(x[i]) || !finite(y[i]) {
+				pen = false
+				continue
+			}
+			op := "l"
+			if !pen {
+				op = "m"
+			}
+			pen = true
+			fmt.Fprintf(&p.content, "%s %s %s\n", pdfNum(x[i]), pdfNum(p.y(y[i])), op)
+		}
+		p.content.WriteString("S\n")
+	})
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// FillRect 填充矩形
+func (p *Page) FillRect(x, y, w, h float64, c render.Color) {
+	p.withAlpha(c, func() {
+		p.fillStyle(c)
+		fmt.Fprintf(&p.content, "%s %s %s %s re f\n", pdfNum(x), pdfNum(p.y(y+h)), pdfNum(w), pdfNum(h))
+	})
+}
+
+// StrokeRect 绘制矩形边框
+func (p *Page) StrokeRect(x, y, w, h float64, c render.Color, width float64) {
+	p.withAlpha(c, func() {
+		p.strokeStyle(c, width, false)
+		fmt.Fprintf(&p.content, "%s %s %s %s re S\n", pdfNum(x), pdfNum(p.y(y+h)), pdfNum(w), pdfNum(h))
+	})
+}
+
+// Text 以 baseline 为基线绘制单行文字
+func (p *Page) Text(x, baseline, size float64, s string, c render.Color) {
+	if s == "" {
+		return
+	}
+	p.withAlpha(c, func() {
+		p.fillStyle(c)
+		fmt.Fprintf(&p.content, "BT /F1 %s Tf %s %s Td <", pdfNum(size), pdfNum(x), pdfNum(p.y(baseline)))
+		for _, u := range utf16.Encode([]rune(s)) {
+			fmt.Fprintf(&p.content, "%04X", u)
+		}
+		p.content.WriteString("> Tj ET\n")
+	})
+}
+
+// Clip 限制后续绘制的范围, w 或 h <= 0 时取消限制
+func (p *Page) Clip(x, y, w, h float64) {
+	if p.clipped {
+		p.content.WriteString("Q\n")
+		p.clipped = false
+	}
+	if w <= 0 || h <= 0 {
+		return
+	}
+	fmt.Fprintf(&p.content, "q %s %s %s %s re W n\n", pdfNum(x), pdfNum(p.y(y+h)), pdfNum(w), pdfNum(h))
+	p.clipped = true
+}
+
+// pdfText 将文字编码为带 BOM 的 UTF-16BE 十六进制字符串, 用于文档信息
+func pdfText(s string) string {
+	var b bytes.Buffer
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// pdfWriter 记录各对象的偏移量以生成交叉引用表
+type pdfWriter struct {
+	w       *bufio.Writer
+	n       int64
+	offsets []int64
+}
+
+func (pw *pdfWriter) write(s string) {
+	k, _ := pw.w.WriteString(s)
+	pw.n += int64(k)
+}
+
+// object 写出第 num 个对象(对象号从 1 开始按顺序分配)
+func (pw *pdfWriter) object(num int, body string) {
+	pw.offsets[num-1] = pw.n
+	pw.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", num, body))
+}
+
+// WriteTo 写出完整文档
+func (d *PDF) WriteTo(w io.Writer) (int64, error) {
+	pages := d.pages
+	if len(pages) == 0 {
+		pages = []*Page{{Width: A4Width, Height: A4Height, doc: d}}
+	}
+	const (
+		catalogObj = iota + 1
+		pagesObj
+		fontObj
+		cidFontObj
+		descriptorObj
+		resourcesObj
+		infoObj
+		firstPageObj
+	)
+	pw := &pdfWriter{w: bufio.NewWriter(w), offsets: make([]int64, firstPageObj-1+2*len(pages))}
+	pw.write("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	kids := make([]byte, 0, 8*len(pages))
+	for i := range pages {
+		kids = fmt.Appendf(kids, "%d 0 R ", firstPageObj+2*i)
+	}
+	pw.object(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	pw.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", bytes.TrimSpace(kids), len(pages)))
+	pw.object(fontObj, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UTF16-H /DescendantFonts [%d 0 R] >>", cidFontObj))
+	// ASCII 映射到的比例与半角字形(CID 1~95、814~939)均按 500 单位宽, 与 TextWidth 一致
+	pw.object(cidFontObj, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 500 814 939 500] >>", descriptorObj))
+	pw.object(descriptorObj, "<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] "+
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	alphas := make([]int, 0, len(d.alphas))
+	for a := range d.alphas {
+		alphas = append(alphas, int(a))
+	}
+	slices.Sort(alphas)
+	var states bytes.Buffer
+	for _, a := range alphas {
+		fmt.Fprintf(&states, "/GS%d << /Type /ExtGState /ca %s /CA %s >> ", a, pdfNum(float64(a)/255), pdfNum(float64(a)/255))
+	}
+	pw.object(resourcesObj, fmt.Sprintf("<< /Font << /F1 %d 0 R >> /ExtGState << %s>> >>", fontObj, states.String()))
+	pw.object(infoObj, fmt.Sprintf("<< /Title %s /Producer (comtradeviewer) >>", pdfText(d.Title)))
+
+	for i, p := range pages {
+		content := p.content.Bytes()
+		if p.clipped {
+			content = append(slices.Clip(content), "Q\n"...)
+		}
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(content)
+		zw.Close()
+
+		pageObj := firstPageObj + 2*i
+		pw.object(pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+			pagesObj, pdfNum(p.Width), pdfNum(p.Height), resourcesObj, pageObj+1))
+		pw.offsets[pageObj] = pw.n
+		pw.write(fmt.Sprintf("%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", pageObj+1, z.Len()))
+		k, _ := pw.w.Write(z.Bytes())
+		pw.n += int64(k)
+		pw.write("\nendstream\nendobj\n")
+	}
+
+	xref := pw.n
+	pw.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1))
+	for _, off := range pw.offsets {
+		pw.write(fmt.Sprintf("%010d 00000 n \n", off))
+	}
+	pw.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, catalogObj, infoObj, xref))
+	return pw.n, pw.w.Flush()
+}
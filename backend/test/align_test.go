@@ -0,0 +1,68 @@
+package test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestCrossCorrelationLag(t *testing.T) {
+	// 故障样的非周期信号: 第 300 点后出现衰减正弦
+	signal := func(n, delay int) []float64 {
+		y := make([]float64, n)
+		for i := range y {
+			k := i - 300 - delay
+			if k >= 0 {
+				y[i] = math.Exp(-float64(k)/200) * math.Sin(2*math.Pi*float64(k)/40)
+			}
+		}
+		return y
+	}
+	ref := signal(1000, 0)
+	for _, delay := range []int{-17, 0, 23} {
+		lag, coeff := comtrade.CrossCorrelationLag(ref, signal(1000, delay), 50)
+		if lag != delay || coeff < 0.95 {
+			t.Fatalf("delay %d: got lag %d (r=%.3f)", delay, lag, coeff)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	times := []float64{0, 1, 2, 3}
+	y := []float64{0, 10, 20, 10}
+	got := comtrade.InterpolateLinear(times, y, []float64{-1, 0.5, 2.25, 5})
+	want := []float64{0, 5, 17.5, 10}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("linear: want %v, got %v", want, got)
+		}
+	}
+
+	hold := comtrade.InterpolateHold(times, []int8{0, 1, 1, 0}, []float64{0.9, 1, 2.9, 3.5})
+	if hold[0] != 0 || hold[1] != 1 || hold[2] != 1 || hold[3] != 0 {
+		t.Fatalf("hold: unexpected %v", hold)
+	}
+
+	grid := comtrade.UniformTimes(0, 1, 0.25)
+	if len(grid) != 5 || grid[4] != 1 {
+		t.Fatalf("uniform grid: unexpected %v", grid)
+	}
+}
+
+func TestAlignmentShift(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	a := &comtrade.Metadata{StartTime: t0, EndTime: t0.Add(100 * time.Millisecond)}
+	b := &comtrade.Metadata{StartTime: t0.Add(12500 * time.Microsecond), EndTime: t0.Add(90 * time.Millisecond)}
+
+	if s := comtrade.AlignmentShift(b, comtrade.AlignAbsolute, a); math.Abs(s-12.5) > 1e-9 {
+		t.Fatalf("absolute: expected 12.5 ms, got %v", s)
+	}
+	if s := comtrade.AlignmentShift(b, comtrade.AlignTrigger, a); math.Abs(s+77.5) > 1e-9 {
+		t.Fatalf("trigger: expected -77.5 ms, got %v", s)
+	}
+	if s := comtrade.AlignmentShift(b, comtrade.AlignOffset, a); s != 0 {
+		t.Fatalf("offset: expected 0, got %v", s)
+	}
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"comtradeviewer/importer"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// archiveEntry 构造压缩包用的文件名与内容
+type archiveEntry struct {
+	name string
+	data string
+}
+
+func archiveRecordFiles(rec *importer.ArchiveRecord) []string {
+	var exts []string
+	for ext := range rec.Files {
+		exts = append(exts, ext)
+	}
+	slices.Sort(exts)
+	return exts
+}
+
+func TestReadZipArchive(t *testing.T) {
+	gbkName, _ := simplifiedchinese.GBK.NewEncoder().String("录波/故障1.CFG")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []struct {
+		archiveEntry
+		nonUTF8 bool
+	}{
+		{archiveEntry{gbkName, "cfg1"}, true},
+		{archiveEntry{"录波/故障1.dat", "dat1"}, false},
+		{archiveEntry{"录波/故障1.Hdr", "hdr1"}, false},
+		{archiveEntry{"录波/", ""}, false},
+		{archiveEntry{"B/rec2.cfg", "cfg2"}, false},
+		{archiveEntry{"b/REC2.DAT", "dat2"}, false},
+		{archiveEntry{"b/rec2.dat", "dat2-dup"}, false},
+		{archiveEntry{"only.cfg", "cfg3"}, false},
+		{archiveEntry{"readme.txt", "x"}, false},
+		{archiveEntry{"__MACOSX/录波/._故障1.dat", "x"}, false},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, NonUTF8: e.nonUTF8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.data))
+	}
+	zw.Close()
+
+	archive, err := importer.ReadArchive(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Records) != 3 || !slices.Equal(archive.Ignored, []string{"readme.txt"}) {
+		t.Fatalf("records=%d ignored=%v", len(archive.Records), archive.Ignored)
+	}
+	// 按包内路径排序: B/rec2、only、录波/故障1
+	rec2, only, rec1 := archive.Records[0], archive.Records[1], archive.Records[2]
+	if rec1.Name != "故障1" || rec1.Dir != "录波" || !slices.Equal(archiveRecordFiles(rec1), []string{"cfg", "dat", "hdr"}) {
+		t.Errorf("GBK record: %+v", rec1)
+	}
+	if string(rec1.Files["cfg"].Data) != "cfg1" || rec1.Files["cfg"].Name != "录波/故障1.CFG" {
+		t.Errorf("cfg file: %+v", rec1.Files["cfg"])
+	}
+	// 目录与文件名均不区分大小写配对, 重复的 dat 单独列出
+	if rec2.Name != "rec2" || string(rec2.Files["dat"].Data) != "dat2" || !slices.Equal(rec2.Duplicates, []string{"b/rec2.dat"}) {
+		t.Errorf("case-insensitive record: %+v", rec2)
+	}
+	if only.Files["dat"] != nil || only.Files["cfg"] == nil {
+		t.Errorf("cfg-only record: %+v", only)
+	}
+}
+
+func TestReadTarGzArchive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range []archiveEntry{{"./dfr/A.cfg", "cfg"}, {"./dfr/a.dat", "dat"}, {"./dfr/a.inf", "inf"}} {
+		tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(e.data))
+	}
+	tw.WriteHeader(&tar.Header{Name: "dfr/link.cfg", Linkname: "A.cfg", Typeflag: tar.TypeSymlink})
+	tw.Close()
+	gz.Close()
+
+	archive, err := importer.ReadArchive(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Records) != 1 {
+		t.Fatalf("records: %+v", archive.Records)
+	}
+	rec := archive.Records[0]
+	if rec.Name != "A" || rec.Dir != "dfr" || !slices.Equal(archiveRecordFiles(rec), []string{"cfg", "dat", "inf"}) {
+		t.Errorf("record: %+v", rec)
+	}
+
+	if _, err := importer.ReadArchive([]byte("plain text")); err == nil {
+		t.Error("expected error for non-archive data")
+	}
+}
+
+func TestReadArchiveSizeLimit(t *testing.T) {
+	// rawZip 按给定的声明解压大小写入 stored 条目, 不实际生成大文件
+	rawZip := func(sizes ...uint64) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for i, size := range sizes {
+			w, err := zw.CreateRaw(&zip.FileHeader{Name: fmt.Sprintf("rec%d.dat", i), Method: zip.Store, CompressedSize64: 1, UncompressedSize64: size})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte("x"))
+		}
+		zw.Close()
+		return buf.Bytes()
+	}
+	for name, data := range map[string][]byte{
+		"zip entry": rawZip(importer.MaxArchiveEntryBytes + 1),
+		"zip total": rawZip(200<<20, 200<<20, 200<<20),
+	} {
+		if _, err := importer.ReadArchive(data); err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("%s: expected size limit error, got %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "big.dat", Mode: 0o644, Size: importer.MaxArchiveEntryBytes + 1, Typeflag: tar.TypeReg})
+	if _, err := importer.ReadArchive(buf.Bytes()); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("tar entry: expected size limit error, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestParseCOMName(t *testing.T) {
+	n, err := comtrade.ParseCOMName("archive/240315,142530123,+8h,Station_A,DFR01,Grid Co,Bay1,Line 2.cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, 3, 15, 14, 25, 30, 123e6, time.FixedZone("", 8*3600))
+	if !n.StartTime.Equal(want) || n.TimeCode != "+8h" || n.Station != "Station_A" || n.Device != "DFR01" || n.Company != "Grid Co" {
+		t.Fatalf("parsed %+v", n)
+	}
+	if !slices.Equal(n.UserFields, []string{"Bay1", "Line 2"}) {
+		t.Errorf("user fields %v", n.UserFields)
+	}
+
+	n, err = comtrade.ParseCOMName(`C:\dfr\991231,235959,-5h30,S,D,C.CFG`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, offset := n.StartTime.Zone(); n.StartTime.Year() != 1999 || offset != -(5*3600+1800) || len(n.UserFields) != 0 {
+		t.Errorf("two-digit year/offset: %v", n.StartTime)
+	}
+
+	for _, name := range []string{
+		"录波1.cfg",
+		"240315,142530,+8h,S,D.cfg",
+		"2403,142530,+8h,S,D,C.cfg",
+		"241315,142530,+8h,S,D,C.cfg",
+		"240315,1425,+8h,S,D,C.cfg",
+		"240315,142530,+8x,S,D,C.cfg",
+		"240315,142530,+8h,,D,C.cfg",
+	} {
+		if _, err := comtrade.ParseCOMName(name); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCOMNameMismatches(t *testing.T) {
+	n, err := comtrade.ParseCOMName("240315,142530123,+8h,Station_A,DFR01,Grid Co.cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &comtrade.Metadata{
+		Station:   "station a",
+		Relay:     "DFR01",
+		StartTime: time.Date(2024, 3, 15, 14, 25, 30, 123400000, time.UTC),
+		EndTime:   time.Date(2024, 3, 15, 14, 25, 30, 223400000, time.UTC),
+		TimeCode:  "+8h",
+	}
+	if w := n.Mismatches(meta); len(w) != 0 {
+		t.Fatalf("unexpected warnings: %v", w)
+	}
+
+	// 文件名时间为触发时刻
+	meta.StartTime = meta.StartTime.Add(-time.Second)
+	meta.EndTime = time.Date(2024, 3, 15, 14, 25, 30, 123900000, time.UTC)
+	if w := n.Mismatches(meta); len(w) != 0 {
+		t.Fatalf("trigger time should match: %v", w)
+	}
+
+	// CFG 时间代码不同: 同一钟面时间相差 8 小时; 没有时间代码时按钟面时间比较
+	meta.TimeCode = "0"
+	if w := n.Mismatches(meta); len(w) != 1 {
+		t.Errorf("time code: %v", w)
+	}
+	meta.TimeCode = ""
+	if w := n.Mismatches(meta); len(w) != 0 {
+		t.Errorf("no time code: %v", w)
+	}
+
+	meta.Station, meta.Relay = "Station B", "DFR02"
+	if w := n.Mismatches(meta); len(w) != 2 {
+		t.Errorf("station/relay: %v", w)
+	}
+}
+
+func TestCOMNameFileName(t *testing.T) {
+	meta := &comtrade.Metadata{
+		Station:   "Station A",
+		Relay:     "DFR/01",
+		StartTime: time.Date(2024, 3, 15, 14, 25, 30, 123000000, time.UTC),
+		TimeCode:  "-5h30",
+	}
+	n := comtrade.COMNameFromMetadata(meta, "Co,Ltd", "Bay1")
+	name := n.FileName()
+	if name != "240315,142530123,-5h30,Station A,DFR_01,Co_Ltd,Bay1" {
+		t.Fatalf("file name %q", name)
+	}
+	back, err := comtrade.ParseCOMName(name + ".cfg")
+	if err != nil || !back.StartTime.Equal(n.StartTime) || back.Device != "DFR_01" {
+		t.Fatalf("round trip: %v %+v", err, back)
+	}
+	if w := back.Mismatches(meta); len(w) != 0 {
+		t.Errorf("round trip mismatches: %v", w)
+	}
+
+	// 微秒位、没有时间代码与公司名
+	meta.StartTime = meta.StartTime.Add(456 * time.Microsecond)
+	meta.TimeCode = ""
+	if name := comtrade.COMNameFromMetadata(meta, "").FileName(); name != "240315,142530123456,0,Station A,DFR_01,-" {
+		t.Errorf("file name %q", name)
+	}
+}
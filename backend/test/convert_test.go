@@ -0,0 +1,242 @@
+package test
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"comtradeviewer/comtrade"
+)
+
+// convertSource 构造 2 模拟量 + 17 开关量(跨两个打包字)的 1999 版浮点记录
+func convertSource() (*comtrade.Metadata, *comtrade.ChannelData) {
+	const n = 50
+	start := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	meta := &comtrade.Metadata{
+		Station:      "站A",
+		Relay:        "REC1",
+		Version:      "1999",
+		DataFileType: "ascii",
+		AnalogChannels: []comtrade.AnalogChannel{
+			{ChannelNumber: 1, ChannelName: "Ia", Phase: "A", Unit: "kA", Multiplier: 0.001, MinValue: -99999, MaxValue: 99999, Primary: 1200, Secondary: 5, PS: "P"},
+			{ChannelNumber: 2, ChannelName: "Ua", Phase: "A", Unit: "kV", Multiplier: 1, Offset: 0.5, MinValue: -99999, MaxValue: 99999, Primary: 1, Secondary: 1, PS: "S"},
+		},
+		Frequency:      50,
+		RatesNum:       1,
+		SampleRates:    []comtrade.SampleRate{{SampRate: 1000, LastSampleNum: n}},
+		StartTime:      start,
+		EndTime:        start.Add(10 * time.Millisecond),
+		TimeMultiplier: 0.5,
+	}
+	dat := &comtrade.ChannelData{Timestamps: make([]int32, n)}
+	ia := make([]float32, n)
+	ua := make([]float32, n)
+	for i := range n {
+		dat.Timestamps[i] = int32(2*i + 1)
+		ia[i] = float32(i * 37)
+		ua[i] = float32(110 * math.Sin(float64(i)/5))
+	}
+	dat.AnalogChannels = []comtrade.AnalogChannelData{{ChannelNumber: 1, RawDataFloat: ia}, {ChannelNumber: 2, RawDataFloat: ua}}
+	for d := range 17 {
+		meta.DigitalChannels = append(meta.DigitalChannels, comtrade.DigitalChannel{ChannelNumber: d + 1, ChannelName: "D", CCBM: "CB"})
+		y := make([]int8, n)
+		for i := range y {
+			if i > 10+d {
+				y[i] = 1
+			}
+		}
+		dat.DigitalChannels = append(dat.DigitalChannels, comtrade.DigitalChannelData{ChannelNumber: d + 1, RawData: y})
+	}
+	meta.TotalChannelNum, meta.AnalogChannelNum, meta.DigitalChannelNum = 19, 2, 17
+	return meta, dat
+}
+
+// roundTrip 转换后写出 CFG 与 DAT 并重新解析
+func roundTrip(t *testing.T, meta *comtrade.Metadata, dat *comtrade.ChannelData, revision, fileType string) (*comtrade.Metadata, *comtrade.ChannelData, *comtrade.ConversionReport) {
+	t.Helper()
+	m, d, report, err := comtrade.Convert(meta, dat, revision, fileType)
+	if err != nil {
+		t.Fatalf("%s/%s: %v", revision, fileType, err)
+	}
+	var cfg, datBuf bytes.Buffer
+	if err := comtrade.WriteCFG(&cfg, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := comtrade.WriteDAT(&datBuf, m, d); err != nil {
+		t.Fatal(err)
+	}
+	pm, pd, err := comtrade.ParseComtradeFromBytes(cfg.Bytes(), datBuf.Bytes())
+	if err != nil {
+		t.Fatalf("%s/%s: reparse: %v\n%s", revision, fileType, err, cfg.String())
+	}
+	return pm, pd, report
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	meta, dat := convertSource()
+	want := make([][]float64, 2)
+	for k := range want {
+		want[k], _ = comtrade.ScaledAnalogData(meta, dat, k+1)
+	}
+
+	for _, tc := range []struct{ revision, fileType string }{
+		{"1991", "ascii"}, {"1991", "binary"},
+		{"1999", "ascii"}, {"1999", "binary"},
+		{"2013", "ascii"}, {"2013", "binary"}, {"2013", "binary32"}, {"2013", "float32"},
+	} {
+		m, d, report := roundTrip(t, meta, dat, tc.revision, tc.fileType)
+		if m.Version != tc.revision || m.DataFileType != tc.fileType || m.Station != "站A" {
+			t.Fatalf("%+v: header %s %s %q", tc, m.Version, m.DataFileType, m.Station)
+		}
+		if len(d.Timestamps) != len(dat.Timestamps) || len(m.DigitalChannels) != 17 {
+			t.Fatalf("%+v: %d samples, %d digital channels", tc, len(d.Timestamps), len(m.DigitalChannels))
+		}
+		// 每个通道的工程值误差不超过报告值, 且报告值不超过半个码值分辨率
+		for k, ch := range report.Channels {
+			got, err := comtrade.ScaledAnalogData(m, d, ch.Channel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				if e := math.Abs(got[i] - want[k][i]); e > ch.MaxError+1e-9 {
+					t.Fatalf("%+v: channel %d sample %d error %v exceeds reported %v", tc, ch.Channel, i, e, ch.MaxError)
+				}
+			}
+			if ch.Resolution > 0 && ch.MaxError > ch.Resolution/2+1e-12 {
+				t.Fatalf("%+v: channel %d max error %v > resolution/2 %v", tc, ch.Channel, ch.MaxError, ch.Resolution/2)
+			}
+		}
+		for i, ts := range d.Timestamps {
+			got, want := float64(ts)*m.TimeMultiplier, float64(dat.Timestamps[i])*meta.TimeMultiplier
+			if math.Abs(got-want) > report.MaxTimestampError {
+				t.Fatalf("%+v: timestamp[%d]=%v us want %v", tc, i, got, want)
+			}
+		}
+		for k := range 17 {
+			got, _ := d.GetDigitalData(k + 1)
+			orig, _ := dat.GetDigitalData(k + 1)
+			if !slices.Equal(got, orig) {
+				t.Fatalf("%+v: digital channel %d changed", tc, k+1)
+			}
+		}
+	}
+}
+
+func TestConvertPrecisionReport(t *testing.T) {
+	meta, dat := convertSource()
+
+	// Ia 的码值为整数, 在 ASCII 与 binary32 范围内保持无损; Ua 为小数, 需要重新量化
+	_, _, report := roundTrip(t, meta, dat, "1999", "ascii")
+	if !report.Channels[0].Lossless || report.Channels[0].Rescaled {
+		t.Errorf("integer channel should stay lossless: %+v", report.Channels[0])
+	}
+	if report.Channels[1].Lossless || !report.Channels[1].Rescaled || report.Lossless {
+		t.Errorf("fractional channel should be requantized: %+v", report.Channels[1])
+	}
+
+	// Ia 最大码值 1813 在 binary 范围内, Ua 重新量化后用满 ±32767
+	m, d, report := roundTrip(t, meta, dat, "1999", "binary")
+	if !report.Channels[0].Lossless {
+		t.Errorf("binary should keep in-range integer codes: %+v", report.Channels[0])
+	}
+	codes, _, _ := d.GetAnalogData(2)
+	lo, hi := int32(math.MaxInt32), int32(math.MinInt32)
+	for _, x := range codes {
+		lo, hi = min(lo, x), max(hi, x)
+	}
+	if lo != -32767 || hi != 32767 || m.AnalogChannels[1].MaxValue != 32767 {
+		t.Errorf("requantized range [%d, %d], cfg max %v", lo, hi, m.AnalogChannels[1].MaxValue)
+	}
+
+	// float32 保存原浮点码值, 无损
+	if _, _, report := roundTrip(t, meta, dat, "2013", "float32"); !report.Lossless {
+		t.Errorf("float32 conversion should be lossless: %+v", report)
+	}
+}
+
+func TestConvertRevisionFields(t *testing.T) {
+	meta, dat := convertSource()
+
+	// 1991 版: 时间戳换算为微秒, 变比丢弃并给出警告
+	m, d, report := roundTrip(t, meta, dat, "1991", "binary")
+	if m.TimeMultiplier != 1 || d.Timestamps[3] != 4 || report.MaxTimestampError != 0.5 {
+		t.Errorf("1991 timestamps: mult=%v ts[3]=%d maxErr=%v", m.TimeMultiplier, d.Timestamps[3], report.MaxTimestampError)
+	}
+	if len(report.Warnings) != 2 || m.AnalogChannels[0].Primary != 1 || m.DigitalChannels[0].CCBM != "" {
+		t.Errorf("1991 fields: warnings=%v primary=%v ccbm=%q", report.Warnings, m.AnalogChannels[0].Primary, m.DigitalChannels[0].CCBM)
+	}
+
+	// 2013 版: 变比与时间倍率保留, 时区字段写为默认值
+	m, d, report = roundTrip(t, meta, dat, "2013", "binary32")
+	if m.TimeMultiplier != 0.5 || d.Timestamps[3] != 7 || m.AnalogChannels[0].Primary != 1200 || m.AnalogChannels[0].PS != "P" {
+		t.Errorf("2013 fields: mult=%v ts[3]=%d primary=%v ps=%q", m.TimeMultiplier, d.Timestamps[3], m.AnalogChannels[0].Primary, m.AnalogChannels[0].PS)
+	}
+	if m.TimeCode != "0" || m.TmqCode != "0" || len(report.Warnings) != 1 {
+		t.Errorf("2013 time fields: %q %q warnings=%v", m.TimeCode, m.TmqCode, report.Warnings)
+	}
+	if !m.StartTime.Equal(meta.StartTime) {
+		t.Errorf("start time %v want %v", m.StartTime, meta.StartTime)
+	}
+
+	if _, _, _, err := comtrade.Convert(meta, dat, "1999", "float32"); err == nil {
+		t.Error("1999 does not support float32")
+	}
+	if _, _, _, err := comtrade.Convert(meta, dat, "2001", "ascii"); err == nil {
+		t.Error("unknown revision accepted")
+	}
+}
+
+func TestConvertMissingSamples(t *testing.T) {
+	meta, dat := convertSource()
+	meta.Version, meta.DataFileType = "2013", "binary"
+	meta.AnalogChannels[0].MinValue, meta.AnalogChannels[0].MaxValue = -32767, 32767
+	ia := make([]int32, len(dat.Timestamps))
+	for i := range ia {
+		ia[i] = int32(i*100 - 2000)
+	}
+	ia[7] = math.MinInt16
+	dat.AnalogChannels[0] = comtrade.AnalogChannelData{ChannelNumber: 1, RawData: ia}
+
+	// binary → binary32: 缺失值码不参与范围判断, 其余码值保持不变, 缺失样本写为 binary32 的缺失值码
+	_, d, report := roundTrip(t, meta, dat, "2013", "binary32")
+	conv := report.Channels[0]
+	if conv.Missing != 1 || conv.Rescaled || !conv.Lossless {
+		t.Fatalf("binary32: %+v", conv)
+	}
+	codes, _, _ := d.GetAnalogData(1)
+	for i, x := range codes {
+		want := ia[i]
+		if i == 7 {
+			want = math.MinInt32
+		}
+		if x != want {
+			t.Fatalf("binary32 code[%d]=%d want %d", i, x, want)
+		}
+	}
+
+	// binary32 → binary: 超出 binary 范围需要重新量化, 缺失样本不影响量化范围
+	m32, d32, _, err := comtrade.Convert(meta, dat, "2013", "binary32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range ia {
+		d32.AnalogChannels[0].RawData[i] *= 100
+	}
+	d32.AnalogChannels[0].RawData[7] = math.MinInt32
+	_, d, report = roundTrip(t, m32, d32, "2013", "binary")
+	conv = report.Channels[0]
+	if conv.Missing != 1 || !conv.Rescaled || conv.MaxError > conv.Resolution/2+1e-12 {
+		t.Fatalf("binary: %+v", conv)
+	}
+	codes, _, _ = d.GetAnalogData(1)
+	if codes[0] != -32767 || codes[len(codes)-1] != 32767 || codes[7] != math.MinInt16 {
+		t.Fatalf("binary codes first=%d last=%d missing=%d", codes[0], codes[len(codes)-1], codes[7])
+	}
+
+	// ascii 没有缺失值码, 报告给出警告
+	if _, _, report := roundTrip(t, meta, dat, "2013", "ascii"); report.Lossless || len(report.Warnings) != 1 {
+		t.Fatalf("ascii: lossless=%v warnings=%v", report.Lossless, report.Warnings)
+	}
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestVectorGroupMatrix(t *testing.T) {
+	s := 1 / math.Sqrt(3)
+	want := [3][3]float64{{s, 0, -s}, {-s, s, 0}, {0, -s, s}}
+	got := comtrade.VectorGroupMatrix(1, false)
+	for i := range 3 {
+		for j := range 3 {
+			if math.Abs(got[i][j]-want[i][j]) > 1e-9 {
+				t.Fatalf("clock 1: want %v, got %v", want, got)
+			}
+		}
+	}
+
+	if m := comtrade.VectorGroupMatrix(0, false); m != [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}} {
+		t.Fatalf("clock 0 without zero-sequence removal should be identity, got %v", m)
+	}
+	if m := comtrade.VectorGroupMatrix(12, true); math.Abs(m[0][0]-2.0/3) > 1e-9 || math.Abs(m[0][1]+1.0/3) > 1e-9 {
+		t.Fatalf("clock 0 with zero-sequence removal: unexpected %v", m)
+	}
+}
+
+// yd11Currents 构造 Yd11 变压器两侧电流: 低压侧超前高压侧 30°; internal 为 false 时为穿越性电流, 否则两侧同时流入
+func yd11Currents(n int, fs float64, hvAmp, lvAmp float64, internal bool) (hv, lv [3][]float64) {
+	lvShift := math.Pi + math.Pi/6 // 穿越电流: 低压侧流出, 与流入方向相反
+	if internal {
+		lvShift = math.Pi / 6
+	}
+	for k := range 3 {
+		phase := -float64(k) * 2 * math.Pi / 3
+		hv[k] = sineWave(n, fs, 50, hvAmp, phase)
+		lv[k] = sineWave(n, fs, 50, lvAmp, phase+lvShift)
+	}
+	return hv, lv
+}
+
+func TestDifferentialThroughLoadAndInternalFault(t *testing.T) {
+	const fs, n = 2000.0, 400
+	bc := comtrade.DefaultBiasCharacteristic
+
+	// 高压侧 CT 400/1、额定 200 A; 低压侧 CT 2000/1、额定 1000 A; 二次电流峰值对应 1 倍额定电流
+	hvPeak := 200 * math.Sqrt2 / 400
+	lvPeak := 1000 * math.Sqrt2 / 2000
+
+	for _, internal := range []bool{false, true} {
+		hv, lv := yd11Currents(n, fs, hvPeak, lvPeak, internal)
+		groups := []comtrade.DifferentialGroup{
+			{Currents: hv, CTRatio: 400, Base: 200, Clock: 11},
+			{Currents: lv, CTRatio: 2000, Base: 1000, Clock: 0, RemoveZeroSequence: true},
+		}
+		res, err := comtrade.ComputeDifferential(groups, fs, 50, comtrade.RestraintSum)
+		if err != nil {
+			t.Fatalf("compute: %v", err)
+		}
+		for k := range 3 {
+			id, ir := res.Id[k][n-1], res.Ir[k][n-1]
+			if math.Abs(ir-1) > 0.01 {
+				t.Fatalf("internal=%v phase %d: expected Ir≈1 pu, got %v", internal, k, ir)
+			}
+			if !internal && (id > 0.01 || bc.Operates(id, ir)) {
+				t.Fatalf("through load phase %d: expected Id≈0, got %v", k, id)
+			}
+			if internal && (math.Abs(id-2) > 0.01 || !bc.Operates(id, ir)) {
+				t.Fatalf("internal fault phase %d: expected Id≈2 pu and operation, got %v", k, id)
+			}
+		}
+	}
+
+	if _, err := comtrade.ComputeDifferential(nil, fs, 50, ""); err == nil {
+		t.Fatalf("expected error for fewer than two groups")
+	}
+	hv, lv := yd11Currents(n, fs, 1, 1, false)
+	mixed := []comtrade.DifferentialGroup{{Currents: hv, Base: 200, Clock: 11}, {Currents: lv}}
+	if _, err := comtrade.ComputeDifferential(mixed, fs, 50, ""); err == nil {
+		t.Fatalf("expected error when base is given for only some groups")
+	}
+}
+
+func TestBiasCharacteristicThreshold(t *testing.T) {
+	bc := comtrade.BiasCharacteristic{Pickup: 0.2, Slope1: 0.25, Breakpoint: 2, Slope2: 0.5, Unrestrained: 5}
+	cases := []struct{ ir, want float64 }{{0, 0.2}, {0.5, 0.2}, {1.6, 0.4}, {2, 0.5}, {4, 1.5}}
+	for _, c := range cases {
+		if got := bc.Threshold(c.ir); math.Abs(got-c.want) > 1e-9 {
+			t.Fatalf("threshold at Ir=%v: want %v, got %v", c.ir, c.want, got)
+		}
+	}
+	if !bc.Operates(5, 100) {
+		t.Fatalf("unrestrained setting should operate regardless of restraint")
+	}
+	if bc.Operates(1.4, 4) {
+		t.Fatalf("point below the second slope should not operate")
+	}
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestDetectAnalogDisturbances(t *testing.T) {
+	const fs, n = 1000.0, 3000
+	// 1500~1600 采样之间幅值由 100 突增到 300
+	y := sineWave(n, fs, 50, 100, 0.2)
+	for i := 1500; i < 1600; i++ {
+		y[i] *= 3
+	}
+
+	settings := comtrade.DefaultDetectorSettings
+	settings.Over = 150
+	windows := comtrade.DetectAnalogDisturbances(y, 1, fs, 50, comtrade.DisturbanceDetectors, settings)
+
+	found := map[string]bool{}
+	for _, w := range windows {
+		if w.Start < 1480 || w.End > 1650 {
+			t.Fatalf("window outside the disturbance: %+v", w)
+		}
+		if w.Severity < 1 {
+			t.Fatalf("severity must be >= 1: %+v", w)
+		}
+		found[w.Detector+"/"+w.Type] = true
+	}
+	for _, want := range []string{"rmsstep/step", "delta/delta", "threshold/over"} {
+		if !found[want] {
+			t.Fatalf("expected a %s window, got %+v", want, windows)
+		}
+	}
+
+	events := comtrade.MergeDisturbances(windows, 20)
+	if len(events) != 1 || len(events[0].Detectors) != 3 {
+		t.Fatalf("expected a single merged event from 3 detectors, got %+v", events)
+	}
+
+	quiet := comtrade.DetectAnalogDisturbances(sineWave(n, fs, 50, 100, 0), 1, fs, 50, comtrade.DisturbanceDetectors, comtrade.DefaultDetectorSettings)
+	if len(quiet) != 0 {
+		t.Fatalf("steady sine should not trigger detectors, got %+v", quiet)
+	}
+}
+
+func TestDetectDigitalDisturbances(t *testing.T) {
+	windows := comtrade.DetectDigitalDisturbances([]int8{0, 0, 1, 1, 0}, 3)
+	if len(windows) != 2 || windows[0].Start != 2 || windows[0].Type != "rise" || windows[1].Type != "fall" || windows[1].Channel != 3 {
+		t.Fatalf("unexpected digital windows: %+v", windows)
+	}
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestDigitalEventsSortedWithTriggerTime(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	meta := &comtrade.Metadata{
+		DigitalChannels: []comtrade.DigitalChannel{
+			{ChannelNumber: 1, ChannelName: "Trip", Y: 0},
+			{ChannelNumber: 2, ChannelName: "CB Closed", Y: 1},
+		},
+		RatesNum:    1,
+		SampleRates: []comtrade.SampleRate{{SampRate: 1000, LastSampleNum: 10}},
+		StartTime:   start,
+		EndTime:     start.Add(3 * time.Millisecond),
+	}
+	dat := &comtrade.ChannelData{
+		Timestamps: make([]int32, 10),
+		DigitalChannels: []comtrade.DigitalChannelData{
+			{ChannelNumber: 1, RawData: []int8{0, 0, 0, 0, 1, 1, 1, 0, 0, 0}},
+			{ChannelNumber: 2, RawData: []int8{1, 1, 1, 1, 1, 1, 0, 0, 0, 0}},
+		},
+	}
+	timestamps := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, len(dat.Timestamps))
+
+	events := comtrade.DigitalEvents(meta, dat, nil, timestamps)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	wantIdx := []int{4, 6, 7}
+	wantCh := []int{1, 2, 1}
+	wantAbnormal := []bool{true, true, false}
+	for i, e := range events {
+		if e.Index != wantIdx[i] || e.Channel != wantCh[i] || e.Abnormal != wantAbnormal[i] {
+			t.Fatalf("event %d: unexpected %+v", i, e)
+		}
+	}
+	if events[0].TriggerTime != 1 || !events[0].Timestamp.Equal(start.Add(4*time.Millisecond)) {
+		t.Fatalf("unexpected event time: trigger %v, absolute %v", events[0].TriggerTime, events[0].Timestamp)
+	}
+
+	filtered := comtrade.DigitalEvents(meta, dat, []int{2}, timestamps)
+	if len(filtered) != 1 || filtered[0].Channel != 2 {
+		t.Fatalf("expected only channel 2 events, got %+v", filtered)
+	}
+}
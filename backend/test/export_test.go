@@ -0,0 +1,379 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"comtradeviewer/export"
+)
+
+func TestCSVExport(t *testing.T) {
+	var buf bytes.Buffer
+	w := export.NewCSVWriter(&buf, ';', true)
+	if err := w.WriteHeader("Time (ms)", []export.Column{{Name: "Ia", Unit: "kA"}, {Name: "断路器,合位"}}); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteRow(export.Row{Time: 0.25, Values: []float64{1.5, 1}})
+	w.WriteRow(export.Row{Label: "2024-01-02T03:04:05.000000", Values: []float64{math.NaN(), 0}})
+	w.WriteRow(export.Row{Time: 0.5, Values: []float64{math.Inf(1), math.Inf(-1)}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\uFEFFTime (ms);Ia (kA);断路器,合位\n0.25;1.5;1\n2024-01-02T03:04:05.000000;;0\n0.5;;\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected csv:\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestXLSXExport(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewXLSXWriter(&buf, "录波/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteHeader("Time (ms)", []export.Column{{Name: "Ia & Ib", Unit: "A"}})
+	for i := range 3 {
+		w.WriteRow(export.Row{Time: float64(i), Values: []float64{float64(i) * 2}})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("missing part %s", name)
+		}
+		// 各部件须为格式良好的 XML
+		d := xml.NewDecoder(strings.NewReader(parts[name]))
+		for {
+			if _, err := d.Token(); err != nil {
+				if err != io.EOF {
+					t.Fatalf("malformed %s: %v", name, err)
+				}
+				break
+			}
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="录波_1"`) {
+		t.Fatalf("sheet name not sanitized: %s", parts["xl/workbook.xml"])
+	}
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				T      string `xml:"t,attr"`
+				V      string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 4 || sheet.Rows[3].R != 4 {
+		t.Fatalf("unexpected rows: %+v", sheet.Rows)
+	}
+	if h := sheet.Rows[0].Cells[1]; h.T != "inlineStr" || h.Inline != "Ia & Ib (A)" {
+		t.Fatalf("unexpected header cell: %+v", h)
+	}
+	if v := sheet.Rows[3].Cells[1].V; v != "4" {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+// matElement 读取一个 MAT v5 数据元素, 返回解码后的值: 数值矩阵为 []float64, 字符数组为 string, 结构体数组为 []map[string]any
+func matElement(t *testing.T, b []byte) (name string, value any, dims [2]int32, rest []byte) {
+	t.Helper()
+	typ, n := binary.LittleEndian.Uint32(b), int(binary.LittleEndian.Uint32(b[4:]))
+	if typ != 14 || len(b) < 8+n || n%8 != 0 {
+		t.Fatalf("bad matrix element: type %d, %d bytes of %d", typ, n, len(b)-8)
+	}
+	body, rest := b[8:8+n], b[8+n:]
+	sub := func() (uint32, []byte) {
+		typ, n := binary.LittleEndian.Uint32(body), int(binary.LittleEndian.Uint32(body[4:]))
+		data := body[8 : 8+n]
+		body = body[8+(n+7)&^7:]
+		return typ, data
+	}
+
+	_, flags := sub()
+	class := flags[0]
+	_, d := sub()
+	dims = [2]int32{int32(binary.LittleEndian.Uint32(d)), int32(binary.LittleEndian.Uint32(d[4:]))}
+	_, nb := sub()
+	name = string(nb)
+
+	switch class {
+	case 6:
+		_, data := sub()
+		v := make([]float64, len(data)/8)
+		for i := range v {
+			v[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+		}
+		value = v
+	case 4:
+		_, data := sub()
+		u := make([]uint16, len(data)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+		value = string(utf16.Decode(u))
+	case 2:
+		_, fl := sub()
+		fieldLen := int(binary.LittleEndian.Uint32(fl))
+		_, names := sub()
+		fields := make([]string, len(names)/fieldLen)
+		for i := range fields {
+			fields[i] = strings.TrimRight(string(names[i*fieldLen:(i+1)*fieldLen]), "\x00")
+		}
+		elements := make([]map[string]any, dims[0]*dims[1])
+		for i := range elements {
+			elements[i] = make(map[string]any)
+			for _, f := range fields {
+				_, v, _, r := matElement(t, body)
+				elements[i][f] = v
+				body = r
+			}
+		}
+		value = elements
+	default:
+		t.Fatalf("unexpected class %d", class)
+	}
+	if len(body) != 0 {
+		t.Fatalf("%d trailing bytes in element %q", len(body), name)
+	}
+	return name, value, dims, rest
+}
+
+func TestMATExport(t *testing.T) {
+	data := []float64{1, 2.5, -3}
+	vars := []export.MATVariable{
+		{Name: "record", Value: export.MATStruct([]string{"station", "sampleRates"}, []export.MATValue{
+			export.MATString("变电站A"),
+			export.MATMatrix(2, 2, []float64{1000, 100, 4000, 500}),
+		})},
+		{Name: "channels", Value: export.MATStruct([]string{"name", "phase", "data"},
+			[]export.MATValue{export.MATString("Ia"), export.MATString(""), export.MATVector(3, func(i int) float64 { return data[i] })},
+			[]export.MATValue{export.MATString("Ib"), export.MATString("B"), export.MATVector(0, nil)},
+		)},
+	}
+	var buf bytes.Buffer
+	if err := export.WriteMAT(&buf, vars); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if int64(len(b)) != export.MATFileSize(vars) {
+		t.Fatalf("file size %d, predicted %d", len(b), export.MATFileSize(vars))
+	}
+	if !strings.HasPrefix(string(b), "MATLAB 5.0 MAT-file") || binary.LittleEndian.Uint16(b[124:]) != 0x0100 || string(b[126:128]) != "IM" {
+		t.Fatalf("bad header: %q", b[:128])
+	}
+
+	name, value, _, rest := matElement(t, b[128:])
+	record := value.([]map[string]any)
+	if name != "record" || record[0]["station"] != "变电站A" {
+		t.Fatalf("unexpected record %s: %+v", name, record)
+	}
+	// 按列优先存储
+	if rates := record[0]["sampleRates"].([]float64); !slices.Equal(rates, []float64{1000, 4000, 100, 500}) {
+		t.Fatalf("unexpected sample rates %v", rates)
+	}
+
+	name, value, dims, rest := matElement(t, rest)
+	channels := value.([]map[string]any)
+	if name != "channels" || dims != [2]int32{1, 2} || len(rest) != 0 {
+		t.Fatalf("unexpected channels %s %v, %d trailing bytes", name, dims, len(rest))
+	}
+	if channels[0]["name"] != "Ia" || channels[0]["phase"] != "" || !slices.Equal(channels[0]["data"].([]float64), data) {
+		t.Fatalf("unexpected first channel %+v", channels[0])
+	}
+	if channels[1]["phase"] != "B" || len(channels[1]["data"].([]float64)) != 0 {
+		t.Fatalf("unexpected second channel %+v", channels[1])
+	}
+}
+
+// thriftReader Thrift compact protocol 解码, 结构体解码为 map[字段号]值, 用于校验 Parquet 元数据
+type thriftReader struct {
+	b []byte
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 5, 6:
+		return r.zigzag()
+	case 8:
+		n := int(r.uvarint())
+		s := string(r.b[:n])
+		r.b = r.b[n:]
+		return s
+	case 9:
+		h := r.b[0]
+		r.b = r.b[1:]
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(h & 0x0f)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", typ))
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	out := make(map[int16]any)
+	var last int16
+	for {
+		h := r.b[0]
+		r.b = r.b[1:]
+		if h == 0 {
+			return out
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			last += delta
+		} else {
+			last = int16(r.zigzag())
+		}
+		out[last] = r.value(h & 0x0f)
+	}
+}
+
+func TestParquetExport(t *testing.T) {
+	fields := []export.ParquetField{
+		{Name: "channel", Kind: export.ParquetString},
+		{Name: "timestamp", Kind: export.ParquetTimestamp},
+		{Name: "value", Kind: export.ParquetDouble},
+	}
+	for _, compression := range []string{export.ParquetCompressionNone, export.ParquetCompressionGzip} {
+		var buf bytes.Buffer
+		pw, err := export.NewParquetWriter(&buf, fields, export.ParquetOptions{RowGroupRows: 4, Compression: compression, Metadata: [][2]string{{"station", "站A"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 10 {
+			pw.SetString(0, fmt.Sprintf("通道%d", i%3))
+			pw.SetTimestamp(1, int64(i)*250)
+			pw.SetDouble(2, float64(i)/2)
+			if err := pw.EndRow(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		b := buf.Bytes()
+		if string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+			t.Fatalf("missing magic")
+		}
+		n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+		footer := &thriftReader{b: b[len(b)-8-n : len(b)-8]}
+		meta := footer.structure()
+		if len(footer.b) != 0 {
+			t.Fatalf("%d trailing footer bytes", len(footer.b))
+		}
+		if meta[3] != int64(10) {
+			t.Fatalf("num_rows = %v", meta[3])
+		}
+		schema := meta[2].([]any)
+		if len(schema) != 4 || schema[0].(map[int16]any)[5] != int64(3) || schema[1].(map[int16]any)[4] != "channel" {
+			t.Fatalf("unexpected schema %v", schema)
+		}
+		if kv := meta[5].([]any)[0].(map[int16]any); kv[1] != "station" || kv[2] != "站A" {
+			t.Fatalf("unexpected key-value metadata %v", kv)
+		}
+
+		// 3 个行组(4+4+2 行), 逐列解出数据页并还原取值
+		groups := meta[4].([]any)
+		if len(groups) != 3 {
+			t.Fatalf("expected 3 row groups, got %d", len(groups))
+		}
+		var names []string
+		var stamps []int64
+		var values []float64
+		for _, g := range groups {
+			g := g.(map[int16]any)
+			rows := int(g[3].(int64))
+			for ci, chunk := range g[1].([]any) {
+				cm := chunk.(map[int16]any)[3].(map[int16]any)
+				offset := cm[9].(int64)
+				page := &thriftReader{b: b[offset:]}
+				header := page.structure()
+				data := page.b[:header[3].(int64)]
+				if int64(len(b[offset:])-len(page.b))+header[3].(int64) != cm[7].(int64) {
+					t.Fatalf("compressed size mismatch in column %d", ci)
+				}
+				if compression == export.ParquetCompressionGzip {
+					zr, err := gzip.NewReader(bytes.NewReader(data))
+					if err != nil {
+						t.Fatal(err)
+					}
+					data, _ = io.ReadAll(zr)
+				}
+				if int64(len(data)) != header[2].(int64) || header[5].(map[int16]any)[1] != int64(rows) {
+					t.Fatalf("unexpected page header %v", header)
+				}
+				for range rows {
+					switch ci {
+					case 0:
+						n := binary.LittleEndian.Uint32(data)
+						names = append(names, string(data[4:4+n]))
+						data = data[4+n:]
+					case 1:
+						stamps = append(stamps, int64(binary.LittleEndian.Uint64(data)))
+						data = data[8:]
+					case 2:
+						values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+						data = data[8:]
+					}
+				}
+			}
+		}
+		if len(names) != 10 || names[4] != "通道1" || stamps[9] != 2250 || values[7] != 3.5 {
+			t.Fatalf("%s: unexpected values %v %v %v", compression, names, stamps, values)
+		}
+	}
+}
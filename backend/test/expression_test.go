@@ -0,0 +1,151 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+// expressionDataset 构造 1000Hz 采样、50Hz 额定频率的三相电流及一个开关量
+func expressionDataset() (*comtrade.Metadata, *comtrade.ChannelData) {
+	const n = 100
+	meta := &comtrade.Metadata{
+		AnalogChannels: []comtrade.AnalogChannel{
+			{ChannelNumber: 1, ChannelName: "Ia", Unit: "A", Multiplier: 1},
+			{ChannelNumber: 2, ChannelName: "Ib", Unit: "A", Multiplier: 1},
+			{ChannelNumber: 3, ChannelName: "I C 相", Unit: "A", Multiplier: 0.5},
+		},
+		DigitalChannels: []comtrade.DigitalChannel{{ChannelNumber: 1, ChannelName: "Trip"}},
+		Frequency:       50,
+		RatesNum:        1,
+		SampleRates:     []comtrade.SampleRate{{SampRate: 1000, LastSampleNum: n}},
+	}
+	dat := &comtrade.ChannelData{Timestamps: make([]int32, n)}
+	for k := range 3 {
+		raw := make([]int32, n)
+		scale := 1.0 / meta.AnalogChannels[k].Multiplier
+		for i, v := range sineWave(n, 1000, 50, 100, -float64(k)*2*math.Pi/3) {
+			raw[i] = int32(math.Round(v * scale))
+		}
+		dat.AnalogChannels = append(dat.AnalogChannels, comtrade.AnalogChannelData{ChannelNumber: k + 1, RawData: raw})
+	}
+	trip := make([]int8, n)
+	for i := 50; i < n; i++ {
+		trip[i] = 1
+	}
+	dat.DigitalChannels = []comtrade.DigitalChannelData{{ChannelNumber: 1, RawData: trip}}
+	return meta, dat
+}
+
+func evaluate(t *testing.T, src string) []float64 {
+	t.Helper()
+	meta, dat := expressionDataset()
+	expr, err := comtrade.ParseExpression(src)
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+	y, err := expr.Evaluate(meta, dat, comtrade.AnalogOptions{})
+	if err != nil {
+		t.Fatalf("evaluate %q: %v", src, err)
+	}
+	return y
+}
+
+func TestExpressionArithmeticAndReferences(t *testing.T) {
+	meta, dat := expressionDataset()
+	ia, _ := comtrade.ScaledAnalogData(meta, dat, 1)
+	ib, _ := comtrade.ScaledAnalogData(meta, dat, 2)
+
+	// 按编号、名称、方括号名称引用同一组通道, 三相之和约为 0
+	for _, src := range []string{"A1+A2+A3", "Ia + Ib + [I C 相]"} {
+		for i, v := range evaluate(t, src) {
+			if math.Abs(v) > 2 {
+				t.Fatalf("%s: expected near-zero residual at %d, got %v", src, i, v)
+			}
+		}
+	}
+
+	y := evaluate(t, "(Ia - Ib) / sqrt(3) * 2 ^ 2 - -1")
+	for i := range y {
+		want := (ia[i]-ib[i])/math.Sqrt(3)*4 + 1
+		if math.Abs(y[i]-want) > 1e-9 {
+			t.Fatalf("index %d: want %v, got %v", i, want, y[i])
+		}
+	}
+}
+
+func TestExpressionCycleFunctions(t *testing.T) {
+	rms := evaluate(t, "rms(Ia)")
+	mag := evaluate(t, "mag(A1)")
+	ang := evaluate(t, "ang(Ib) - ang(Ia)")
+	for i := 20; i < len(rms); i++ {
+		if math.Abs(rms[i]-100/math.Sqrt2) > 0.5 || math.Abs(mag[i]-100/math.Sqrt2) > 0.5 {
+			t.Fatalf("index %d: unexpected rms %v / mag %v", i, rms[i], mag[i])
+		}
+		if d := math.Mod(ang[i]+360+120, 360); d > 0.5 && d < 359.5 {
+			t.Fatalf("index %d: expected -120° between Ib and Ia, got %v", i, ang[i])
+		}
+	}
+}
+
+func TestExpressionDigitalLogic(t *testing.T) {
+	y := evaluate(t, "Trip && !(abs(Ia) > 1000) || D1 == 2")
+	for i, v := range y {
+		want := 0.0
+		if i >= 50 {
+			want = 1
+		}
+		if v != want {
+			t.Fatalf("index %d: want %v, got %v", i, want, v)
+		}
+	}
+	y = evaluate(t, "if(Trip, max(Ia, Ib, 0), -1)")
+	if y[10] != -1 || y[60] < 0 {
+		t.Fatalf("unexpected if/max result: %v %v", y[10], y[60])
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	for _, src := range []string{"Ia +", "foo(Ia)", "max()", "(Ia", "Ia $ Ib", "[Ia"} {
+		if _, err := comtrade.ParseExpression(src); err == nil {
+			t.Fatalf("expected parse error for %q", src)
+		}
+	}
+
+	meta, dat := expressionDataset()
+	expr, err := comtrade.ParseExpression("Ia + Unknown")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := expr.Evaluate(meta, dat, comtrade.AnalogOptions{}); err == nil {
+		t.Fatalf("expected error for unknown channel")
+	}
+}
+
+func TestExpressionBracketedNameVersusNumber(t *testing.T) {
+	meta, dat := expressionDataset()
+	// 通道 2 名为 "A1": [A1] 为通道 2, A1 为通道 1, 先后解析的结果不能互相混用
+	meta.AnalogChannels[1].ChannelName = "A1"
+	ia, _ := comtrade.ScaledAnalogData(meta, dat, 1)
+	ib, _ := comtrade.ScaledAnalogData(meta, dat, 2)
+	for src, want := range map[string]func(i int) float64{
+		"A1 - [A1]": func(i int) float64 { return ia[i] - ib[i] },
+		"[A1] - A1": func(i int) float64 { return ib[i] - ia[i] },
+		"[A1] - A2": func(i int) float64 { return 0 },
+	} {
+		expr, err := comtrade.ParseExpression(src)
+		if err != nil {
+			t.Fatalf("parse %q: %v", src, err)
+		}
+		y, err := expr.Evaluate(meta, dat, comtrade.AnalogOptions{})
+		if err != nil {
+			t.Fatalf("evaluate %q: %v", src, err)
+		}
+		for i := range y {
+			if math.Abs(y[i]-want(i)) > 1e-9 {
+				t.Fatalf("%s index %d: want %v, got %v", src, i, want(i), y[i])
+			}
+		}
+	}
+}
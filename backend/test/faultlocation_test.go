@@ -0,0 +1,55 @@
+package test
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func constantPhasors(a, b, c complex128, n int) [3][]complex128 {
+	var out [3][]complex128
+	for k, v := range []complex128{a, b, c} {
+		out[k] = make([]complex128, n)
+		for j := range out[k] {
+			out[k][j] = v
+		}
+	}
+	return out
+}
+
+func TestEstimateFaultDistanceAG(t *testing.T) {
+	line := comtrade.LineParams{R1: 0.03, X1: 0.4, R0: 0.1, X0: 1.2, Length: 100}
+	const distance, rf = 35.0, 5.0
+	z1 := line.Z1()
+	k0 := line.K0()
+
+	// 故障前: 三相对称负荷电流; 故障后: A相叠加故障电流 ΔI
+	a := cmplx.Rect(1, 2*math.Pi/3)
+	load := cmplx.Rect(400, -0.2)
+	delta := cmplx.Rect(3000, -1.3)
+	preI := [3]complex128{load, a * a * load, a * load}
+	postI := [3]complex128{load + delta, a * a * load, a * load}
+	in := delta
+	vA := complex(distance, 0)*z1*(postI[0]+k0*in) + complex(rf, 0)*delta
+
+	const n, pre, at = 10, 2, 8
+	i := constantPhasors(preI[0], preI[1], preI[2], n)
+	for j := at - 1; j < n; j++ {
+		i[0][j], i[1][j], i[2][j] = postI[0], postI[1], postI[2]
+	}
+	v := constantPhasors(vA, 0, 0, n)
+
+	got := comtrade.EstimateFaultDistance("AG", v, i, line, at, pre)
+	if !got.TakagiAvailable {
+		t.Fatal("expected Takagi estimate to be available")
+	}
+	if math.Abs(got.TakagiKm-distance) > 0.01 {
+		t.Fatalf("Takagi: expected %v km, got %v", distance, got.TakagiKm)
+	}
+	// 过渡电阻与负荷电流使电抗法产生误差, 但仍应在合理范围内
+	if math.Abs(got.ReactanceKm-distance) > 5 {
+		t.Fatalf("reactance: expected ~%v km, got %v", distance, got.ReactanceKm)
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+// simulateFault 生成三相电流: 故障前为对称负荷, [inception, clearance) 区间内叠加各相故障电流, 切除后电流为零
+func simulateFault(fs, f float64, n, inception, clearance int, fault [3]complex128) [3][]float64 {
+	var out [3][]float64
+	for k := range 3 {
+		out[k] = make([]float64, n)
+		shift := -2 * math.Pi / 3 * float64(k)
+		for j := range n {
+			wt := 2 * math.Pi * f * float64(j) / fs
+			switch {
+			case j < inception:
+				out[k][j] = 100 * math.Sqrt2 * math.Sin(wt+shift-0.3)
+			case j < clearance:
+				load := 100 * math.Sqrt2 * math.Sin(wt+shift-0.3)
+				mag, ang := math.Hypot(real(fault[k]), imag(fault[k])), math.Atan2(imag(fault[k]), real(fault[k]))
+				out[k][j] = load + mag*math.Sqrt2*math.Sin(wt+ang)
+			}
+		}
+	}
+	return out
+}
+
+func TestClassifyFault(t *testing.T) {
+	const fs, f = 4000.0, 50.0
+	const n, inception, clearance = 2000, 800, 1200
+
+	cases := []struct {
+		name  string
+		fault [3]complex128
+		want  string
+	}{
+		{"AG", [3]complex128{complex(2000, -1500), 0, 0}, "AG"},
+		{"BC", [3]complex128{0, complex(0, -2000), complex(0, 2000)}, "BC"},
+		{"ABC", [3]complex128{complex(0, -2000), complex(-1732, 1000), complex(1732, 1000)}, "ABC"},
+		{"CAG", [3]complex128{complex(-1200, -1800), complex(150, -150), complex(1800, 700)}, "CAG"},
+	}
+
+	for _, tc := range cases {
+		currents := simulateFault(fs, f, n, inception, clearance, tc.fault)
+		got := comtrade.ClassifyFault(currents, [3][]float64{}, fs, f, inception+10)
+		if got.Type != tc.want {
+			t.Fatalf("%s: expected type %s, got %s (%+v)", tc.name, tc.want, got.Type, got)
+		}
+		if got.Inception < inception || got.Inception > inception+10 {
+			t.Fatalf("%s: expected inception near %d, got %d", tc.name, inception, got.Inception)
+		}
+		if got.Clearance < clearance-20 || got.Clearance > clearance+20 {
+			t.Fatalf("%s: expected clearance near %d, got %d", tc.name, clearance, got.Clearance)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func sineWave(n int, fs, freq, amplitude, phase float64) []float64 {
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = amplitude * math.Sin(2*math.Pi*freq*float64(i)/fs+phase)
+	}
+	return y
+}
+
+func TestEstimateFrequencyOffNominal(t *testing.T) {
+	const fs, nominal, actual = 4000.0, 50.0, 50.6
+	y := sineWave(4000, fs, actual, 100, 0.3)
+
+	for _, method := range []string{comtrade.FrequencyMethodZeroCrossing, comtrade.FrequencyMethodPhase} {
+		track, err := comtrade.EstimateFrequency(y, 0, len(y)-1, fs, nominal, method)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", method, err)
+		}
+		if len(track.Frequency) == 0 {
+			t.Fatalf("%s: no frequency estimates", method)
+		}
+		if len(track.DfDt) != len(track.Frequency) {
+			t.Fatalf("%s: dfdt length %d != frequency length %d", method, len(track.DfDt), len(track.Frequency))
+		}
+		mid := track.FrequencyAt(len(y) / 2)
+		if math.Abs(mid-actual) > 0.02 {
+			t.Fatalf("%s: expected ~%v Hz, got %v", method, actual, mid)
+		}
+	}
+}
+
+func TestEstimateFrequencyRejectsShortWindow(t *testing.T) {
+	y := sineWave(50, 4000, 50, 1, 0)
+	if _, err := comtrade.EstimateFrequency(y, 0, len(y)-1, 4000, 50, comtrade.FrequencyMethodZeroCrossing); err == nil {
+		t.Fatal("expected error for window shorter than two cycles")
+	}
+}
+
+func TestDFTPhasorWithTrackedFrequency(t *testing.T) {
+	const fs, actual = 4000.0, 51.0
+	amplitude := 100.0
+	y := sineWave(4000, fs, actual, amplitude, 0)
+
+	track, err := comtrade.EstimateFrequency(y, 0, len(y)-1, fs, 50, comtrade.FrequencyMethodZeroCrossing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := amplitude / math.Sqrt2
+	idx := 2000
+	tracked := cmplx.Abs(comtrade.DFTPhasor(y, idx, fs, track.FrequencyAt(idx)))
+	if math.Abs(tracked-want)/want > 0.01 {
+		t.Fatalf("tracked DFT magnitude: expected ~%v, got %v", want, tracked)
+	}
+
+	rms := comtrade.CycleRMS(y, idx, fs, track.FrequencyAt(idx))
+	if math.Abs(rms-want)/want > 0.01 {
+		t.Fatalf("tracked RMS: expected ~%v, got %v", want, rms)
+	}
+}
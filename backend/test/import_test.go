@@ -0,0 +1,154 @@
+package test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/importer"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestImportCSVColumns(t *testing.T) {
+	csv := "PSCAD output\n\nDomain,Ia (kA),Ua [kV],BRK\n0,0.1,1.5,0\n0.0005,0.2,1.25,0\n0.001,-0.3,1,1\n"
+	ts, err := importer.ReadCSV([]byte("\uFEFF"+csv), importer.CSVOptions{SkipRows: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Time) != 3 || ts.Time[2] != 0.001 || len(ts.Columns) != 3 {
+		t.Fatalf("time=%v columns=%d", ts.Time, len(ts.Columns))
+	}
+	if ts.Columns[0].Name != "Ia (kA)" || ts.Columns[0].Number != 2 || ts.Columns[2].Values[2] != 1 {
+		t.Fatalf("unexpected columns: %+v", ts.Columns)
+	}
+
+	// 分号分隔、逗号小数点, 时间单位为毫秒
+	ts, err = importer.ReadCSV([]byte("t;Ua\n0;1,5\n0,5;2,25\n"), importer.CSVOptions{TimeUnit: "ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Time[1] != 0.5e-3 || ts.Columns[0].Values[1] != 2.25 {
+		t.Fatalf("decimal comma: time=%v values=%v", ts.Time, ts.Columns[0].Values)
+	}
+
+	// 空白分隔、无表头, 按列号指定时间列
+	ts, err = importer.ReadCSV([]byte("  1  0.0  5\n 2  0.001  6\n"), importer.CSVOptions{NoHeader: true, TimeColumn: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Columns) != 2 || ts.Columns[1].Name != "C3" || ts.Columns[1].Number != 3 || ts.Time[1] != 0.001 {
+		t.Fatalf("whitespace: %+v time=%v", ts.Columns, ts.Time)
+	}
+
+	// GBK 编码的中文表头
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("时间,电流\n0,1\n0.001,2\n"))
+	ts, err = importer.ReadCSV(gbk, importer.CSVOptions{})
+	if err != nil || len(ts.Time) != 2 || ts.Columns[0].Name != "电流" {
+		t.Fatalf("gbk: %v %+v", err, ts)
+	}
+
+	for name, tc := range map[string]struct {
+		data string
+		opts importer.CSVOptions
+		want string
+	}{
+		"bad number":  {"t,a\n0,1\n0.001,x\n", importer.CSVOptions{}, "row 3 column 2"},
+		"short row":   {"t,a,b\n0,1,2\n0.001,1\n", importer.CSVOptions{}, "row 3 has 2 columns"},
+		"time column": {"t,a\n0,1\n", importer.CSVOptions{TimeColumn: "x"}, "time column"},
+		"time unit":   {"t,a\n0,1\n", importer.CSVOptions{TimeUnit: "min"}, "time unit"},
+	} {
+		if _, err := importer.ReadCSV([]byte(tc.data), tc.opts); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err=%v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestImportBuildRecord(t *testing.T) {
+	const n = 200
+	ts := &importer.TimeSeries{Time: make([]float64, n)}
+	ia := make([]float64, n)
+	brk := make([]float64, n)
+	for i := range n {
+		ts.Time[i] = 1 + float64(i)*50e-6
+		ia[i] = 2 * math.Sin(2*math.Pi*50*float64(i)*50e-6)
+		if i >= 120 {
+			brk[i] = 1
+		}
+	}
+	ts.Columns = []importer.SeriesColumn{{Name: "Ia (kA)", Number: 2, Values: ia}, {Name: "BRK", Number: 3, Values: brk}}
+	threshold := 0.0
+	start := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	opts := importer.SeriesOptions{
+		Station:       "仿真",
+		StartTime:     start,
+		TriggerOffset: 6,
+		Channels: []importer.SeriesChannel{
+			{Column: "ia (KA)", Name: "IA", Unit: "A", Scale: 1000, Phase: "A"},
+			{Column: "3", Type: "digital", Threshold: &threshold},
+		},
+	}
+	meta, dat, err := importer.Build(ts, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 时间列等间隔 50us → 单一采样率 20kHz, 时间戳从 0 开始
+	if meta.RatesNum != 1 || meta.SampleRates[0].SampRate != 20000 || dat.Timestamps[n-1] != (n-1)*50 {
+		t.Fatalf("rates=%+v last ts=%d", meta.SampleRates, dat.Timestamps[n-1])
+	}
+	if !meta.EndTime.Equal(start.Add(6 * time.Millisecond)) {
+		t.Errorf("trigger time %v", meta.EndTime)
+	}
+	if meta.DigitalChannels[0].ChannelName != "BRK" || meta.AnalogChannels[0].PS != "P" {
+		t.Errorf("channels: %+v %+v", meta.AnalogChannels[0], meta.DigitalChannels[0])
+	}
+
+	// 写出后重新解析, 模拟量按 scale 换算为工程值
+	var cfg, datBuf bytes.Buffer
+	if err := comtrade.WriteCFG(&cfg, meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := comtrade.WriteDAT(&datBuf, meta, dat); err != nil {
+		t.Fatal(err)
+	}
+	m, d, err := comtrade.ParseComtradeFromBytes(cfg.Bytes(), datBuf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, _ := comtrade.ScaledAnalogData(m, d, 1)
+	for i := range y {
+		if math.Abs(y[i]-ia[i]*1000) > 1e-3 {
+			t.Fatalf("sample %d: %v want %v", i, y[i], ia[i]*1000)
+		}
+	}
+	states, _ := d.GetDigitalData(1)
+	if states[119] != 0 || states[120] != 1 {
+		t.Errorf("digital threshold: %v %v", states[119], states[120])
+	}
+
+	// 非等间隔时间列: 不记录采样率, 时间轴取自时间戳
+	ts.Time[10] += 20e-6
+	meta, dat, err = importer.Build(ts, importer.SeriesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	axis := comtrade.ComputeTimeAxisFromMeta(*meta, dat.Timestamps, n)
+	if meta.RatesNum != 0 || math.Abs(float64(axis[10])-0.52) > 1e-6 || meta.AnalogChannels[0].Unit != "kA" || meta.Frequency != 50 {
+		t.Errorf("variable rate: rates=%d axis[10]=%v unit=%q", meta.RatesNum, axis[10], meta.AnalogChannels[0].Unit)
+	}
+
+	// 时间列不递增、列不存在、没有时间也没有采样率
+	ts.Time[10] = ts.Time[9]
+	if _, _, err := importer.Build(ts, importer.SeriesOptions{}); err == nil {
+		t.Error("non-increasing time accepted")
+	}
+	if _, _, err := importer.Build(ts, importer.SeriesOptions{SampleRate: 1000, Channels: []importer.SeriesChannel{{Column: "Ib"}}}); err == nil {
+		t.Error("unknown column accepted")
+	}
+	if _, _, err := importer.Build(&importer.TimeSeries{Columns: ts.Columns}, importer.SeriesOptions{}); err == nil {
+		t.Error("missing time and sample rate accepted")
+	}
+}
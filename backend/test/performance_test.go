@@ -293,3 +293,22 @@ func TestMemoryCacheEviction(t *testing.T) {
 		t.Error("id5 should be in cache")
 	}
 }
+
+// TestDatasetCacheDelete verifies Delete evicts the dataset and its resampled entries only
+func TestDatasetCacheDelete(t *testing.T) {
+	cache := comtrade.NewDatasetCache(10)
+	for _, key := range []string{"ds1", "ds1@4800/linear", "ds1@1000/sinc", "ds10", "ds10@4800/linear"} {
+		cache.Set(key, &comtrade.Metadata{}, &comtrade.ChannelData{})
+	}
+	cache.Delete("ds1")
+	for _, key := range []string{"ds1", "ds1@4800/linear", "ds1@1000/sinc"} {
+		if _, _, ok := cache.Get(key); ok {
+			t.Errorf("%s should have been deleted", key)
+		}
+	}
+	for _, key := range []string{"ds10", "ds10@4800/linear"} {
+		if _, _, ok := cache.Get(key); !ok {
+			t.Errorf("%s should be kept", key)
+		}
+	}
+}
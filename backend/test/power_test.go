@@ -0,0 +1,99 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestComputePowerThreePhaseBalanced(t *testing.T) {
+	const fs, f = 4000.0, 50.0
+	const n = 800
+	uPeak, iPeak := 100*math.Sqrt2, 10*math.Sqrt2
+	lag := math.Pi / 6
+
+	voltages := make([][]float64, 3)
+	currents := make([][]float64, 3)
+	for k := range 3 {
+		shift := -2 * math.Pi / 3 * float64(k)
+		voltages[k] = sineWave(n, fs, f, uPeak, shift)
+		currents[k] = sineWave(n, fs, f, iPeak, shift-lag)
+	}
+
+	ps, err := comtrade.ComputePower(voltages, currents, fs, f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantP := 3 * 100 * 10 * math.Cos(lag)
+	wantQ := 3 * 100 * 10 * math.Sin(lag)
+	j := n / 2
+	if math.Abs(ps.P[j]-wantP) > 1 {
+		t.Fatalf("P: expected %v, got %v", wantP, ps.P[j])
+	}
+	if math.Abs(ps.Q[j]-wantQ) > 1 {
+		t.Fatalf("Q: expected %v, got %v", wantQ, ps.Q[j])
+	}
+	if math.Abs(ps.PF[j]-math.Cos(lag)) > 1e-3 {
+		t.Fatalf("PF: expected %v, got %v", math.Cos(lag), ps.PF[j])
+	}
+	// 三相对称时瞬时功率恒定
+	if math.Abs(ps.Instantaneous[j]-wantP) > 1 || math.Abs(ps.InstantaneousQ[j]-wantQ) > 1 {
+		t.Fatalf("instantaneous p/q: expected %v/%v, got %v/%v", wantP, wantQ, ps.Instantaneous[j], ps.InstantaneousQ[j])
+	}
+}
+
+func TestSideFactor(t *testing.T) {
+	ch := comtrade.AnalogChannel{Primary: 1200, Secondary: 5, PS: "S"}
+	if got := ch.SideFactor(comtrade.ValueSidePrimary); got != 240 {
+		t.Fatalf("secondary->primary: expected 240, got %v", got)
+	}
+	if got := ch.SideFactor(comtrade.ValueSideSecondary); got != 1 {
+		t.Fatalf("secondary->secondary: expected 1, got %v", got)
+	}
+	ch.PS = "P"
+	if got := ch.SideFactor(comtrade.ValueSideSecondary); got != 1.0/240 {
+		t.Fatalf("primary->secondary: expected 1/240, got %v", got)
+	}
+	if got, scale := comtrade.PowerUnitPrefix("kV", "kA"); got != "M" || scale != 1 {
+		t.Fatalf("kV x kA: expected prefix M, got %q x%v", got, scale)
+	}
+	// mV×mA 的 10^-6 没有对应前缀, 退回 W 并换算数值
+	if got, scale := comtrade.PowerUnitPrefix("mV", "mA"); got != "" || scale != 1e-6 {
+		t.Fatalf("mV x mA: expected base unit with scale 1e-6, got %q x%v", got, scale)
+	}
+}
+
+func TestValueConversionUnits(t *testing.T) {
+	ct := comtrade.AnalogChannel{Unit: "A", Primary: 1200, Secondary: 5, PS: "S"}
+	factor, unit := ct.ValueConversion(comtrade.ValueSidePrimary)
+	if unit != "kA" || math.Abs(factor-0.24) > 1e-12 {
+		t.Fatalf("CT secondary->primary: expected 0.24 kA, got %v %s", factor, unit)
+	}
+
+	vt := comtrade.AnalogChannel{Unit: "kV", Primary: 220000, Secondary: 100, PS: "P"}
+	factor, unit = vt.ValueConversion(comtrade.ValueSideSecondary)
+	if unit != "V" || math.Abs(factor-1000.0/2200) > 1e-12 {
+		t.Fatalf("VT primary->secondary: expected 0.4545 V, got %v %s", factor, unit)
+	}
+
+	factor, unit = vt.ValueConversion(comtrade.ValueSideRaw)
+	if unit != "kV" || factor != 1 {
+		t.Fatalf("raw: expected 1 kV, got %v %s", factor, unit)
+	}
+
+	// 未实际换算时保持 CFG 单位: 记录值已是一次值、PS 标志缺失或变比无效
+	for _, ch := range []comtrade.AnalogChannel{
+		{Unit: "A", Primary: 1200, Secondary: 5, PS: "P"},
+		{Unit: "A", Primary: 1200, Secondary: 5},
+		{Unit: "A", PS: "S"},
+	} {
+		if factor, unit := ch.ValueConversion(comtrade.ValueSidePrimary); unit != "A" || factor != 1 {
+			t.Errorf("primary without ratio %+v: expected 1 A, got %v %s", ch, factor, unit)
+		}
+	}
+	if factor, unit := vt.ValueConversion(comtrade.ValueSidePrimary); unit != "kV" || factor != 1 {
+		t.Errorf("VT recorded as primary: expected 1 kV, got %v %s", factor, unit)
+	}
+}
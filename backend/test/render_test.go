@@ -0,0 +1,128 @@
+package test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"comtradeviewer/render"
+)
+
+func renderTestChart() *render.Chart {
+	n := 200
+	x := make([]float64, n)
+	ia := make([]float64, n)
+	ua := make([]float64, n)
+	cb := make([]float64, n)
+	for i := range n {
+		x[i] = float64(i) * 0.5
+		ia[i] = math.Sin(2 * math.Pi * 50 * x[i] / 1000)
+		ua[i] = 100 * math.Cos(2*math.Pi*50*x[i]/1000)
+		if i >= n/2 {
+			cb[i] = 1
+		}
+	}
+	return &render.Chart{
+		Title:      "变电站 <A&B>",
+		TitleShort: "2024-01-01 00:00:00",
+		Width:      800,
+		XMin:       0,
+		XMax:       x[n-1],
+		Series: []render.Series{
+			{Label: "A相电流", Short: "A1", Unit: "kA", Phase: "A", X: x, Y: ia},
+			{Label: "Ua", Short: "A2", Unit: "kV", Phase: "A", X: x, Y: ua},
+			{Label: "断路器", Short: "D1", Digital: true, X: x, Y: cb},
+		},
+		Markers: []render.Marker{
+			{Start: 20, End: 20, Label: "Trigger", Trigger: true},
+			{Start: 40, End: 60, Label: "故障"},
+		},
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	chart := renderTestChart()
+	chart.Height = render.DefaultHeight(chart.Series)
+	canvas := render.NewSVG(chart.Width, chart.Height)
+	chart.Draw(canvas)
+	var buf bytes.Buffer
+	if _, err := canvas.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	// 文档须为合法 XML, 标题中的特殊字符须转义
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v", err)
+		}
+	}
+	if !strings.Contains(out, "变电站 &lt;A&amp;B&gt;") {
+		t.Error("title not escaped")
+	}
+	// SVG 由查看器渲染文字, 中文通道名原样输出
+	for _, want := range []string{"A相电流", "断路器", "故障", "t (ms)", "kA", "kV"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SVG missing %q", want)
+		}
+	}
+	if got := strings.Count(out, "<polyline"); got < 3 {
+		t.Errorf("polylines=%d, want >= 3", got)
+	}
+	if !strings.Contains(out, "stroke-dasharray") {
+		t.Error("markers should be dashed")
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	chart := renderTestChart()
+	chart.Height = 500
+	canvas := render.NewRaster(chart.Width, chart.Height)
+	chart.Draw(canvas)
+	var buf bytes.Buffer
+	if err := canvas.EncodePNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 800 || b.Dy() != 500 {
+		t.Fatalf("size=%v", b)
+	}
+
+	// 统计非背景像素, 并检查相别 A 的黄色曲线已绘制
+	drawn, yellow := 0, 0
+	for y := range 500 {
+		for x := range 800 {
+			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b = r>>8, g>>8, b>>8
+			if r != 255 || g != 255 || b != 255 {
+				drawn++
+			}
+			if r > 200 && g > 140 && g < 200 && b < 60 {
+				yellow++
+			}
+		}
+	}
+	if drawn < 5000 {
+		t.Errorf("only %d pixels drawn", drawn)
+	}
+	if yellow < 200 {
+		t.Errorf("phase A trace pixels=%d, want >= 200", yellow)
+	}
+
+	// PNG 无法显示中文时以短名代替, 不应影响绘制
+	chart.Series = chart.Series[:1]
+	chart.Series[0].X, chart.Series[0].Y = nil, nil
+	chart.Draw(render.NewRaster(chart.Width, chart.Height))
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"comtradeviewer/render"
+	"comtradeviewer/report"
+)
+
+// pdfStreams 校验交叉引用表中的偏移量均指向对应对象, 并返回解压后的全部内容流
+func pdfStreams(t *testing.T, data []byte) []string {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to xref table", xref)
+	}
+	lines := strings.Split(string(data[xref:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for num := 1; num < count; num++ {
+		off, _ := strconv.Atoi(lines[2+num][:10])
+		want := fmt.Sprintf("%d 0 obj\n", num)
+		if !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points to %q", num, data[off:off+12])
+		}
+	}
+
+	streams := make([]string, 0)
+	re := regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	for _, loc := range re.FindAllSubmatchIndex(data, -1) {
+		n, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(data[loc[1] : loc[1]+n]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data[loc[1]+n:], []byte("\nendstream")) {
+			t.Fatal("stream length mismatch")
+		}
+		streams = append(streams, string(b))
+	}
+	return streams
+}
+
+// utf16Hex 返回 PDF 内容流中文字的十六进制编码
+func utf16Hex(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+func TestReportPDF(t *testing.T) {
+	doc := report.NewDocument("故障录波报告")
+	doc.Title("故障录波报告", "站A  REC1")
+	doc.Heading("记录信息", 40)
+	doc.KeyValues([][2]string{{"站名", "站A"}, {"触发时刻", "2024-01-01 00:00:00.010000"}})
+
+	x := make([]float64, 100)
+	y := make([]float64, 100)
+	for i := range x {
+		x[i] = float64(i)
+		y[i] = float64(i % 20)
+	}
+	doc.Heading("波形", 100)
+	doc.Chart(&render.Chart{
+		Title:   "电流",
+		Width:   900,
+		Height:  300,
+		XMax:    99,
+		Series:  []render.Series{{Label: "Ia", Unit: "kA", Phase: "A", X: x, Y: y}},
+		Markers: []render.Marker{{Start: 10, End: 30, Label: "区间"}},
+	})
+
+	// 跨页的长表格
+	rows := make([][]string, 200)
+	for i := range rows {
+		rows[i] = []string{strconv.Itoa(i + 1), "断路器分闸", "0 → 1"}
+	}
+	doc.Heading("开关量变位(SOE)", 40)
+	doc.Table([]string{"序号", "通道", "变位"}, []float64{1, 3, 1}, rows)
+	doc.Paragraph(strings.Repeat("很长的备注文字会自动折行 ", 40))
+	doc.Footer("故障录波报告")
+
+	var buf bytes.Buffer
+	if _, err := doc.PDF().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	streams := pdfStreams(t, data)
+
+	pages := len(doc.PDF().Pages())
+	if pages < 3 {
+		t.Fatalf("pages=%d, want the table to span several pages", pages)
+	}
+	if len(streams) != pages || !bytes.Contains(data, fmt.Appendf(nil, "/Count %d", pages)) {
+		t.Fatalf("streams=%d pages=%d", len(streams), pages)
+	}
+	if !bytes.Contains(data, []byte("/Encoding /UniGB-UTF16-H")) {
+		t.Error("CJK font not declared")
+	}
+	// 半透明的标注区间使用图形状态
+	if !bytes.Contains(data, []byte("/ExtGState << /GS40")) {
+		t.Error("missing ExtGState for translucent fill")
+	}
+
+	all := strings.Join(streams, "\n")
+	for _, want := range []string{"故障录波报告", "电流", "Ia", "断路器分闸", fmt.Sprintf("第 %d / %d 页", pages, pages)} {
+		if !strings.Contains(all, utf16Hex(want)) {
+			t.Errorf("content missing %q", want)
+		}
+	}
+	// 表头在每个续页重复
+	header := utf16Hex("序号")
+	for i := 1; i < pages-1; i++ {
+		if !strings.Contains(streams[i], header) {
+			t.Errorf("page %d missing table header", i+1)
+		}
+	}
+	// 裁剪区在页面结束前恢复
+	for i, s := range streams {
+		if strings.Count(s, "q ") < strings.Count(s, "Q\n") {
+			t.Errorf("page %d has unbalanced graphics state", i+1)
+		}
+	}
+	if strings.Count(all, "q ") != strings.Count(all, "Q\n") {
+		t.Error("unbalanced q/Q operators")
+	}
+}
+
+func TestReportTextWidth(t *testing.T) {
+	if w := report.TextWidth("Ia", 10); w != 10 {
+		t.Errorf("ascii width=%v", w)
+	}
+	if w := report.TextWidth("电流", 10); w != 20 {
+		t.Errorf("cjk width=%v", w)
+	}
+}
+
+func TestReportPolylineBreaksAtNonFinite(t *testing.T) {
+	doc := report.NewPDF("t")
+	page := doc.AddPage(100, 100)
+	page.Polyline([]float64{0, 10, 15, 20, 30, 40}, []float64{0, 10, math.NaN(), 20, 30, math.Inf(1)}, render.Color{A: 255}, 1)
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// NaN 处开始新的子路径, 不画向 0 的尖峰
+	want := "0 100 m\n10 90 l\n20 80 m\n30 70 l\nS\n"
+	if streams := pdfStreams(t, buf.Bytes()); !strings.Contains(streams[0], want) {
+		t.Fatalf("polyline not split at non-finite points:\n%s", streams[0])
+	}
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+// sampledTimes 返回 n 个采样点在采样率 fs 下的毫秒时间轴
+func sampledTimes(n int, fs float64) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = float64(i) * 1000 / fs
+	}
+	return out
+}
+
+// maxErrorAgainst 返回 at[from:to] 内 y 与 f(t) 的最大绝对误差
+func maxErrorAgainst(at, y []float64, from, to int, f func(ms float64) float64) float64 {
+	worst := 0.0
+	for i := from; i < to; i++ {
+		worst = math.Max(worst, math.Abs(y[i]-f(at[i])))
+	}
+	return worst
+}
+
+func TestResampleUpsample(t *testing.T) {
+	const fs = 1000.0
+	src := sampledTimes(400, fs)
+	y := sineWave(400, fs, 50, 100, 0.4)
+	want := func(ms float64) float64 { return 100 * math.Sin(2*math.Pi*50*ms/1000+0.4) }
+
+	at := comtrade.UniformTimes(0, src[len(src)-1], 1000/4000.0)
+	linear := comtrade.Resample(src, y, at, comtrade.ResampleMethodLinear)
+	sinc := comtrade.Resample(src, y, at, comtrade.ResampleMethodSinc)
+
+	from, to := 200, len(at)-200
+	if e := maxErrorAgainst(at, linear, from, to, want); e > 5 {
+		t.Fatalf("linear upsampling error too large: %v", e)
+	}
+	if e := maxErrorAgainst(at, sinc, from, to, want); e > 0.5 {
+		t.Fatalf("sinc upsampling error too large: %v", e)
+	}
+}
+
+func TestResampleDecimationAntiAlias(t *testing.T) {
+	const fs = 4000.0
+	const n = 2000
+	src := sampledTimes(n, fs)
+	y := sineWave(n, fs, 50, 100, 0)
+	for i, v := range sineWave(n, fs, 900, 30, 0) {
+		y[i] += v
+	}
+	want := func(ms float64) float64 { return 100 * math.Sin(2*math.Pi*50*ms/1000) }
+
+	at := comtrade.UniformTimes(0, src[len(src)-1], 1)
+	from, to := 50, len(at)-50
+	for _, method := range []string{comtrade.ResampleMethodLinear, comtrade.ResampleMethodSinc} {
+		out := comtrade.Resample(src, y, at, method)
+		// 未滤波时 900Hz 分量会以 100Hz 混叠, 误差接近 30
+		if e := maxErrorAgainst(at, out, from, to, want); e > 5 {
+			t.Fatalf("%s: 900 Hz component not removed on decimation, max error %v", method, e)
+		}
+	}
+}
+
+func TestResampleDatasetMultiRate(t *testing.T) {
+	meta := &comtrade.Metadata{
+		AnalogChannels:  []comtrade.AnalogChannel{{ChannelNumber: 1, ChannelName: "Ua", Unit: "V", Multiplier: 0.1}},
+		DigitalChannels: []comtrade.DigitalChannel{{ChannelNumber: 1, ChannelName: "Trip"}},
+		RatesNum:        2,
+		SampleRates:     []comtrade.SampleRate{{SampRate: 1000, LastSampleNum: 100}, {SampRate: 500, LastSampleNum: 150}},
+	}
+	dat := &comtrade.ChannelData{Timestamps: make([]int32, 150)}
+	raw := make([]int32, 150)
+	trip := make([]int8, 150)
+	for i := range raw {
+		raw[i] = int32(i)
+		if i >= 120 {
+			trip[i] = 1
+		}
+	}
+	dat.AnalogChannels = []comtrade.AnalogChannelData{{ChannelNumber: 1, RawData: raw}}
+	dat.DigitalChannels = []comtrade.DigitalChannelData{{ChannelNumber: 1, RawData: trip}}
+
+	m, d, err := comtrade.ResampleDataset(meta, dat, 1000, comtrade.ResampleMethodLinear)
+	if err != nil {
+		t.Fatalf("resample: %v", err)
+	}
+	// 100 点 @1kHz(0..99ms) + 50 点 @500Hz(100..198ms) → 0..198ms @1kHz 共 199 点
+	if len(d.Timestamps) != 199 || m.SampleRateAt(150) != 1000 || m.SampleRates[0].LastSampleNum != 199 {
+		t.Fatalf("unexpected resampled layout: %d samples, rates %+v", len(d.Timestamps), m.SampleRates)
+	}
+	y, err := comtrade.ScaledAnalogData(m, d, 1)
+	if err != nil {
+		t.Fatalf("scaled data: %v", err)
+	}
+	// 第二段中 101ms 处位于原采样 100(100ms) 与 101(102ms) 之间
+	if math.Abs(y[50]-5) > 1e-6 || math.Abs(y[101]-10.05) > 1e-6 {
+		t.Fatalf("unexpected interpolated values: %v %v", y[50], y[101])
+	}
+	digital, _ := d.GetDigitalData(1)
+	// 原第 120 点位于 140ms
+	if digital[139] != 0 || digital[140] != 1 {
+		t.Fatalf("unexpected digital hold around 140 ms: %v %v", digital[139], digital[140])
+	}
+
+	if _, _, err := comtrade.ResampleDataset(meta, dat, 0, ""); err == nil {
+		t.Fatalf("expected error for non-positive rate")
+	}
+	if _, _, err := comtrade.ResampleDataset(meta, dat, 1000, "cubic"); err == nil {
+		t.Fatalf("expected error for unknown method")
+	}
+}
+
+func TestResampleDatasetSizeLimit(t *testing.T) {
+	// 100 个模拟量, 1000Hz 记录 1 秒
+	const channels, n = 100, 1001
+	meta := &comtrade.Metadata{RatesNum: 1, SampleRates: []comtrade.SampleRate{{SampRate: 1000, LastSampleNum: n}}}
+	dat := &comtrade.ChannelData{Timestamps: make([]int32, n)}
+	for k := 1; k <= channels; k++ {
+		meta.AnalogChannels = append(meta.AnalogChannels, comtrade.AnalogChannel{ChannelNumber: k, Multiplier: 1})
+		dat.AnalogChannels = append(dat.AnalogChannels, comtrade.AnalogChannelData{ChannelNumber: k, RawData: make([]int32, n)})
+	}
+
+	// 2MHz: 每通道 2000001 点未超过 MaxResampleSamples, 但 100 个通道合计约 770MB
+	if _, _, err := comtrade.ResampleDataset(meta, dat, 2e6, ""); !errors.Is(err, comtrade.ErrInvalidResample) {
+		t.Fatalf("expected ErrInvalidResample for total size, got %v", err)
+	}
+	if _, _, err := comtrade.ResampleDataset(meta, dat, 1e10, ""); !errors.Is(err, comtrade.ErrInvalidResample) {
+		t.Fatalf("expected ErrInvalidResample for per-channel samples, got %v", err)
+	}
+	_, d, err := comtrade.ResampleDataset(meta, dat, 10000, "")
+	if err != nil || len(d.Timestamps) != 10001 || len(d.AnalogChannels) != channels {
+		t.Fatalf("10kHz resample: %v", err)
+	}
+}
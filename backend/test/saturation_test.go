@@ -0,0 +1,73 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+// faultCurrent 故障前为负荷电流, 第 faultAt 点起为带衰减直流分量的故障电流
+func faultCurrent(n int, fs float64, faultAt int) []float64 {
+	y := make([]float64, n)
+	w := 2 * math.Pi * 50 / fs
+	for i := range y {
+		if i < faultAt {
+			y[i] = 100 * math.Sin(w*float64(i))
+			continue
+		}
+		k := float64(i - faultAt)
+		// 电压过零附近发生故障: 全偏移故障电流
+		y[i] = 2000 * (math.Exp(-k/fs/0.06) - math.Cos(w*k))
+	}
+	return y
+}
+
+// saturateCT 以磁链限幅的理想模型模拟 CT 饱和: 磁链超过 limit 时二次电流为零
+func saturateCT(primary []float64, fs, limit float64) []float64 {
+	out := make([]float64, len(primary))
+	flux := 0.0
+	for i, v := range primary {
+		next := flux + v/fs
+		if math.Abs(next) > limit && math.Signbit(next) == math.Signbit(v) {
+			flux = math.Copysign(limit, next)
+			continue
+		}
+		flux = next
+		out[i] = v
+	}
+	return out
+}
+
+func TestDetectCTSaturation(t *testing.T) {
+	const fs, n, faultAt = 4000.0, 2400, 400
+	primary := faultCurrent(n, fs, faultAt)
+
+	if got := comtrade.DetectCTSaturation(primary, fs, 50); len(got) != 0 {
+		t.Fatalf("unsaturated fault current should not be flagged, got %+v", got)
+	}
+
+	saturated := saturateCT(primary, fs, 12)
+	intervals := comtrade.DetectCTSaturation(saturated, fs, 50)
+	if len(intervals) == 0 {
+		t.Fatalf("expected saturation to be detected")
+	}
+	first := intervals[0]
+	if first.Start < faultAt || first.Start > faultAt+int(fs/50)*4 {
+		t.Fatalf("saturation should be flagged within a few cycles after the fault, got %+v", first)
+	}
+	if first.Confidence < 0.45 || first.Confidence > 1 {
+		t.Fatalf("unexpected confidence: %+v", first)
+	}
+	hasFlat := false
+	for _, iv := range intervals {
+		for _, ind := range iv.Indicators {
+			if ind == comtrade.SaturationFlatTop {
+				hasFlat = true
+			}
+		}
+	}
+	if !hasFlat {
+		t.Fatalf("expected flat-top indicator, got %+v", intervals)
+	}
+}
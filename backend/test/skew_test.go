@@ -0,0 +1,34 @@
+package test
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestCompensateSkewRemovesPhaseError(t *testing.T) {
+	const fs, f = 4000.0, 50.0
+	const skewMicros = 250.0
+
+	// 通道实际在 t_k + skew 采样, 相对于理想通道超前 2π·f·skew
+	ideal := sineWave(800, fs, f, 100, 0)
+	skewed := sineWave(800, fs, f, 100, 2*math.Pi*f*skewMicros*1e-6)
+
+	corrected := comtrade.CompensateSkew(skewed, skewMicros, fs)
+
+	idx := 400
+	want := comtrade.PhasorAngleDegrees(comtrade.DFTPhasor(ideal, idx, fs, f))
+	before := comtrade.PhasorAngleDegrees(comtrade.DFTPhasor(skewed, idx, fs, f))
+	after := comtrade.PhasorAngleDegrees(comtrade.DFTPhasor(corrected, idx, fs, f))
+	if math.Abs(before-want) < 4 {
+		t.Fatalf("expected skewed channel to lead by ~4.5 deg, got %v vs %v", before, want)
+	}
+	if math.Abs(after-want) > 0.1 {
+		t.Fatalf("expected compensated angle ~%v, got %v", want, after)
+	}
+	if m := cmplx.Abs(comtrade.DFTPhasor(corrected, idx, fs, f)); math.Abs(m-100/math.Sqrt2) > 0.5 {
+		t.Fatalf("unexpected magnitude after compensation: %v", m)
+	}
+}
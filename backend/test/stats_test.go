@@ -0,0 +1,53 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+func TestAnalogStatistics(t *testing.T) {
+	const fs, n = 1000.0, 110 // 5.5 个周波
+	y := sineWave(n, fs, 50, 10, 0)
+	for i := range y {
+		y[i] += 2
+	}
+	times := make([]float32, n)
+	for i := range times {
+		times[i] = float32(i)
+	}
+
+	s := comtrade.AnalogStatistics(y, times, 0, n-1, fs, 50)
+	if s.Samples != n || math.Abs(s.Max-12) > 1e-9 || math.Abs(s.Min+8) > 1e-9 {
+		t.Fatalf("unexpected extremes: %+v", s)
+	}
+	if s.MaxTime != 5 || s.MinTime != 15 {
+		t.Fatalf("unexpected extreme times: max at %v, min at %v", s.MaxTime, s.MinTime)
+	}
+	// 整数周波平均应精确等于直流分量, 而 5.5 周波的算术平均带有半周波偏差
+	if math.Abs(s.DCOffset-2) > 1e-9 || math.Abs(s.Mean-2) < 0.1 {
+		t.Fatalf("unexpected mean %v / dc offset %v", s.Mean, s.DCOffset)
+	}
+	wantRMS := math.Sqrt(4 + 50)
+	if math.Abs(s.RMS-wantRMS) > 0.2 || math.Abs(s.PeakToPeak-20) > 1e-9 || math.Abs(s.Crest-12/s.RMS) > 1e-9 {
+		t.Fatalf("unexpected rms %v / p-p %v / crest %v", s.RMS, s.PeakToPeak, s.Crest)
+	}
+
+	w := comtrade.AnalogStatistics(y, times, 20, 39, fs, 50)
+	if w.Samples != 20 || math.Abs(w.DCOffset-2) > 1e-9 {
+		t.Fatalf("unexpected window stats: %+v", w)
+	}
+}
+
+func TestDigitalStatistics(t *testing.T) {
+	y := []int8{0, 0, 1, 1, 1, 0, 1, 1}
+	times := []float32{0, 1, 2, 3, 4, 5, 6, 7}
+	s := comtrade.DigitalStatistics(y, times, 0, 7)
+	if s.Transitions != 3 || s.Rising != 2 || s.Falling != 1 {
+		t.Fatalf("unexpected transitions: %+v", s)
+	}
+	if s.TimeHigh != 5 || s.TimeLow != 3 || s.Initial != 0 || s.Final != 1 {
+		t.Fatalf("unexpected state durations: %+v", s)
+	}
+}
@@ -0,0 +1,365 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"comtradeviewer/comtrade"
+	"comtradeviewer/importer"
+)
+
+// berTLV 按 BER 编码单字节标签的 TLV
+func berTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// svSample 构造报文用的一个采样
+type svSample struct {
+	svID    string
+	smpCnt  uint16
+	synch   bool
+	smpRate uint16
+	values  []int32
+	quality []uint32
+}
+
+// svFrame 构造包含若干 ASDU 的 SV 以太网帧, vlan 为 true 时带 802.1Q 标签
+func svFrame(appID uint16, vlan bool, samples ...svSample) []byte {
+	var seq []byte
+	for _, s := range samples {
+		var asdu []byte
+		asdu = append(asdu, berTLV(0x80, []byte(s.svID))...)
+		asdu = append(asdu, berTLV(0x82, binary.BigEndian.AppendUint16(nil, s.smpCnt))...)
+		asdu = append(asdu, berTLV(0x83, []byte{0, 0, 0, 1})...)
+		synch := byte(0)
+		if s.synch {
+			synch = 1
+		}
+		asdu = append(asdu, berTLV(0x85, []byte{synch})...)
+		if s.smpRate > 0 {
+			asdu = append(asdu, berTLV(0x86, binary.BigEndian.AppendUint16(nil, s.smpRate))...)
+		}
+		var data []byte
+		for k, v := range s.values {
+			data = binary.BigEndian.AppendUint32(data, uint32(v))
+			data = binary.BigEndian.AppendUint32(data, s.quality[k])
+		}
+		asdu = append(asdu, berTLV(0x87, data)...)
+		seq = append(seq, berTLV(0x30, asdu)...)
+	}
+	pdu := berTLV(0x60, append(berTLV(0x80, []byte{byte(len(samples))}), berTLV(0xa2, seq)...))
+	sv := binary.BigEndian.AppendUint16(nil, appID)
+	sv = binary.BigEndian.AppendUint16(sv, uint16(8+len(pdu)))
+	sv = append(sv, 0, 0, 0, 0)
+	sv = append(sv, pdu...)
+
+	frame := []byte{0x01, 0x0c, 0xcd, 0x04, 0x00, 0x01, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	if vlan {
+		frame = append(frame, 0x81, 0x00, 0x80, 0x05)
+	}
+	frame = append(frame, 0x88, 0xba)
+	return append(frame, sv...)
+}
+
+// capturedFrame 抓包时刻与帧内容
+type capturedFrame struct {
+	at   time.Time
+	data []byte
+}
+
+// writePcap 写出经典 pcap(小端、微秒时间戳、以太网)
+func writePcap(frames []capturedFrame) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, 0xa1b2c3d4)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = binary.LittleEndian.AppendUint32(b, 65535)
+	b = binary.LittleEndian.AppendUint32(b, 1)
+	for _, f := range frames {
+		b = binary.LittleEndian.AppendUint32(b, uint32(f.at.Unix()))
+		b = binary.LittleEndian.AppendUint32(b, uint32(f.at.Nanosecond()/1000))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(f.data)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(f.data)))
+		b = append(b, f.data...)
+	}
+	return b
+}
+
+// writePcapng 写出大端 pcapng, 接口时间戳精度为纳秒(if_tsresol=9)
+func writePcapng(frames []capturedFrame) []byte {
+	be := binary.BigEndian
+	block := func(b []byte, typ uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		b = be.AppendUint32(b, typ)
+		b = be.AppendUint32(b, uint32(12+len(body)))
+		b = append(b, body...)
+		return be.AppendUint32(b, uint32(12+len(body)))
+	}
+	shb := be.AppendUint32(nil, 0x1a2b3c4d)
+	shb = be.AppendUint16(shb, 1)
+	shb = be.AppendUint16(shb, 0)
+	shb = be.AppendUint64(shb, math.MaxUint64)
+	b := block(nil, 0x0a0d0d0a, shb)
+
+	idb := be.AppendUint16(nil, 1)
+	idb = be.AppendUint16(idb, 0)
+	idb = be.AppendUint32(idb, 65535)
+	idb = append(idb, 0, 9, 0, 1, 9, 0, 0, 0, 0, 0, 0, 0)
+	b = block(b, 1, idb)
+
+	for _, f := range frames {
+		ts := uint64(f.at.UnixNano())
+		epb := be.AppendUint32(nil, 0)
+		epb = be.AppendUint32(epb, uint32(ts>>32))
+		epb = be.AppendUint32(epb, uint32(ts))
+		epb = be.AppendUint32(epb, uint32(len(f.data)))
+		epb = be.AppendUint32(epb, uint32(len(f.data)))
+		epb = append(epb, f.data...)
+		b = block(b, 6, epb)
+	}
+	return b
+}
+
+// leSample 构造 9-2LE 采样: Ia = 100*seq mA, Ua = 1000*seq ×10mV, 其余通道为 0
+func leSample(svID string, smpCnt uint16, seq int) svSample {
+	values := make([]int32, 8)
+	values[0] = int32(100 * seq)
+	values[4] = int32(1000 * seq)
+	return svSample{svID: svID, smpCnt: smpCnt, synch: true, values: values, quality: make([]uint32, 8)}
+}
+
+func TestSVPcapImport(t *testing.T) {
+	const rate = 4000
+	second := time.Unix(1700000000, 0).UTC()
+	// smpCnt 3990..3999 属于上一秒, 之后翻转为 0..20
+	type entry struct {
+		cnt uint16
+		seq int
+	}
+	var order []entry
+	for i := range 31 {
+		cnt := uint16((3990 + i) % rate)
+		switch cnt {
+		case 5, 6:
+			continue // 丢帧
+		case 12:
+			order = append(order, entry{13, i + 1}, entry{12, i})
+			continue
+		case 13:
+			continue
+		}
+		order = append(order, entry{cnt, i})
+		if cnt == 10 {
+			order = append(order, entry{cnt, i}) // 重复帧
+		}
+	}
+
+	frames := []capturedFrame{{second, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 0x08, 0x06, 0, 0}}}
+	for _, e := range order {
+		s := leSample("MU01", e.cnt, e.seq)
+		if e.cnt == 15 {
+			s.quality[1] = 0x0001
+		}
+		if e.cnt == 16 {
+			for k := range s.quality {
+				s.quality[k] = 1 << 11
+			}
+		}
+		sampled := second.Add(time.Duration(e.cnt) * time.Second / rate)
+		if e.cnt >= 3990 {
+			sampled = sampled.Add(-time.Second)
+		}
+		frames = append(frames, capturedFrame{sampled.Add(150 * time.Microsecond), svFrame(0x4000, e.cnt%2 == 0, s)})
+	}
+	broken := svFrame(0x4000, false, leSample("MU01", 100, 0))
+	frames = append(frames, capturedFrame{second.Add(time.Second), broken[:40]})
+
+	capture, err := importer.DecodeSVCapture(writePcap(frames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capture.Frames != len(frames) || capture.SVFrames != len(frames)-1 || capture.Malformed != 1 || len(capture.Streams) != 1 {
+		t.Fatalf("frames=%d sv=%d malformed=%d streams=%d", capture.Frames, capture.SVFrames, capture.Malformed, len(capture.Streams))
+	}
+	stream := capture.Streams[0]
+	if stream.AppID != 0x4000 || stream.Source != "00:11:22:33:44:55" {
+		t.Fatalf("stream %+v", stream)
+	}
+
+	meta, dat, report, err := importer.BuildSV(stream, importer.SVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SampleRate != rate || report.Samples != 31 || report.Missing != 2 || report.Duplicates != 1 || report.OutOfOrder != 1 {
+		t.Fatalf("report: %+v", report)
+	}
+	if len(report.Gaps) != 1 || report.Gaps[0] != (importer.SVGap{After: 4, Missing: 2}) {
+		t.Fatalf("gaps: %+v", report.Gaps)
+	}
+	if report.Channels[1].Invalid != 1 || report.Channels[0].Test != 1 || !report.Synchronized {
+		t.Fatalf("quality: %+v", report.Channels)
+	}
+	// 同步采样: 首个样本(上一秒的 smpCnt=3990)的采样时刻
+	if want := second.Add(-time.Second + 3990*time.Second/rate); !meta.StartTime.Equal(want) {
+		t.Errorf("start %v, want %v", meta.StartTime, want)
+	}
+
+	// 写出后重新解析: 一次值、缺失样本沿用前值、各标志通道
+	var cfg, datBuf bytes.Buffer
+	if err := comtrade.WriteCFG(&cfg, meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := comtrade.WriteDAT(&datBuf, meta, dat); err != nil {
+		t.Fatal(err)
+	}
+	m, d, err := comtrade.ParseComtradeFromBytes(cfg.Bytes(), datBuf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ia, _ := comtrade.ScaledAnalogData(m, d, 1)
+	ua, _ := comtrade.ScaledAnalogData(m, d, 5)
+	if m.AnalogChannels[0].Unit != "A" || m.AnalogChannels[4].ChannelName != "Ua" || len(m.DigitalChannels) != 11 {
+		t.Fatalf("channels: %+v", m.AnalogChannels[0])
+	}
+	for j := range 31 {
+		seq := j
+		if j == 15 || j == 16 { // smpCnt 5、6 缺失, 沿用 smpCnt 4
+			seq = 14
+		}
+		if math.Abs(ia[j]-0.1*float64(seq)) > 1e-9 || math.Abs(ua[j]-10*float64(seq)) > 1e-9 {
+			t.Fatalf("sample %d: Ia=%v Ua=%v want seq %d", j, ia[j], ua[j], seq)
+		}
+	}
+	digital := func(name string) []int8 {
+		for _, ch := range m.DigitalChannels {
+			if ch.ChannelName == name {
+				y, _ := d.GetDigitalData(ch.ChannelNumber)
+				return y
+			}
+		}
+		t.Fatalf("digital channel %s missing", name)
+		return nil
+	}
+	missing := digital("missing")
+	if missing[14] != 0 || missing[15] != 1 || missing[16] != 1 || missing[17] != 0 {
+		t.Errorf("missing flags: %v", missing)
+	}
+	if q := digital("Ib.q"); q[25] != 1 || slices.Index(q, 1) != 25 {
+		t.Errorf("Ib quality flags: %v", q)
+	}
+	if test := digital("test"); test[26] != 1 || test[25] != 0 {
+		t.Errorf("test flags: %v", test)
+	}
+	if synch := digital("smpSynch"); synch[0] != 1 || synch[15] != 0 {
+		t.Errorf("synch flags: %v", synch)
+	}
+}
+
+func TestSVPcapngStreams(t *testing.T) {
+	// 两个数据流, 每帧 8 个 ASDU, smpRate=256 每周波, 未同步
+	start := time.Date(2024, 3, 1, 8, 0, 0, 123456789, time.UTC)
+	var frames []capturedFrame
+	for f := range 4 {
+		for _, id := range []string{"MU_A", "MU_B"} {
+			batch := make([]svSample, 8)
+			for k := range batch {
+				cnt := uint16(8*f + k + 100)
+				batch[k] = leSample(id, cnt, int(cnt))
+				batch[k].synch, batch[k].smpRate = false, 256
+			}
+			at := start.Add(time.Duration(f) * 625 * time.Microsecond)
+			frames = append(frames, capturedFrame{at, svFrame(0x4001, false, batch...)})
+		}
+	}
+	capture, err := importer.DecodeSVCapture(writePcapng(frames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := capture.SVStreamIDs(); !slices.Equal(ids, []string{"MU_A", "MU_B"}) {
+		t.Fatalf("streams: %v", ids)
+	}
+	s, ok := capture.FindStream(" MU_B ")
+	if !ok {
+		t.Fatal("stream MU_B not found")
+	}
+	meta, dat, report, err := importer.BuildSV(s, importer.SVOptions{Frequency: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SampleRate != 256*60 || report.SamplesPerCycle != 256 || report.Samples != 32 || report.Missing != 0 || report.Synchronized {
+		t.Fatalf("report: %+v", report)
+	}
+	// 未同步时开始时刻取首帧抓包时间(纳秒精度截断到微秒)
+	if !meta.StartTime.Equal(start.Truncate(time.Microsecond)) || len(report.Warnings) != 1 {
+		t.Errorf("start %v warnings %v", meta.StartTime, report.Warnings)
+	}
+	if ia, _, _ := dat.GetAnalogData(1); ia[31] != 100*131 {
+		t.Errorf("last Ia code %d", ia[31])
+	}
+	if _, ok := capture.FindStream("MU_C"); ok {
+		t.Error("unexpected stream MU_C")
+	}
+
+	if _, err := importer.DecodeSVCapture([]byte("not a capture file")); err == nil {
+		t.Error("expected error for non-capture data")
+	}
+}
+
+func TestSVLongGap(t *testing.T) {
+	const rate = 4000
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	// smpCnt 990..999 之后丢失 0.6s(2400 个采样), 继续 3400..3409: 差值超过半个翻转周期
+	var frames []capturedFrame
+	for _, cnt := range []int{990, 991, 992, 993, 994, 995, 996, 997, 998, 999, 3400, 3401, 3402, 3403, 3404, 3405, 3406, 3407, 3408, 3409} {
+		at := start.Add(time.Duration(cnt-990) * time.Second / rate)
+		frames = append(frames, capturedFrame{at.Add(100 * time.Microsecond), svFrame(0x4000, false, leSample("MU01", uint16(cnt), cnt))})
+	}
+	capture, err := importer.DecodeSVCapture(writePcap(frames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, dat, report, err := importer.BuildSV(capture.Streams[0], importer.SVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SampleRate != rate || report.Samples != 2420 || report.Missing != 2400 || report.OutOfOrder != 0 || report.Duplicates != 0 {
+		t.Fatalf("report: %+v", report)
+	}
+	if len(report.Gaps) != 1 || report.Gaps[0] != (importer.SVGap{After: 999, Missing: 2400}) {
+		t.Fatalf("gaps: %+v", report.Gaps)
+	}
+	if ia, _, _ := dat.GetAnalogData(1); ia[2419] != 100*3409 || ia[2410] != 100*3400 || ia[9] != 100*999 || meta.SampleRates[0].LastSampleNum != 2420 {
+		t.Errorf("Ia codes: %d %d %d", ia[9], ia[2410], ia[2419])
+	}
+
+	// 抓包时间跳变过大时拒绝重建, 而不是按跳变申请内存
+	frames = frames[:0]
+	for k, cnt := range []uint16{10, 11} {
+		s := leSample("MU02", cnt, int(cnt))
+		s.smpRate = 80
+		frames = append(frames, capturedFrame{start.Add(time.Duration(k) * time.Hour), svFrame(0x4001, false, s)})
+	}
+	capture, err = importer.DecodeSVCapture(writePcap(frames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := importer.BuildSV(capture.Streams[0], importer.SVOptions{}); err == nil {
+		t.Error("expected error for a jump beyond MaxSVSamples")
+	}
+}
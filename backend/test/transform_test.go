@@ -0,0 +1,59 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"comtradeviewer/comtrade"
+)
+
+// balancedCos 返回以 a = A·cos(ωt + φ) 为参考的对称正序三相量
+func balancedCos(n int, fs, f, amplitude, phase float64) (a, b, c []float64) {
+	shift := math.Pi / 2 // sineWave 为正弦, 加 90° 得到余弦
+	a = sineWave(n, fs, f, amplitude, phase+shift)
+	b = sineWave(n, fs, f, amplitude, phase+shift-2*math.Pi/3)
+	c = sineWave(n, fs, f, amplitude, phase+shift+2*math.Pi/3)
+	return a, b, c
+}
+
+func TestClarkeTransform(t *testing.T) {
+	const fs = 2000.0
+	a, b, c := balancedCos(200, fs, 50, 10, 0.3)
+	ab := comtrade.ClarkeTransform(a, b, c)
+	for i := range ab.Alpha {
+		theta := 2*math.Pi*50*float64(i)/fs + 0.3
+		if math.Abs(ab.Alpha[i]-10*math.Cos(theta)) > 1e-9 || math.Abs(ab.Beta[i]-10*math.Sin(theta)) > 1e-9 || math.Abs(ab.Zero[i]) > 1e-9 {
+			t.Fatalf("index %d: unexpected αβ0 %v %v %v", i, ab.Alpha[i], ab.Beta[i], ab.Zero[i])
+		}
+	}
+}
+
+func TestParkTransformAngles(t *testing.T) {
+	const fs, n = 2000.0, 400
+	a, b, c := balancedCos(n, fs, 50, 10, 0.7)
+	ab := comtrade.ClarkeTransform(a, b, c)
+
+	tracked, err := comtrade.TrackedAngle(a, b, c, fs, 50)
+	if err != nil {
+		t.Fatalf("tracked angle: %v", err)
+	}
+	fixed := comtrade.FixedAngle(n, fs, 50, 0.7)
+	for name, theta := range map[string][]float64{"tracked": tracked, "fixed": fixed} {
+		dq := comtrade.ParkTransform(ab, theta)
+		for i := range dq.D {
+			if math.Abs(dq.D[i]-10) > 1e-6 || math.Abs(dq.Q[i]) > 1e-6 {
+				t.Fatalf("%s index %d: expected d=10, q=0, got d=%v q=%v", name, i, dq.D[i], dq.Q[i])
+			}
+		}
+	}
+
+	// 固定角度的初相偏 90° 时, 全部分量转到 -q 轴
+	dq := comtrade.ParkTransform(ab, comtrade.FixedAngle(n, fs, 50, 0.7+math.Pi/2))
+	if math.Abs(dq.D[100]) > 1e-6 || math.Abs(dq.Q[100]+10) > 1e-6 {
+		t.Fatalf("unexpected dq with shifted angle: d=%v q=%v", dq.D[100], dq.Q[100])
+	}
+
+	if _, err := comtrade.TrackedAngle(a[:10], b[:10], c[:10], fs, 50); err == nil {
+		t.Fatalf("expected error for record shorter than one cycle")
+	}
+}